@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/user"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/audit"
+	"leblanc.io/open-go-ssl-checker/internal/auth"
+	"leblanc.io/open-go-ssl-checker/internal/store"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// runCommand runs the administration command given on the command line, if
+// any. It reports whether a command was run.
+func runCommand(s store.Store, arguments args) (bool, error) {
+	switch {
+	case arguments.CreateAPIKey != "":
+		return true, createAPIKey(s, arguments)
+	case arguments.RevokeAPIKey != "":
+		return true, revokeAPIKey(s, arguments.RevokeAPIKey)
+	case arguments.ListAPIKeys:
+		return true, listAPIKeys(s)
+	case arguments.CreateUser != "":
+		return true, createUser(s, arguments.CreateUser, arguments.Role, splitTeams(arguments.Teams))
+	case arguments.SetPassword != "":
+		return true, setPassword(s, arguments.SetPassword)
+	case arguments.SetTeams != "":
+		return true, setTeams(s, arguments.SetTeams, splitTeams(arguments.Teams))
+	case arguments.SetRole != "":
+		return true, setRole(s, arguments.SetRole, arguments.Role)
+	case arguments.DeleteUser != "":
+		return true, deleteUser(s, arguments.DeleteUser)
+	case arguments.ListUsers:
+		return true, listUsers(s)
+	default:
+		return false, nil
+	}
+}
+
+func createAPIKey(s store.Store, arguments args) error {
+	scopes, err := auth.ParseScopes(arguments.Scopes)
+	if err != nil {
+		return err
+	}
+
+	var expiresAt *time.Time
+
+	if arguments.Expires > 0 {
+		t := time.Now().Add(arguments.Expires)
+		expiresAt = &t
+	}
+
+	apiKey, key, err := auth.CreateAPIKey(s, arguments.CreateAPIKey, scopes, splitTeams(arguments.Teams), expiresAt)
+	if err != nil {
+		return fmt.Errorf("unable to create API key: %w", err)
+	}
+
+	recordCommand(s, types.AuditAPIKeyCreate, apiKeyTarget(*apiKey), nil, audit.APIKeySnapshot(*apiKey))
+	fmt.Printf("API key %s created with scopes %s.\n", apiKey.Name, strings.Join(apiKey.Scopes, ","))
+	fmt.Println("Store it now, it will not be shown again:")
+	fmt.Println(key)
+
+	return nil
+}
+
+func revokeAPIKey(s store.Store, name string) error {
+	keys, err := s.ListAPIKeys()
+	if err != nil {
+		return fmt.Errorf("unable to list API keys: %w", err)
+	}
+
+	for _, k := range keys {
+		if k.Name == name {
+			if err := s.RevokeAPIKey(k.ID, time.Now()); err != nil {
+				return fmt.Errorf("unable to revoke API key: %w", err)
+			}
+
+			recordCommand(s, types.AuditAPIKeyRevoke, apiKeyTarget(k), audit.APIKeySnapshot(k), nil)
+			fmt.Printf("API key %s revoked.\n", name)
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("API key %s not found", name)
+}
+
+func listAPIKeys(s store.Store) error {
+	keys, err := s.ListAPIKeys()
+	if err != nil {
+		return fmt.Errorf("unable to list API keys: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPREFIX\tSCOPES\tTEAMS\tCREATED\tEXPIRES\tLAST USED\tREVOKED")
+
+	for _, k := range keys {
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			k.Name,
+			k.Prefix,
+			strings.Join(k.Scopes, ","),
+			formatTeams(k.Teams),
+			k.CreatedAt.Format(time.DateTime),
+			formatOptionalTime(k.ExpiresAt),
+			formatOptionalTime(k.LastUsedAt),
+			formatOptionalTime(k.RevokedAt),
+		)
+	}
+
+	return w.Flush()
+}
+
+func formatTeams(teams []string) string {
+	if len(teams) == 0 {
+		return "-"
+	}
+
+	return strings.Join(teams, ",")
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+
+	return t.Format(time.DateTime)
+}
+
+// readPassword reads the password from the first line of the standard input,
+// so it can be piped from a secret manager.
+func readPassword() (string, error) {
+	fmt.Fprint(os.Stderr, "Password: ")
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("unable to read password: %w", err)
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func createUser(s store.Store, username string, role string, teams []string) error {
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+
+	user, err := auth.CreateUser(s, username, password, role, teams)
+	if err != nil {
+		return fmt.Errorf("unable to create user: %w", err)
+	}
+
+	recordCommand(s, types.AuditUserCreate, userTarget(*user), nil, audit.UserSnapshot(*user))
+	fmt.Printf("User %s created with role %s.\n", user.Username, user.Role)
+
+	return nil
+}
+
+func setPassword(s store.Store, username string) error {
+	user, err := findUser(s, username)
+	if err != nil {
+		return err
+	}
+
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+
+	if user.Provider != types.ProviderLocal {
+		return fmt.Errorf("user %s is provisioned by %s and has no password", username, user.Provider)
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	if err := s.UpdateUserPassword(user.ID, hash); err != nil {
+		return fmt.Errorf("unable to change password: %w", err)
+	}
+
+	recordCommand(s, types.AuditUserUpdate, userTarget(*user), nil, map[string]any{"password_changed": true})
+	fmt.Printf("Password of user %s changed.\n", username)
+
+	return nil
+}
+
+func setRole(s store.Store, username string, role string) error {
+	if types.RoleRank(role) == 0 {
+		return fmt.Errorf("invalid role %q, must be one of %s", role, strings.Join(auth.Roles, ", "))
+	}
+
+	user, err := findUser(s, username)
+	if err != nil {
+		return err
+	}
+
+	if err := s.UpdateUserRole(user.ID, role); err != nil {
+		return fmt.Errorf("unable to change role: %w", err)
+	}
+
+	updated := *user
+	updated.Role = role
+	recordCommand(
+		s,
+		types.AuditUserUpdate,
+		userTarget(*user),
+		audit.UserSnapshot(*user),
+		audit.UserSnapshot(updated),
+	)
+	fmt.Printf("Role of user %s changed to %s.\n", username, role)
+
+	return nil
+}
+
+func setTeams(s store.Store, username string, teams []string) error {
+	user, err := findUser(s, username)
+	if err != nil {
+		return err
+	}
+
+	if err := s.UpdateUserTeams(user.ID, teams); err != nil {
+		return fmt.Errorf("unable to change teams: %w", err)
+	}
+
+	updated := *user
+	updated.Teams = teams
+	recordCommand(
+		s,
+		types.AuditUserUpdate,
+		userTarget(*user),
+		audit.UserSnapshot(*user),
+		audit.UserSnapshot(updated),
+	)
+	fmt.Printf("Teams of user %s changed to %s.\n", username, strings.Join(teams, ","))
+
+	return nil
+}
+
+// splitTeams parses a comma separated list of teams.
+func splitTeams(value string) []string {
+	var teams []string
+
+	for _, team := range strings.Split(value, ",") {
+		if team = strings.TrimSpace(team); team != "" && !slices.Contains(teams, team) {
+			teams = append(teams, team)
+		}
+	}
+
+	return teams
+}
+
+func deleteUser(s store.Store, username string) error {
+	user, err := findUser(s, username)
+	if err != nil {
+		return err
+	}
+
+	if err := s.DeleteUser(user.ID); err != nil {
+		return fmt.Errorf("unable to delete user: %w", err)
+	}
+
+	recordCommand(s, types.AuditUserDelete, userTarget(*user), audit.UserSnapshot(*user), nil)
+	fmt.Printf("User %s deleted.\n", username)
+
+	return nil
+}
+
+// recordCommand writes the change made on the command line to the audit log,
+// with the system user running the command as actor.
+func recordCommand(s store.Store, action string, target audit.Target, before any, after any) {
+	actor := audit.Actor{Name: "cli", Source: types.AuditSourceCLI}
+	if u, err := user.Current(); err == nil {
+		actor.Name = "cli:" + u.Username
+	}
+
+	audit.NewRecorder(s).Record(actor, action, target, before, after)
+}
+
+func userTarget(u types.User) audit.Target {
+	return audit.Target{Type: audit.TargetUser, ID: u.ID, Name: u.Username}
+}
+
+func apiKeyTarget(k types.APIKey) audit.Target {
+	return audit.Target{Type: audit.TargetAPIKey, ID: k.ID, Name: k.Name}
+}
+
+func findUser(s store.Store, username string) (*types.User, error) {
+	user, err := s.GetUserByUsername(username)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve user: %w", err)
+	}
+
+	if user == nil {
+		return nil, fmt.Errorf("user %s not found", username)
+	}
+
+	return user, nil
+}
+
+func listUsers(s store.Store) error {
+	users, err := s.ListUsers()
+	if err != nil {
+		return fmt.Errorf("unable to list users: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USERNAME\tROLE\tPROVIDER\tTEAMS\tCREATED")
+
+	for _, u := range users {
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\t%s\t%s\n",
+			u.Username,
+			u.Role,
+			u.Provider,
+			formatTeams(u.Teams),
+			u.CreatedAt.Format(time.DateTime),
+		)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,138 @@
+// Package audit records who added, changed or deleted the projects, the API
+// keys and the users, and who triggered the manual checks.
+package audit
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/logger"
+	"leblanc.io/open-go-ssl-checker/internal/store"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// Actor is who made a change, and how.
+type Actor struct {
+	// Name is the username, "api-key:<name>" for the API keys, or
+	// "anonymous" when the web UI authentication is disabled.
+	Name       string
+	Source     string
+	RemoteAddr string
+}
+
+// Target is what a change applies to.
+type Target struct {
+	Type string
+	ID   string
+	Name string
+}
+
+// Target types.
+const (
+	TargetProject   = "project"
+	TargetAPIKey    = "api_key"
+	TargetUser      = "user"
+	TargetCandidate = "candidate"
+)
+
+// Recorder writes the audit log.
+type Recorder struct {
+	store store.AuditRepository
+}
+
+func NewRecorder(s store.AuditRepository) *Recorder {
+	return &Recorder{store: s}
+}
+
+// Record writes an entry with the JSON snapshots of the target before and
+// after the change, nil for a creation or a deletion. The change is already
+// done: a failure is logged and not returned.
+func (r *Recorder) Record(actor Actor, action string, target Target, before any, after any) {
+	if r == nil {
+		return
+	}
+
+	entry := types.AuditEntry{
+		Time:       time.Now(),
+		Actor:      actor.Name,
+		Source:     actor.Source,
+		RemoteAddr: actor.RemoteAddr,
+		Action:     action,
+		TargetType: target.Type,
+		TargetID:   target.ID,
+		TargetName: target.Name,
+		Before:     snapshot(before),
+		After:      snapshot(after),
+	}
+
+	if err := r.store.AddAuditEntry(entry); err != nil {
+		logger.Logger.Error(
+			"Audit error - AddAuditEntry",
+			"action", action,
+			"target_id", target.ID,
+			"actor", actor.Name,
+			"error", err,
+		)
+	}
+}
+
+func snapshot(v any) string {
+	if v == nil {
+		return ""
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		logger.Logger.Error("Audit error - snapshot", "error", err)
+
+		return ""
+	}
+
+	return string(b)
+}
+
+// ProjectSnapshot is the audited state of a project, with the fields of the
+// API representation. Every change of a project is recorded with it, whatever
+// its source, so that the entries can be compared.
+func ProjectSnapshot(p types.Project) map[string]any {
+	port, _ := strconv.Atoi(p.Port)
+
+	tags := p.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return map[string]any{
+		"id":             p.ID,
+		"name":           p.Name,
+		"host":           p.Host,
+		"port":           port,
+		"type":           p.Type,
+		"allow_insecure": p.AllowInsecure,
+		"tags":           tags,
+		"team":           p.Team,
+		"managed_by":     p.ManagedBy,
+	}
+}
+
+// UserSnapshot is the audited state of a user, without its password hash.
+func UserSnapshot(u types.User) map[string]any {
+	return map[string]any{
+		"username": u.Username,
+		"role":     u.Role,
+		"provider": u.Provider,
+		"teams":    u.Teams,
+	}
+}
+
+// APIKeySnapshot is the audited state of an API key, without its hash.
+func APIKeySnapshot(k types.APIKey) map[string]any {
+	return map[string]any{
+		"name":       k.Name,
+		"prefix":     k.Prefix,
+		"scopes":     k.Scopes,
+		"teams":      k.Teams,
+		"expires_at": k.ExpiresAt,
+	}
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"slices"
+
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// Access is the set of projects a user or an API key can see and manage: the
+// projects of its teams and the shared projects, which have no team.
+type Access struct {
+	// All grants every project, whatever its team.
+	All   bool
+	Teams []string
+}
+
+// CanAccessTeam reports whether the projects of the team are accessible.
+func (a Access) CanAccessTeam(team string) bool {
+	return a.All || team == "" || slices.Contains(a.Teams, team)
+}
+
+// CanAccess reports whether the project is accessible.
+func (a Access) CanAccess(project types.Project) bool {
+	return a.CanAccessTeam(project.Team)
+}
+
+// CanMove reports whether the projects can be moved from a team to another:
+// the access restricted to teams could otherwise share its projects, or give
+// them to another team.
+func (a Access) CanMove(from, to string) bool {
+	return from == to || a.All
+}
+
+// CanGrant reports whether the API keys of the teams can be listed, created
+// or revoked: the access restricted to teams could otherwise create a key
+// granted every project, or the projects of another team.
+func (a Access) CanGrant(teams []string) bool {
+	if a.All {
+		return true
+	}
+
+	if len(teams) == 0 {
+		return false
+	}
+
+	for _, team := range teams {
+		if !slices.Contains(a.Teams, team) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FilterProjects returns the accessible projects.
+func (a Access) FilterProjects(projects []types.Project) []types.Project {
+	return slices.DeleteFunc(slices.Clone(projects), func(p types.Project) bool {
+		return !a.CanAccessTeam(p.Team)
+	})
+}
+
+// FilterSummaries returns the summaries of the accessible projects.
+func (a Access) FilterSummaries(summaries []types.ProjectCheckSummary) []types.ProjectCheckSummary {
+	return slices.DeleteFunc(slices.Clone(summaries), func(s types.ProjectCheckSummary) bool {
+		return !a.CanAccessTeam(s.Team)
+	})
+}
+
+// Access returns the projects accessible to the viewer: every project when
+// the authentication is disabled or for the admins, the projects of their
+// teams for the other users and only the shared projects for the anonymous
+// visitors.
+func (v Viewer) Access() Access {
+	if !v.AuthEnabled || (v.User != nil && v.User.Role == types.RoleAdmin) {
+		return Access{All: true}
+	}
+
+	if v.User == nil {
+		return Access{}
+	}
+
+	return Access{Teams: v.User.Teams}
+}
+
+// APIKeyAccess returns the projects accessible to the API key: the projects
+// of its teams, or every project when the key is not restricted to teams.
+func APIKeyAccess(key *types.APIKey) Access {
+	if len(key.Teams) == 0 {
+		return Access{All: true}
+	}
+
+	return Access{Teams: key.Teams}
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"leblanc.io/open-go-ssl-checker/internal/store"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// apiKeyPrefix starts every generated API key, to make them easy to spot in
+// configuration files and secret scanners.
+const apiKeyPrefix = "ogsc_"
+
+// Scopes lists the valid API key scopes.
+var Scopes = []string{types.ScopeRead, types.ScopeWrite, types.ScopeCheck, types.ScopeAdmin}
+
+type contextKey int
+
+const apiKeyContextKey contextKey = iota
+
+// GenerateAPIKey returns a new random API key, its public prefix and its hash.
+// The key itself is only shown once to the user: only the prefix and the hash
+// are stored.
+func GenerateAPIKey() (string, string, string, error) {
+	prefix := make([]byte, 6)
+	if _, err := rand.Read(prefix); err != nil {
+		return "", "", "", fmt.Errorf("error generating API key prefix: %w", err)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", "", fmt.Errorf("error generating API key secret: %w", err)
+	}
+
+	p := hex.EncodeToString(prefix)
+	key := apiKeyPrefix + p + "_" + base64.RawURLEncoding.EncodeToString(secret)
+
+	return key, p, HashToken(key), nil
+}
+
+// ParseAPIKeyPrefix returns the public prefix of an API key.
+func ParseAPIKeyPrefix(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, apiKeyPrefix)
+	if !ok {
+		return "", false
+	}
+
+	prefix, secret, ok := strings.Cut(rest, "_")
+	if !ok || prefix == "" || secret == "" {
+		return "", false
+	}
+
+	return prefix, true
+}
+
+// HashToken returns the hash stored for an API key or a session token. The
+// tokens are random 256 bits values, a plain SHA-256 is enough to protect them.
+func HashToken(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyAPIKey reports whether the key matches the stored API key and whether
+// the stored API key is still usable (neither revoked nor expired).
+func VerifyAPIKey(stored types.APIKey, key string, now time.Time) bool {
+	if subtle.ConstantTimeCompare([]byte(stored.Hash), []byte(HashToken(key))) != 1 {
+		return false
+	}
+
+	if stored.RevokedAt != nil {
+		return false
+	}
+
+	if stored.ExpiresAt != nil && !now.Before(*stored.ExpiresAt) {
+		return false
+	}
+
+	return true
+}
+
+// ConstantTimeEqual compares two secrets without leaking their content through timing.
+func ConstantTimeEqual(a string, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// HasScope reports whether the scopes grant the requested scope.
+func HasScope(scopes []string, scope string) bool {
+	return slices.Contains(scopes, types.ScopeAdmin) || slices.Contains(scopes, scope)
+}
+
+// ParseScopes parses a comma separated list of scopes.
+func ParseScopes(value string) ([]string, error) {
+	var scopes []string
+
+	for _, scope := range strings.Split(value, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope == "" {
+			continue
+		}
+
+		if !slices.Contains(Scopes, scope) {
+			return nil, fmt.Errorf("invalid scope %q, must be one of %s", scope, strings.Join(Scopes, ", "))
+		}
+
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("at least one scope is required (%s)", strings.Join(Scopes, ", "))
+	}
+
+	return scopes, nil
+}
+
+// CreateAPIKey generates a new API key, stores its hash and returns the stored
+// API key and the key to give to the user.
+func CreateAPIKey(
+	s store.APIKeyRepository,
+	name string,
+	scopes []string,
+	teams []string,
+	expiresAt *time.Time,
+) (*types.APIKey, string, error) {
+	key, prefix, hash, err := GenerateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	apiKey := types.APIKey{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Prefix:    prefix,
+		Hash:      hash,
+		Scopes:    scopes,
+		Teams:     teams,
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+
+	if err := s.AddAPIKey(apiKey); err != nil {
+		return nil, "", err
+	}
+
+	return &apiKey, key, nil
+}
+
+// WithAPIKey returns a copy of the context holding the authenticated API key.
+func WithAPIKey(ctx context.Context, key *types.APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey, key)
+}
+
+// APIKeyFromContext returns the authenticated API key, or nil.
+func APIKeyFromContext(ctx context.Context) *types.APIKey {
+	key, _ := ctx.Value(apiKeyContextKey).(*types.APIKey)
+
+	return key
+}
@@ -0,0 +1,446 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+	"leblanc.io/open-go-ssl-checker/internal/store"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// oidcFlowCookieName is the name of the cookie keeping the state of a login
+// between the redirection to the identity provider and the callback.
+const oidcFlowCookieName = "ogsc_oidc"
+
+const oidcFlowDuration = 10 * time.Minute
+
+var (
+	// ErrOIDCAccessDenied is returned when the groups of the user do not map to any role.
+	ErrOIDCAccessDenied = errors.New("no role granted by the groups of the user")
+	// ErrOIDCUsernameTaken is returned when another user already uses the
+	// username of a new user.
+	ErrOIDCUsernameTaken = errors.New("username already used by another user")
+	// ErrOIDCInvalidFlow is returned when the callback does not match a login started here.
+	ErrOIDCInvalidFlow = errors.New("invalid or expired login flow")
+)
+
+// OIDCConfig configures the OpenID Connect login.
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// UsernameClaim is the ID token claim used as username, "email" and
+	// "sub" are used when it is missing.
+	UsernameClaim string
+	// GroupsClaim is the ID token claim listing the groups of the user.
+	GroupsClaim  string
+	ViewerGroups []string
+	EditorGroups []string
+	AdminGroups  []string
+	// DefaultRole is given to the users without any mapped group. When
+	// empty, these users are denied.
+	DefaultRole string
+	// TeamGroupPrefix selects the groups giving the teams of the user: the
+	// group "<prefix>payments" gives the team "payments". No team is read
+	// from the groups when empty.
+	TeamGroupPrefix string
+}
+
+// OIDC logs the users in with an OpenID Connect provider, using the
+// authorization code flow with PKCE. The users are provisioned on their first
+// login and their role is updated from their groups on every login. They are
+// identified by the issuer and the subject of their ID token.
+type OIDC struct {
+	config OIDCConfig
+	users  store.UserRepository
+	secure bool
+
+	mu       sync.Mutex
+	oauth2   *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// oidcFlow is the state of a login, kept in a cookie.
+type oidcFlow struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Next     string `json:"next"`
+}
+
+func NewOIDC(config OIDCConfig, users store.UserRepository, secure bool) (*OIDC, error) {
+	if config.Issuer == "" || config.ClientID == "" || config.RedirectURL == "" {
+		return nil, fmt.Errorf("OIDC issuer, client_id and redirect_url are required")
+	}
+
+	if config.DefaultRole != "" && types.RoleRank(config.DefaultRole) == 0 {
+		return nil, fmt.Errorf("invalid OIDC default role %q", config.DefaultRole)
+	}
+
+	return &OIDC{config: config, users: users, secure: secure}, nil
+}
+
+// provider discovers the identity provider on the first login, so the
+// application starts even when the provider is not reachable yet.
+func (o *OIDC) provider(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.oauth2 != nil {
+		return o.oauth2, o.verifier, nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, o.config.Issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error discovering OIDC provider %s: %w", o.config.Issuer, err)
+	}
+
+	scopes := o.config.Scopes
+	if !slices.Contains(scopes, oidc.ScopeOpenID) {
+		scopes = append([]string{oidc.ScopeOpenID}, scopes...)
+	}
+
+	o.oauth2 = &oauth2.Config{
+		ClientID:     o.config.ClientID,
+		ClientSecret: o.config.ClientSecret,
+		RedirectURL:  o.config.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       scopes,
+	}
+	o.verifier = provider.Verifier(&oidc.Config{ClientID: o.config.ClientID})
+
+	return o.oauth2, o.verifier, nil
+}
+
+// AuthCodeURL starts a login: it keeps its state in a cookie and returns the
+// URL of the identity provider to redirect the user to.
+func (o *OIDC) AuthCodeURL(ctx context.Context, w http.ResponseWriter, next string) (string, error) {
+	config, _, err := o.provider(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	nonce, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	flow := oidcFlow{State: state, Nonce: nonce, Verifier: oauth2.GenerateVerifier(), Next: next}
+
+	value, err := json.Marshal(flow)
+	if err != nil {
+		return "", fmt.Errorf("error encoding OIDC flow: %w", err)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookieName,
+		Value:    base64.RawURLEncoding.EncodeToString(value),
+		Path:     "/",
+		MaxAge:   int(oidcFlowDuration.Seconds()),
+		HttpOnly: true,
+		Secure:   o.secure,
+		// Lax, so the cookie is sent on the redirection back from the provider
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return config.AuthCodeURL(
+		state,
+		oauth2.S256ChallengeOption(flow.Verifier),
+		oidc.Nonce(nonce),
+	), nil
+}
+
+// Callback completes a login: it exchanges the authorization code, verifies
+// the ID token and returns the provisioned user and the page to go back to.
+func (o *OIDC) Callback(w http.ResponseWriter, r *http.Request) (*types.User, string, error) {
+	flow, err := readOIDCFlow(r)
+
+	// The flow cookie is only valid once
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   o.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	if r.URL.Query().Get("state") != flow.State {
+		return nil, "", ErrOIDCInvalidFlow
+	}
+
+	if errCode := r.URL.Query().Get("error"); errCode != "" {
+		return nil, "", fmt.Errorf("OIDC provider error: %s %s", errCode, r.URL.Query().Get("error_description"))
+	}
+
+	config, verifier, err := o.provider(r.Context())
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := config.Exchange(r.Context(), r.URL.Query().Get("code"), oauth2.VerifierOption(flow.Verifier))
+	if err != nil {
+		return nil, "", fmt.Errorf("error exchanging OIDC authorization code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, "", fmt.Errorf("OIDC token response without id_token")
+	}
+
+	idToken, err := verifier.Verify(r.Context(), rawIDToken)
+	if err != nil {
+		return nil, "", fmt.Errorf("error verifying OIDC ID token: %w", err)
+	}
+
+	if idToken.Nonce != flow.Nonce {
+		return nil, "", ErrOIDCInvalidFlow
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, "", fmt.Errorf("error decoding OIDC claims: %w", err)
+	}
+
+	user, err := o.provision(idToken.Issuer, idToken.Subject, claims)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return user, flow.Next, nil
+}
+
+// RoleForGroups returns the highest role granted by the groups, or the
+// default role when no group is mapped.
+func (o *OIDC) RoleForGroups(groups []string) string {
+	mapping := []struct {
+		role   string
+		groups []string
+	}{
+		{types.RoleAdmin, o.config.AdminGroups},
+		{types.RoleEditor, o.config.EditorGroups},
+		{types.RoleViewer, o.config.ViewerGroups},
+	}
+
+	for _, m := range mapping {
+		for _, group := range groups {
+			if slices.Contains(m.groups, group) {
+				return m.role
+			}
+		}
+	}
+
+	return o.config.DefaultRole
+}
+
+// provision creates the user on its first login, or updates its role, its
+// teams and its username. The users are matched on their subject, never on
+// their username: a user renamed at the provider, or given the email of a
+// former user, cannot take over another account.
+func (o *OIDC) provision(issuer string, subject string, claims map[string]any) (*types.User, error) {
+	if subject == "" {
+		return nil, fmt.Errorf("OIDC ID token without subject")
+	}
+
+	username := stringClaim(claims, o.config.UsernameClaim, "email", "sub")
+	if username == "" {
+		return nil, fmt.Errorf("OIDC ID token without username")
+	}
+
+	groups := groupsClaim(claims[o.config.GroupsClaim])
+
+	role := o.RoleForGroups(groups)
+	if role == "" {
+		return nil, fmt.Errorf("user %s: %w", username, ErrOIDCAccessDenied)
+	}
+
+	teams := o.TeamsForGroups(groups)
+
+	user, err := o.users.GetUserBySubject(issuer, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return o.addUser(issuer, subject, username, role, teams)
+	}
+
+	if user.Username != username {
+		// The username is kept when another user already uses the new one
+		if err := o.users.UpdateUsername(user.ID, username); err == nil {
+			user.Username = username
+		} else if !errors.Is(err, store.ErrDuplicateUsername) {
+			return nil, err
+		}
+	}
+
+	if err := o.updateAccess(user, role, teams); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// updateAccess updates the role and the teams of the user from its groups.
+func (o *OIDC) updateAccess(user *types.User, role string, teams []string) error {
+	if user.Role != role {
+		if err := o.users.UpdateUserRole(user.ID, role); err != nil {
+			return err
+		}
+
+		user.Role = role
+	}
+
+	if !slices.Equal(user.Teams, teams) {
+		if err := o.users.UpdateUserTeams(user.ID, teams); err != nil {
+			return err
+		}
+
+		user.Teams = teams
+	}
+
+	return nil
+}
+
+// addUser provisions the user on its first login. The users provisioned
+// before the subjects were stored are linked to their subject instead.
+func (o *OIDC) addUser(issuer, subject, username, role string, teams []string) (*types.User, error) {
+	user, err := o.users.GetUserByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+
+	if user != nil {
+		if user.Provider != types.ProviderOIDC || user.Subject != "" {
+			return nil, fmt.Errorf("user %s: %w", username, ErrOIDCUsernameTaken)
+		}
+
+		if err := o.users.UpdateUserSubject(user.ID, issuer, subject); err != nil {
+			return nil, err
+		}
+
+		user.Issuer, user.Subject = issuer, subject
+
+		if err := o.updateAccess(user, role, teams); err != nil {
+			return nil, err
+		}
+
+		return user, nil
+	}
+
+	user = &types.User{
+		ID:        uuid.New().String(),
+		Username:  username,
+		Role:      role,
+		Provider:  types.ProviderOIDC,
+		Teams:     teams,
+		CreatedAt: time.Now(),
+		Issuer:    issuer,
+		Subject:   subject,
+	}
+
+	if err := o.users.AddUser(*user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// TeamsForGroups returns the teams given by the groups starting with the
+// team group prefix.
+func (o *OIDC) TeamsForGroups(groups []string) []string {
+	if o.config.TeamGroupPrefix == "" {
+		return nil
+	}
+
+	var teams []string
+
+	for _, group := range groups {
+		if team, ok := strings.CutPrefix(group, o.config.TeamGroupPrefix); ok && team != "" {
+			teams = append(teams, team)
+		}
+	}
+
+	return teams
+}
+
+func readOIDCFlow(r *http.Request) (*oidcFlow, error) {
+	cookie, err := r.Cookie(oidcFlowCookieName)
+	if err != nil {
+		return nil, ErrOIDCInvalidFlow
+	}
+
+	value, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return nil, ErrOIDCInvalidFlow
+	}
+
+	var flow oidcFlow
+	if err := json.Unmarshal(value, &flow); err != nil || flow.State == "" {
+		return nil, ErrOIDCInvalidFlow
+	}
+
+	return &flow, nil
+}
+
+// stringClaim returns the first non empty string claim among the names.
+func stringClaim(claims map[string]any, names ...string) string {
+	for _, name := range names {
+		if value, ok := claims[name].(string); ok && value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// groupsClaim reads the groups claim, given as a list or a single string.
+func groupsClaim(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		groups := make([]string, 0, len(v))
+		for _, g := range v {
+			if s, ok := g.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+
+		return groups
+	default:
+		return nil
+	}
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating random token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
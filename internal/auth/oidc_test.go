@@ -0,0 +1,428 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/store"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+const (
+	testClientID     = "ogsc"
+	testClientSecret = "secret"
+	testRedirectURL  = "http://127.0.0.1:4332/auth/oidc/callback"
+)
+
+// mockProvider is a local OpenID Connect provider serving the discovery, the
+// JWKS and the token endpoints. The authorization endpoint is not served: the
+// tests issue the codes as if the user logged in.
+type mockProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]mockCode
+}
+
+// mockCode is an authorization code issued for the parameters of the login.
+type mockCode struct {
+	challenge string
+	nonce     string
+	claims    map[string]any
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+
+	p := &mockProvider{key: key, codes: make(map[string]mockCode)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /keys", p.jwks)
+	mux.HandleFunc("POST /token", p.token)
+
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	return p
+}
+
+func (p *mockProvider) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeTestJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.server.URL,
+		"authorization_endpoint":                p.server.URL + "/authorize",
+		"token_endpoint":                        p.server.URL + "/token",
+		"jwks_uri":                              p.server.URL + "/keys",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *mockProvider) jwks(w http.ResponseWriter, _ *http.Request) {
+	writeTestJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "test",
+		"alg": "RS256",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+	}}})
+}
+
+// token exchanges a code, checking the client, the redirect URL and the PKCE
+// verifier as a real provider.
+func (p *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	if clientID != testClientID || clientSecret != testClientSecret {
+		writeTestJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+
+		return
+	}
+
+	p.mu.Lock()
+	code, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" ||
+		r.PostForm.Get("redirect_uri") != testRedirectURL ||
+		base64.RawURLEncoding.EncodeToString(verifier[:]) != code.challenge {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+
+		return
+	}
+
+	claims := map[string]any{
+		"iss":   p.server.URL,
+		"aud":   testClientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": code.nonce,
+	}
+	for name, value := range code.claims {
+		claims[name] = value
+	}
+
+	writeTestJSON(w, http.StatusOK, map[string]any{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     p.sign(claims),
+	})
+}
+
+func (p *mockProvider) sign(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// authorize logs the user in: it issues a code for the login started by the
+// authorization URL, with the ID token claims of the user.
+func (p *mockProvider) authorize(t *testing.T, authURL string, claims map[string]any) string {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("error parsing authorization URL: %v", err)
+	}
+
+	query := u.Query()
+	if query.Get("client_id") != testClientID || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected authorization URL %s", authURL)
+	}
+
+	code := base64.RawURLEncoding.EncodeToString(big.NewInt(time.Now().UnixNano()).Bytes())
+
+	p.mu.Lock()
+	p.codes[code] = mockCode{challenge: query.Get("code_challenge"), nonce: query.Get("nonce"), claims: claims}
+	p.mu.Unlock()
+
+	return code
+}
+
+func writeTestJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func newTestOIDC(t *testing.T, p *mockProvider, users store.UserRepository) *OIDC {
+	t.Helper()
+
+	o, err := NewOIDC(OIDCConfig{
+		Issuer:          p.server.URL,
+		ClientID:        testClientID,
+		ClientSecret:    testClientSecret,
+		RedirectURL:     testRedirectURL,
+		UsernameClaim:   "preferred_username",
+		GroupsClaim:     "groups",
+		ViewerGroups:    []string{"staff"},
+		EditorGroups:    []string{"devs"},
+		AdminGroups:     []string{"admins"},
+		TeamGroupPrefix: "team-",
+	}, users, false)
+	if err != nil {
+		t.Fatalf("NewOIDC() error = %v", err)
+	}
+
+	return o
+}
+
+// login runs a whole login, edit changes the callback request before it is
+// handled.
+func login(
+	t *testing.T,
+	p *mockProvider,
+	o *OIDC,
+	claims map[string]any,
+	edit func(callback url.Values),
+) (*types.User, string, error) {
+	t.Helper()
+
+	start := httptest.NewRecorder()
+
+	authURL, err := o.AuthCodeURL(context.Background(), start, "/projects")
+	if err != nil {
+		t.Fatalf("AuthCodeURL() error = %v", err)
+	}
+
+	u, _ := url.Parse(authURL)
+	callback := url.Values{
+		"code":  {p.authorize(t, authURL, claims)},
+		"state": {u.Query().Get("state")},
+	}
+
+	if edit != nil {
+		edit(callback)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?"+callback.Encode(), nil)
+	for _, cookie := range start.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+
+	return o.Callback(httptest.NewRecorder(), req)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	p := newMockProvider(t)
+	users := store.NewMemoryStore()
+	o := newTestOIDC(t, p, users)
+
+	user, next, err := login(t, p, o, map[string]any{
+		"sub":                "alice-id",
+		"preferred_username": "alice",
+		"groups":             []string{"staff", "admins", "team-payments"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Callback() error = %v", err)
+	}
+
+	if next != "/projects" {
+		t.Errorf("next = %q, want /projects", next)
+	}
+
+	if user.Username != "alice" || user.Role != types.RoleAdmin || user.Provider != types.ProviderOIDC {
+		t.Errorf("user = %+v, want the admin alice", user)
+	}
+
+	if len(user.Teams) != 1 || user.Teams[0] != "payments" {
+		t.Errorf("teams = %v, want [payments]", user.Teams)
+	}
+
+	stored, _ := users.GetUserBySubject(p.server.URL, "alice-id")
+	if stored == nil || stored.ID != user.ID {
+		t.Fatalf("user not stored with its subject: %+v", stored)
+	}
+
+	// The groups changed at the provider
+	user, _, err = login(t, p, o, map[string]any{
+		"sub":                "alice-id",
+		"preferred_username": "alice",
+		"groups":             "devs",
+	}, nil)
+	if err != nil {
+		t.Fatalf("Callback() error = %v", err)
+	}
+
+	if user.ID != stored.ID || user.Role != types.RoleEditor || len(user.Teams) != 0 {
+		t.Errorf("user = %+v, want alice as editor without team", user)
+	}
+}
+
+func TestOIDCLoginFlowChecks(t *testing.T) {
+	p := newMockProvider(t)
+	o := newTestOIDC(t, p, store.NewMemoryStore())
+	claims := map[string]any{"sub": "alice-id", "preferred_username": "alice", "groups": "staff"}
+
+	tests := []struct {
+		name string
+		edit func(callback url.Values)
+	}{
+		{"state", func(callback url.Values) { callback.Set("state", "forged") }},
+		{"PKCE verifier", func(callback url.Values) {
+			// A code issued to another login, with another code challenge
+			other := httptest.NewRecorder()
+			authURL, _ := o.AuthCodeURL(context.Background(), other, "/")
+			callback.Set("code", p.authorize(t, authURL, claims))
+		}},
+		{"provider error", func(callback url.Values) {
+			callback.Del("code")
+			callback.Set("error", "access_denied")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if user, _, err := login(t, p, o, claims, tt.edit); err == nil {
+				t.Fatalf("Callback() = %+v, want an error", user)
+			}
+		})
+	}
+
+	t.Run("nonce", func(t *testing.T) {
+		// The nonce of the ID token does not match the nonce of the login
+		_, _, err := login(t, p, o, map[string]any{"sub": "alice-id", "groups": "staff", "nonce": "replayed"}, nil)
+		if !errors.Is(err, ErrOIDCInvalidFlow) {
+			t.Fatalf("Callback() error = %v, want ErrOIDCInvalidFlow", err)
+		}
+	})
+
+	t.Run("missing flow cookie", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?code=x&state=y", nil)
+		if _, _, err := o.Callback(httptest.NewRecorder(), req); !errors.Is(err, ErrOIDCInvalidFlow) {
+			t.Fatalf("Callback() error = %v, want ErrOIDCInvalidFlow", err)
+		}
+	})
+}
+
+func TestOIDCLoginDeniedWithoutRole(t *testing.T) {
+	p := newMockProvider(t)
+	users := store.NewMemoryStore()
+	o := newTestOIDC(t, p, users)
+
+	_, _, err := login(t, p, o, map[string]any{
+		"sub":                "bob-id",
+		"preferred_username": "bob",
+		"groups":             []string{"team-payments", "others"},
+	}, nil)
+	if !errors.Is(err, ErrOIDCAccessDenied) {
+		t.Fatalf("Callback() error = %v, want ErrOIDCAccessDenied", err)
+	}
+
+	if all, _ := users.ListUsers(); len(all) != 0 {
+		t.Errorf("users = %+v, want none provisioned", all)
+	}
+}
+
+func TestOIDCLoginMatchesSubject(t *testing.T) {
+	p := newMockProvider(t)
+	users := store.NewMemoryStore()
+	o := newTestOIDC(t, p, users)
+
+	alice, _, err := login(t, p, o, map[string]any{
+		"sub": "alice-id", "preferred_username": "alice@example.com", "groups": "admins",
+	}, nil)
+	if err != nil {
+		t.Fatalf("Callback() error = %v", err)
+	}
+
+	// Another person given the former email of alice does not get her account
+	_, _, err = login(t, p, o, map[string]any{
+		"sub": "mallory-id", "preferred_username": "alice@example.com", "groups": "staff",
+	}, nil)
+	if !errors.Is(err, ErrOIDCUsernameTaken) {
+		t.Fatalf("Callback() error = %v, want ErrOIDCUsernameTaken", err)
+	}
+
+	// Renamed at the provider, alice keeps her account under the new name
+	renamed, _, err := login(t, p, o, map[string]any{
+		"sub": "alice-id", "preferred_username": "alice.martin@example.com", "groups": "admins",
+	}, nil)
+	if err != nil {
+		t.Fatalf("Callback() error = %v", err)
+	}
+
+	if renamed.ID != alice.ID || renamed.Username != "alice.martin@example.com" {
+		t.Errorf("user = %+v, want the account of alice renamed", renamed)
+	}
+
+	// A local user keeps its username
+	if err := users.AddUser(types.User{ID: "local", Username: "admin", Provider: types.ProviderLocal}); err != nil {
+		t.Fatalf("AddUser() error = %v", err)
+	}
+
+	_, _, err = login(t, p, o, map[string]any{"sub": "other-id", "preferred_username": "admin", "groups": "staff"}, nil)
+	if !errors.Is(err, ErrOIDCUsernameTaken) {
+		t.Fatalf("Callback() error = %v, want ErrOIDCUsernameTaken", err)
+	}
+
+	renamed, _, err = login(t, p, o, map[string]any{
+		"sub": "alice-id", "preferred_username": "admin", "groups": "admins",
+	}, nil)
+	if err != nil || renamed.Username != "alice.martin@example.com" {
+		t.Errorf("user = %+v, %v, want alice keeping her username", renamed, err)
+	}
+}
+
+func TestOIDCLinksLegacyUser(t *testing.T) {
+	p := newMockProvider(t)
+	users := store.NewMemoryStore()
+	o := newTestOIDC(t, p, users)
+
+	// Provisioned before the subjects were stored
+	if err := users.AddUser(types.User{ID: "legacy", Username: "carol", Provider: types.ProviderOIDC}); err != nil {
+		t.Fatalf("AddUser() error = %v", err)
+	}
+
+	user, _, err := login(t, p, o, map[string]any{"sub": "carol-id", "preferred_username": "carol", "groups": "staff"}, nil)
+	if err != nil {
+		t.Fatalf("Callback() error = %v", err)
+	}
+
+	if user.ID != "legacy" || user.Subject != "carol-id" || !strings.HasPrefix(user.Issuer, "http://127.0.0.1") {
+		t.Errorf("user = %+v, want the legacy user linked to its subject", user)
+	}
+}
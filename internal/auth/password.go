@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPasswordLength is the minimal length of the web UI passwords.
+const MinPasswordLength = 8
+
+// dummyPasswordHash is compared against when the user does not exist, so a
+// failed login takes the same time whether the username exists or not.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("open-go-ssl-checker"), bcrypt.DefaultCost)
+
+// HashPassword returns the bcrypt hash of the password.
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters long", MinPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("error hashing password: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+// CheckPassword reports whether the password matches the bcrypt hash. An
+// empty hash (unknown user) never matches.
+func CheckPassword(hash string, password string) bool {
+	if hash == "" {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+
+		return false
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
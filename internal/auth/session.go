@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"leblanc.io/open-go-ssl-checker/internal/store"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// SessionCookieName is the name of the cookie holding the session token.
+const SessionCookieName = "ogsc_session"
+
+const viewerContextKey contextKey = iota + 1
+
+// Roles lists the valid web UI roles.
+var Roles = []string{types.RoleViewer, types.RoleEditor, types.RoleAdmin}
+
+// Viewer is the visitor of the web UI: a logged in user, or an anonymous
+// visitor when User is nil.
+type Viewer struct {
+	User *types.User
+	// AuthEnabled is false when the web UI authentication is disabled: every
+	// visitor can then do everything.
+	AuthEnabled bool
+}
+
+// HasRole reports whether the viewer has the role, or a role granting more rights.
+func (v Viewer) HasRole(role string) bool {
+	if !v.AuthEnabled {
+		return true
+	}
+
+	return v.User != nil && types.RoleRank(v.User.Role) >= types.RoleRank(role)
+}
+
+// CanEdit reports whether the viewer can change the projects and trigger checks.
+func (v Viewer) CanEdit() bool {
+	return v.HasRole(types.RoleEditor)
+}
+
+// WithViewer returns a copy of the context holding the viewer.
+func WithViewer(ctx context.Context, viewer Viewer) context.Context {
+	return context.WithValue(ctx, viewerContextKey, viewer)
+}
+
+// ViewerFromContext returns the viewer of the request. Without viewer in the
+// context, an anonymous viewer with authentication enabled is returned.
+func ViewerFromContext(ctx context.Context) Viewer {
+	viewer, ok := ctx.Value(viewerContextKey).(Viewer)
+	if !ok {
+		return Viewer{AuthEnabled: true}
+	}
+
+	return viewer
+}
+
+// CreateUser hashes the password and stores a new local user.
+func CreateUser(
+	s store.UserRepository,
+	username string,
+	password string,
+	role string,
+	teams []string,
+) (*types.User, error) {
+	if username == "" {
+		return nil, fmt.Errorf("username is required")
+	}
+
+	if types.RoleRank(role) == 0 {
+		return nil, fmt.Errorf("invalid role %q, must be one of %s", role, strings.Join(Roles, ", "))
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := types.User{
+		ID:           uuid.New().String(),
+		Username:     username,
+		PasswordHash: hash,
+		Role:         role,
+		Provider:     types.ProviderLocal,
+		Teams:        teams,
+		CreatedAt:    time.Now(),
+	}
+
+	if err := s.AddUser(user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// SessionManager creates and resolves the web UI sessions.
+type SessionManager struct {
+	users    store.UserRepository
+	sessions store.SessionRepository
+	ttl      time.Duration
+	secure   bool
+}
+
+func NewSessionManager(
+	users store.UserRepository,
+	sessions store.SessionRepository,
+	ttl time.Duration,
+	secure bool,
+) *SessionManager {
+	return &SessionManager{users: users, sessions: sessions, ttl: ttl, secure: secure}
+}
+
+// Login checks the credentials and, when they are valid, opens a session and
+// sets its cookie. It returns nil when the credentials are invalid.
+func (m *SessionManager) Login(
+	w http.ResponseWriter,
+	username string,
+	password string,
+) (*types.User, error) {
+	user, err := m.users.GetUserByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := ""
+	if user != nil && user.Provider == types.ProviderLocal {
+		hash = user.PasswordHash
+	}
+
+	if !CheckPassword(hash, password) {
+		return nil, nil
+	}
+
+	if err := m.StartSession(w, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// StartSession opens a session for the user and sets its cookie.
+func (m *SessionManager) StartSession(w http.ResponseWriter, user *types.User) error {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return fmt.Errorf("error generating session token: %w", err)
+	}
+
+	now := time.Now()
+	value := base64.RawURLEncoding.EncodeToString(token)
+
+	// Opportunistic cleanup, sessions are only created on login
+	if err := m.sessions.DeleteExpiredSessions(now); err != nil {
+		return err
+	}
+
+	err := m.sessions.AddSession(types.Session{
+		TokenHash: HashToken(value),
+		UserID:    user.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(m.ttl),
+	})
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    value,
+		Path:     "/",
+		Expires:  now.Add(m.ttl),
+		HttpOnly: true,
+		Secure:   m.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return nil
+}
+
+// User returns the user of the session of the request, or nil when the
+// request has no valid session.
+func (m *SessionManager) User(r *http.Request) (*types.User, error) {
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return nil, nil
+	}
+
+	session, err := m.sessions.GetSession(HashToken(cookie.Value))
+	if err != nil || session == nil {
+		return nil, err
+	}
+
+	if !time.Now().Before(session.ExpiresAt) {
+		return nil, nil
+	}
+
+	return m.users.GetUser(session.UserID)
+}
+
+// Logout closes the session of the request and clears its cookie.
+func (m *SessionManager) Logout(w http.ResponseWriter, r *http.Request) error {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   m.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+
+	return m.sessions.DeleteSession(HashToken(cookie.Value))
+}
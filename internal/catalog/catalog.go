@@ -39,89 +39,227 @@ func init() {
 }
 
 var messageKeyToIndex = map[string]int{
-	"actions":                24,
-	"add_new_project":        0,
-	"add_project":            10,
-	"allow_insecure":         8,
-	"allow_insecure_warning": 9,
-	"check_time":             23,
-	"confirm_delete":         30,
-	"custom":                 6,
-	"dashboard":              19,
-	"days_remaining":         17,
-	"delete":                 31,
-	"domains":                13,
-	"expired":                26,
-	"expiry_date":            16,
-	"failed":                 18,
-	"history":                27,
-	"host":                   2,
-	"host_port":              21,
-	"ip":                     14,
-	"issuer":                 15,
-	"never_checked":          25,
-	"no_history_found":       11,
-	"no_projects":            20,
-	"port":                   7,
-	"project_name":           1,
-	"project_type":           22,
-	"projects":               29,
-	"refresh_datas":          28,
-	"service_type":           3,
-	"verification_date":      12,
-	"with_auth_tls":          4,
-	"with_starttls":          5,
+	"action":                 7,
+	"actions":                27,
+	"actor":                  1,
+	"add_new_project":        60,
+	"add_project":            86,
+	"after":                  12,
+	"all_actions":            2,
+	"allow_insecure":         83,
+	"allow_insecure_warning": 84,
+	"approve":                29,
+	"audit_log":              0,
+	"automatic":              56,
+	"before":                 11,
+	"candidate_all":          17,
+	"changes":                9,
+	"check_time":             61,
+	"confirm_delete":         91,
+	"custom":                 77,
+	"dashboard":              58,
+	"date":                   6,
+	"days_remaining":         35,
+	"delete":                 92,
+	"discovered_help":        16,
+	"discovered_hosts":       15,
+	"domain":                 21,
+	"domains":                33,
+	"edit":                   90,
+	"edit_project":           72,
+	"expired":                63,
+	"expiry_date":            24,
+	"export_history":         37,
+	"export_projects":        93,
+	"failed":                 36,
+	"file":                   52,
+	"filter":                 4,
+	"first_seen":             25,
+	"format":                 55,
+	"history":                28,
+	"host":                   73,
+	"host_port":              19,
+	"ignore":                 30,
+	"import_applied":         39,
+	"import_apply":           49,
+	"import_changed":         41,
+	"import_content":         53,
+	"import_duplicates":      46,
+	"import_file":            51,
+	"import_fix_invalid":     48,
+	"import_help":            54,
+	"import_invalid":         47,
+	"import_new":             40,
+	"import_nothing":         50,
+	"import_preview":         43,
+	"import_preview_button":  57,
+	"import_projects":        38,
+	"insecure":               45,
+	"invalid_credentials":    68,
+	"ip":                     34,
+	"issuer":                 23,
+	"last_seen":              26,
+	"logged_in_as":           65,
+	"login":                  67,
+	"login_sso":              71,
+	"logout":                 66,
+	"managed_by":             89,
+	"managed_help":           88,
+	"never_checked":          62,
+	"newer":                  13,
+	"no_audit_entries":       5,
+	"no_candidates":          18,
+	"no_history_found":       31,
+	"no_projects":            59,
+	"older":                  14,
+	"password":               70,
+	"port":                   78,
+	"project_name":           44,
+	"project_type":           20,
+	"projects":               42,
+	"refresh_datas":          64,
+	"save":                   85,
+	"service_type":           74,
+	"shared":                 87,
+	"show":                   10,
+	"source":                 22,
+	"tags":                   79,
+	"tags_help":              80,
+	"target":                 8,
+	"target_id":              3,
+	"team":                   81,
+	"team_help":              82,
+	"username":               69,
+	"verification_date":      32,
+	"with_auth_tls":          75,
+	"with_starttls":          76,
 }
 
-var enIndex = []uint32{ // 33 elements
+var enIndex = []uint32{ // 95 elements
 	// Entry 0 - 1F
-	0x00000000, 0x00000010, 0x0000001d, 0x00000022,
-	0x0000002f, 0x0000003f, 0x0000004f, 0x00000056,
-	0x0000005b, 0x00000077, 0x000000db, 0x000000e7,
-	0x000000f8, 0x0000010a, 0x00000112, 0x00000115,
-	0x0000011c, 0x00000128, 0x00000137, 0x0000013e,
-	0x00000148, 0x00000153, 0x0000015d, 0x0000016a,
-	0x00000175, 0x0000017d, 0x0000018b, 0x00000193,
-	0x0000019b, 0x000001a9, 0x000001b2, 0x000001e0,
+	0x00000000, 0x0000000a, 0x00000010, 0x0000001c,
+	0x0000002e, 0x00000035, 0x00000049, 0x0000004e,
+	0x00000055, 0x0000005c, 0x00000064, 0x00000069,
+	0x00000070, 0x00000076, 0x0000007c, 0x00000082,
+	0x00000093, 0x00000119, 0x0000011d, 0x00000131,
+	0x0000013b, 0x00000148, 0x0000014f, 0x00000156,
+	0x0000015d, 0x00000169, 0x00000174, 0x0000017e,
+	0x00000186, 0x0000018e, 0x00000196, 0x0000019d,
 	// Entry 20 - 3F
-	0x000001e7,
-} // Size: 156 bytes
+	0x000001ae, 0x000001c0, 0x000001c8, 0x000001cb,
+	0x000001da, 0x000001e1, 0x000001fa, 0x0000020a,
+	0x00000227, 0x00000234, 0x00000245, 0x0000024e,
+	0x00000256, 0x00000263, 0x0000026c, 0x00000280,
+	0x00000290, 0x000002e3, 0x000002f4, 0x00000307,
+	0x00000316, 0x0000031b, 0x00000330, 0x000003d4,
+	0x000003db, 0x000003e5, 0x000003f8, 0x00000402,
+	0x0000040d, 0x0000041d, 0x00000428, 0x00000436,
+	// Entry 40 - 5F
+	0x0000043e, 0x0000044c, 0x00000459, 0x00000461,
+	0x00000468, 0x00000486, 0x0000048f, 0x00000498,
+	0x000004a8, 0x000004b5, 0x000004ba, 0x000004c7,
+	0x000004d7, 0x000004e7, 0x000004ee, 0x000004f3,
+	0x000004f8, 0x0000052d, 0x00000532, 0x0000059d,
+	0x000005b9, 0x0000061d, 0x00000622, 0x0000062e,
+	0x00000635, 0x00000680, 0x0000068b, 0x00000690,
+	0x000006be, 0x000006c5, 0x000006d9,
+} // Size: 404 bytes
 
-const enData string = "" + // Size: 487 bytes
-	"\x02Add new project\x02Project name\x02Host\x02Service type\x02(with aut" +
-	"h TLS)\x02(with STARTTLS)\x02Custom\x02Port\x02Allow insecure certificat" +
-	"es\x02Allow insecure certificates must be used for self-signed or invali" +
-	"d certificates. Use with caution.\x02Add project\x02No history found\x02" +
-	"Verification date\x02Domains\x02IP\x02Issuer\x02Expiry date\x02Days rema" +
-	"ining\x02Failed\x02Dashboard\x02No project\x02Host:Port\x02Project type" +
-	"\x02Check time\x02Actions\x02Never checked\x02expired\x02History\x02Refr" +
-	"esh datas\x02Projects\x02Are you sure you want to delete this project?" +
-	"\x02Delete"
+const enData string = "" + // Size: 1753 bytes
+	"\x02Audit log\x02Actor\x02All actions\x02Target identifier\x02Filter\x02" +
+	"No change recorded.\x02Date\x02Action\x02Target\x02Changes\x02Show\x02Be" +
+	"fore\x02After\x02Newer\x02Older\x02Discovered hosts\x02Endpoints of your" +
+	" domains found in the Certificate Transparency logs or in your DNS zones" +
+	". Approve them to monitor their certificate.\x02All\x02No discovered hos" +
+	"t.\x02Host:Port\x02Project type\x02Domain\x02Source\x02Issuer\x02Expiry " +
+	"date\x02First seen\x02Last seen\x02Actions\x02History\x02Approve\x02Igno" +
+	"re\x02No history found\x02Verification date\x02Domains\x02IP\x02Days rem" +
+	"aining\x02Failed\x02Export the check history\x02Import projects\x02The i" +
+	"mport has been applied.\x02New projects\x02Changed projects\x02Projects" +
+	"\x02Preview\x02Project name\x02insecure\x02Duplicates, ignored\x02Invali" +
+	"d entries\x02Fix the invalid entries to apply the import: it is applied " +
+	"entirely or not at all.\x02Apply the import\x02Nothing to import.\x02Fil" +
+	"e to import\x02File\x02Or paste the content\x02CSV with a header line, o" +
+	"r a JSON or YAML list of projects with the name, host, port, type, allow" +
+	"_insecure, tags and team fields. The projects are matched by name.\x02Fo" +
+	"rmat\x02Automatic\x02Preview the import\x02Dashboard\x02No project\x02Ad" +
+	"d new project\x02Check time\x02Never checked\x02expired\x02Refresh datas" +
+	"\x02Logged in as\x02Log out\x02Log in\x02Invalid username or password." +
+	"\x02Username\x02Password\x02Log in with SSO\x02Edit project\x02Host\x02S" +
+	"ervice type\x02(with auth TLS)\x02(with STARTTLS)\x02Custom\x02Port\x02T" +
+	"ags\x02Comma separated list, used to filter the API results\x02Team\x02O" +
+	"nly the members of this team (and the admins) can see the project. Leave" +
+	" empty to share it with everyone.\x02Allow insecure certificates\x02Allo" +
+	"w insecure certificates must be used for self-signed or invalid certific" +
+	"ates. Use with caution.\x02Save\x02Add project\x02Shared\x02This project" +
+	" is declared outside the application: change it in its source.\x02Manage" +
+	"d by\x02Edit\x02Are you sure you want to delete this project?\x02Delete" +
+	"\x02Export the projects"
 
-var frIndex = []uint32{ // 33 elements
+var frIndex = []uint32{ // 95 elements
 	// Entry 0 - 1F
-	0x00000000, 0x0000001a, 0x00000028, 0x0000002e,
-	0x0000003e, 0x0000004e, 0x0000005e, 0x0000006c,
-	0x00000071, 0x0000009b, 0x000000ed, 0x000000ff,
-	0x00000118, 0x00000130, 0x00000139, 0x0000013c,
-	0x00000146, 0x00000150, 0x0000015f, 0x00000166,
-	0x00000176, 0x00000185, 0x00000190, 0x00000195,
-	0x000001ab, 0x000001b3, 0x000001c4, 0x000001cc,
-	0x000001d7, 0x000001f0, 0x00000202, 0x00000253,
+	0x00000000, 0x00000012, 0x00000019, 0x0000002c,
+	0x00000044, 0x0000004c, 0x0000006e, 0x00000073,
+	0x0000007a, 0x00000080, 0x0000008e, 0x00000097,
+	0x0000009d, 0x000000a4, 0x000000b2, 0x000000bf,
+	0x000000d2, 0x0000016c, 0x00000171, 0x00000189,
+	0x00000194, 0x00000199, 0x000001a1, 0x000001a8,
+	0x000001b2, 0x000001bc, 0x000001d1, 0x000001e6,
+	0x000001ee, 0x000001f9, 0x00000203, 0x0000020b,
 	// Entry 20 - 3F
-	0x0000025d,
-} // Size: 156 bytes
+	0x00000224, 0x0000023c, 0x00000245, 0x00000248,
+	0x00000257, 0x0000025e, 0x00000289, 0x0000029e,
+	0x000002bc, 0x000002cd, 0x000002df, 0x000002f1,
+	0x000002f9, 0x00000307, 0x00000316, 0x00000329,
+	0x0000033c, 0x000003a6, 0x000003bb, 0x000003cd,
+	0x000003e1, 0x000003e9, 0x000003fe, 0x000004ac,
+	0x000004b3, 0x000004bf, 0x000004d9, 0x000004e9,
+	0x000004f8, 0x00000512, 0x00000528, 0x00000539,
+	// Entry 40 - 5F
+	0x00000541, 0x0000055a, 0x00000570, 0x0000057d,
+	0x00000587, 0x000005b6, 0x000005ca, 0x000005d7,
+	0x000005ea, 0x000005fd, 0x00000603, 0x00000613,
+	0x00000623, 0x00000633, 0x00000641, 0x00000646,
+	0x00000652, 0x000006a3, 0x000006ab, 0x0000072b,
+	0x00000755, 0x000007a7, 0x000007b3, 0x000007c5,
+	0x000007ce, 0x0000081c, 0x00000827, 0x00000830,
+	0x00000881, 0x0000088b, 0x000008a0,
+} // Size: 404 bytes
 
-const frData string = "" + // Size: 605 bytes
-	"\x02Ajouter un nouveau projet\x02Nom du projet\x02Hôte\x02Type de servic" +
-	"e\x02(avec AUTH TLS)\x02(avec STARTTLS)\x02Personnalisé\x02Port\x02Autor" +
-	"iser les certificats non sécurisés\x02Pour les certificats auto-signés o" +
-	"u non sécurisés. À utiliser avec prudence !\x02Ajouter le projet\x02Aucu" +
-	"n historique trouvé\x02Dernière vérification\x02Domaines\x02IP\x02Émette" +
-	"ur\x02Expire le\x02Jours restants\x02Échec\x02Tableau de bord\x02Aucun p" +
-	"rojet !\x02Hôte:Port\x02Type\x02Date de vérification\x02Actions\x02Jamai" +
-	"s vérifié\x02Expiré\x02Historique\x02Rafraîchir les données\x02Liste des" +
-	" projets\x02Êtes vous sûr de vouloir supprimer ce projet et l'ensemble d" +
-	"e son historique ?\x02Supprimer"
+const frData string = "" + // Size: 2208 bytes
+	"\x02Journal d’audit\x02Auteur\x02Toutes les actions\x02Identifiant de la" +
+	" cible\x02Filtrer\x02Aucune modification enregistrée.\x02Date\x02Action" +
+	"\x02Cible\x02Modifications\x02Afficher\x02Avant\x02Après\x02Plus récents" +
+	"\x02Plus anciens\x02Hôtes découverts\x02Points d'accès de vos domaines t" +
+	"rouvés dans les journaux Certificate Transparency ou dans vos zones DNS." +
+	" Approuvez-les pour surveiller leur certificat.\x02Tous\x02Aucun hôte dé" +
+	"couvert.\x02Hôte:Port\x02Type\x02Domaine\x02Source\x02Émetteur\x02Expire" +
+	" le\x02Vu la première fois\x02Vu la dernière fois\x02Actions\x02Historiq" +
+	"ue\x02Approuver\x02Ignorer\x02Aucun historique trouvé\x02Dernière vérifi" +
+	"cation\x02Domaines\x02IP\x02Jours restants\x02Échec\x02Exporter l’histor" +
+	"ique des vérifications\x02Importer des projets\x02L’import a été appliqu" +
+	"é.\x02Nouveaux projets\x02Projets modifiés\x02Liste des projets\x02Aper" +
+	"çu\x02Nom du projet\x02non sécurisé\x02Doublons, ignorés\x02Entrées inv" +
+	"alides\x02Corrigez les entrées invalides pour appliquer l’import : il es" +
+	"t appliqué entièrement ou pas du tout.\x02Appliquer l’import\x02Rien à i" +
+	"mporter.\x02Fichier à importer\x02Fichier\x02Ou collez le contenu\x02CSV" +
+	" avec une ligne d’en-tête, ou liste JSON ou YAML de projets avec les cha" +
+	"mps name, host, port, type, allow_insecure, tags et team. Les projets so" +
+	"nt rapprochés par nom.\x02Format\x02Automatique\x02Prévisualiser l’impor" +
+	"t\x02Tableau de bord\x02Aucun projet !\x02Ajouter un nouveau projet\x02D" +
+	"ate de vérification\x02Jamais vérifié\x02Expiré\x02Rafraîchir les donnée" +
+	"s\x02Connecté en tant que\x02Déconnexion\x02Connexion\x02Nom d’utilisate" +
+	"ur ou mot de passe incorrect.\x02Nom d’utilisateur\x02Mot de passe\x02Co" +
+	"nnexion avec SSO\x02Modifier le projet\x02Hôte\x02Type de service\x02(av" +
+	"ec AUTH TLS)\x02(avec STARTTLS)\x02Personnalisé\x02Port\x02Étiquettes" +
+	"\x02Liste séparée par des virgules, utilisée pour filtrer les résultats " +
+	"de l'API\x02Équipe\x02Seuls les membres de cette équipe (et les administ" +
+	"rateurs) voient le projet. Laisser vide pour le partager avec tout le mo" +
+	"nde.\x02Autoriser les certificats non sécurisés\x02Pour les certificats " +
+	"auto-signés ou non sécurisés. À utiliser avec prudence !\x02Enregistrer" +
+	"\x02Ajouter le projet\x02Partagé\x02Ce projet est déclaré hors de l’appl" +
+	"ication : modifiez-le dans sa source.\x02Géré par\x02Modifier\x02Êtes vo" +
+	"us sûr de vouloir supprimer ce projet et l'ensemble de son historique ?" +
+	"\x02Supprimer\x02Exporter les projets"
 
-	// Total table size 1404 bytes (1KiB); checksum: 28F18B00
+	// Total table size 4769 bytes (4KiB); checksum: E1EF92F6
@@ -1,101 +1,273 @@
 package checker
 
 import (
+	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"log"
 	"net"
 	"strings"
+	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"leblanc.io/open-go-ssl-checker/internal/logger"
+	"leblanc.io/open-go-ssl-checker/internal/metrics"
 	"leblanc.io/open-go-ssl-checker/internal/store"
+	"leblanc.io/open-go-ssl-checker/internal/tracing"
 	"leblanc.io/open-go-ssl-checker/internal/types"
 	"leblanc.io/open-go-ssl-checker/internal/websocket"
 )
 
+// DefaultTimeout bounds a certificate check when no timeout is configured.
+const DefaultTimeout = 30 * time.Second
+
 type CertificateService struct {
-	Store *store.Store
-	Hub   *websocket.Hub
+	Store   store.Store
+	Hub     *websocket.Hub
+	Timeout time.Duration
+	// Metrics records the checks, it is optional.
+	Metrics *metrics.Registry
+
+	// The background checks, drained by Shutdown. ctx is their parent,
+	// canceled when the draining times out.
+	ctx        context.Context
+	cancel     context.CancelFunc
+	background sync.WaitGroup
+	mu         sync.Mutex
+	closing    bool
 }
 
-func NewCertificateService(s *store.Store, h *websocket.Hub) *CertificateService {
-	return &CertificateService{Store: s, Hub: h}
+// CertificateInfo holds the details of the certificate presented by a server.
+type CertificateInfo struct {
+	Host              string
+	Port              string
+	Type              string
+	IP                string
+	Domains           []string
+	Subject           string
+	Issuer            string
+	SerialNumber      string
+	NotBefore         time.Time
+	NotAfter          time.Time
+	DaysRemaining     int
+	FingerprintSHA256 string
+	// ChainValid reports whether the chain is trusted by the system roots and
+	// matches the host, even when the project allows insecure certificates.
+	ChainValid bool
 }
 
+func NewCertificateService(s store.Store, h *websocket.Hub, timeout time.Duration) *CertificateService {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &CertificateService{Store: s, Hub: h, Timeout: timeout, ctx: ctx, cancel: cancel}
+}
+
+// CheckAndStoreCertificate checks the certificate of a project and stores the
+// result in its history. It is meant to be run in the background, and does
+// nothing once Shutdown is called.
 func (cs *CertificateService) CheckAndStoreCertificate(
 	projectID, host, port, projectType string,
 	allowInsecure bool,
 ) {
-	log.Printf(
-		"Checking certificate for project %s (%s:%s, type: %s)\n",
-		projectID,
-		host,
-		port,
-		projectType,
+	cs.mu.Lock()
+	if cs.closing {
+		cs.mu.Unlock()
+
+		return
+	}
+
+	cs.background.Add(1)
+	cs.mu.Unlock()
+
+	defer cs.background.Done()
+
+	ctx, cancel := context.WithTimeout(cs.ctx, cs.Timeout)
+	defer cancel()
+
+	_, _, _ = cs.CheckProject(ctx, types.Project{
+		ID:            projectID,
+		Host:          host,
+		Port:          port,
+		Type:          projectType,
+		AllowInsecure: allowInsecure,
+	})
+}
+
+// CheckProject synchronously checks the certificate of a project, stores the
+// result in its history and returns it. A failed check is stored too: the
+// returned error is the failure reason. A check canceled with its context,
+// e.g. by the shutdown, is not stored and returns no check.
+func (cs *CertificateService) CheckProject(
+	ctx context.Context,
+	project types.Project,
+) (*types.CertificateCheck, *CertificateInfo, error) {
+	log := logger.Logger.With("project_id", project.ID, "host", project.Host, "port", project.Port)
+	log.Debug("Checking certificate", "type", project.Type)
+
+	ctx, span := tracing.Start(
+		ctx,
+		"certificate.check",
+		attribute.String("ogsc.project.id", project.ID),
+		attribute.String("ogsc.project.name", project.Name),
 	)
+	defer span.End()
 
-	var cert *x509.Certificate
-	var ip string
+	start := time.Now()
+	cs.Metrics.CheckStarted()
+
+	info, err := cs.Probe(ctx, project.Host, project.Port, project.Type, project.AllowInsecure)
+
+	result := metrics.Check{Time: start, Duration: time.Since(start), Success: err == nil}
+	if info != nil {
+		result.NotAfter, result.DaysRemaining, result.ChainValid = info.NotAfter, info.DaysRemaining, info.ChainValid
+	}
+
+	cs.Metrics.CheckDone(project.ID, result)
+	tracing.RecordError(span, err)
+
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		log.Info("Certificate check canceled", "duration", result.Duration)
+
+		return nil, nil, err
+	}
+
+	if err != nil {
+		log.Warn(
+			"Certificate check failed",
+			"phase", failedPhase(err),
+			"duration", result.Duration,
+			"error", err,
+		)
+		check := cs.recordCheckFailure(project.ID, err.Error())
+
+		return check, nil, err
+	}
+
+	log.Info(
+		"Certificate checked",
+		"duration", result.Duration,
+		"not_after", info.NotAfter,
+		"days_remaining", info.DaysRemaining,
+		"chain_valid", info.ChainValid,
+	)
+
+	return cs.handleCertificateInfo(project.ID, info), info, nil
+}
+
+// Shutdown stops starting the background checks and waits for the running
+// ones to complete, canceling them when ctx is done.
+func (cs *CertificateService) Shutdown(ctx context.Context) error {
+	cs.mu.Lock()
+	cs.closing = true
+	cs.mu.Unlock()
+
+	drained := make(chan struct{})
+
+	go func() {
+		cs.background.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		cs.cancel()
+		<-drained
+
+		return fmt.Errorf("error draining the background checks: %w", ctx.Err())
+	}
+}
+
+// Probe connects to the server and returns the details of the certificate it
+// presents, without storing anything.
+func (cs *CertificateService) Probe(
+	ctx context.Context,
+	host, port, projectType string,
+	allowInsecure bool,
+) (*CertificateInfo, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, cs.Timeout)
+		defer cancel()
+	}
+
+	ctx, span := tracing.Start(
+		ctx,
+		"certificate.probe",
+		attribute.String("server.address", host),
+		attribute.String("server.port", port),
+		attribute.String("ogsc.project.type", projectType),
+	)
+	defer span.End()
 
-	var err error
+	info, err := probe(ctx, host, port, projectType, allowInsecure)
+	tracing.RecordError(span, err)
+
+	if info != nil {
+		span.SetAttributes(
+			attribute.Int("ogsc.certificate.days_remaining", info.DaysRemaining),
+			attribute.Bool("ogsc.certificate.chain_valid", info.ChainValid),
+		)
+	}
+
+	return info, err
+}
+
+func probe(ctx context.Context, host, port, projectType string, allowInsecure bool) (*CertificateInfo, error) {
+	var certs []*x509.Certificate
+	var ip string
 
 	switch projectType {
 	case "ftp":
-		cert, ip, err = FtpGetTlsCertificate(host, port, allowInsecure, host)
+		var err error
+
+		certs, ip, err = FtpGetTlsCertificates(ctx, host, port, allowInsecure, host)
 		if err != nil {
-			log.Printf(
-				"Error retrieving FTP certificate for %s:%s: %v",
-				host,
-				port,
-				err,
-			)
-			cs.recordCheckFailure(projectID, fmt.Sprintf("FTP retrieval: %v", err))
-
-			return
+			return nil, fmt.Errorf("FTP retrieval: %w", err)
 		}
 
 	default:
-		conn, dialErr := tls.Dial("tcp", fmt.Sprintf("%s:%s", host, port), &tls.Config{
-			InsecureSkipVerify: allowInsecure,
-			ServerName:         host,
-		})
-		if dialErr != nil {
-			log.Printf("Error connecting via TLS to %s:%s: %v", host, port, dialErr)
-			cs.recordCheckFailure(projectID, fmt.Sprintf("TLS connection: %v", dialErr))
-
-			return
+		conn, err := dial(ctx, host, port)
+		if err != nil {
+			return nil, fmt.Errorf("TLS connection: %w", err)
 		}
 		defer conn.Close()
 
-		remoteAddr := conn.RemoteAddr()
-		if tcpAddr, ok := remoteAddr.(*net.TCPAddr); ok {
-			ip = tcpAddr.IP.String()
-		} else {
-			ip = remoteAddr.String()
-		}
-
-		if len(conn.ConnectionState().PeerCertificates) == 0 {
-			log.Printf("No certificate presented by %s:%s", host, port)
-			cs.recordCheckFailure(projectID, "No certificate presented")
-
-			return
+		tlsConn, err := handshake(ctx, conn, &tls.Config{
+			InsecureSkipVerify: allowInsecure,
+			ServerName:         host,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("TLS connection: %w", err)
 		}
 
-		cert = conn.ConnectionState().PeerCertificates[0]
+		ip = remoteIP(tlsConn.RemoteAddr())
+		certs = tlsConn.ConnectionState().PeerCertificates
 	}
 
-	if cert == nil {
-		log.Printf("Certificate not retrieved for project %s (%s:%s)", projectID, host, port)
-		cs.recordCheckFailure(projectID, "Certificate not retrieved (generic)")
-
-		return
+	if len(certs) == 0 || certs[0] == nil {
+		return nil, errors.New("No certificate presented")
 	}
 
-	cs.handleCertificateInfo(projectID, cert, ip)
+	return newCertificateInfo(host, port, projectType, ip, certs), nil
 }
 
-func (cs *CertificateService) handleCertificateInfo(projectID string, cert *x509.Certificate, ip string) {
+func newCertificateInfo(
+	host, port, projectType, ip string,
+	certs []*x509.Certificate,
+) *CertificateInfo {
+	cert := certs[0]
+
 	var domains []string
 	if len(cert.DNSNames) > 0 {
 		domains = cert.DNSNames
@@ -103,17 +275,55 @@ func (cs *CertificateService) handleCertificateInfo(projectID string, cert *x509
 		domains = append(domains, cert.Subject.CommonName)
 	}
 
-	expiryDate := cert.NotAfter
-	daysRemaining := int(time.Until(expiryDate).Hours() / 24)
-	issuer := cert.Issuer.String()
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	return &CertificateInfo{
+		Host:              host,
+		Port:              port,
+		Type:              projectType,
+		IP:                ip,
+		Domains:           domains,
+		Subject:           cert.Subject.String(),
+		Issuer:            cert.Issuer.String(),
+		SerialNumber:      cert.SerialNumber.String(),
+		NotBefore:         cert.NotBefore,
+		NotAfter:          cert.NotAfter,
+		DaysRemaining:     int(time.Until(cert.NotAfter).Hours() / 24),
+		FingerprintSHA256: hex.EncodeToString(fingerprint[:]),
+		ChainValid:        verifyChain(host, certs),
+	}
+}
+
+// verifyChain verifies the chain presented by the server against the system roots.
+func verifyChain(host string, certs []*x509.Certificate) bool {
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Intermediates: intermediates,
+	})
+
+	return err == nil
+}
+
+func remoteIP(remoteAddr net.Addr) string {
+	if tcpAddr, ok := remoteAddr.(*net.TCPAddr); ok {
+		return tcpAddr.IP.String()
+	}
+
+	return remoteAddr.String()
+}
 
+func (cs *CertificateService) handleCertificateInfo(
+	projectID string,
+	info *CertificateInfo,
+) *types.CertificateCheck {
 	projectName, err := cs.Store.GetProjectName(projectID)
 	if err != nil {
-		log.Printf(
-			"Warning: Unable to retrieve the project name %s for storing the check: %v",
-			projectID,
-			err,
-		)
+		logger.Logger.Warn("Unable to retrieve the project name for storing the check", "project_id", projectID, "error", err)
 
 		projectName = "Unknown"
 	}
@@ -122,30 +332,36 @@ func (cs *CertificateService) handleCertificateInfo(projectID string, cert *x509
 		CheckTime:     time.Now(),
 		ProjectID:     projectID,
 		ProjectName:   projectName,
-		Domains:       strings.Join(domains, ", "),
-		IP:            ip,
-		Issuer:        issuer,
-		ExpiryDate:    expiryDate.Format("2006-01-02"),
-		DaysRemaining: daysRemaining,
+		Domains:       strings.Join(info.Domains, ", "),
+		IP:            info.IP,
+		Issuer:        info.Issuer,
+		ExpiryDate:    info.NotAfter.Format("2006-01-02"),
+		DaysRemaining: info.DaysRemaining,
 	}
 
 	if err := cs.Store.AddCertificateCheck(checkData); err != nil {
-		log.Printf(
-			"Error inserting verification data for project %s: %v",
-			projectID,
-			err,
-		)
+		logger.Logger.Error("Error inserting verification data", "project_id", projectID, "error", err)
 	} else {
-		log.Printf("Certificate verification stored for project %s. Domains: %s, Expires on: %s (%d days remaining)",
-			projectID, checkData.Domains, checkData.ExpiryDate, checkData.DaysRemaining)
+		logger.Logger.Debug(
+			"Certificate verification stored",
+			"project_id", projectID,
+			"domains", checkData.Domains,
+			"expiry_date", checkData.ExpiryDate,
+			"days_remaining", checkData.DaysRemaining,
+		)
 
 		if cs.Hub != nil {
 			cs.Hub.NotifyUpdate()
 		}
 	}
+
+	return &checkData
 }
 
-func (cs *CertificateService) recordCheckFailure(projectID string, failureReason string) {
+func (cs *CertificateService) recordCheckFailure(
+	projectID string,
+	failureReason string,
+) *types.CertificateCheck {
 	projectName, err := cs.Store.GetProjectName(projectID)
 	if err != nil {
 		projectName = "Unknown"
@@ -160,16 +376,14 @@ func (cs *CertificateService) recordCheckFailure(projectID string, failureReason
 		DaysRemaining: -1,
 	}
 	if err := cs.Store.AddCertificateCheck(checkData); err != nil {
-		log.Printf(
-			"Error recording verification failure for project %s: %v",
-			projectID,
-			err,
-		)
+		logger.Logger.Error("Error recording verification failure", "project_id", projectID, "error", err)
 	} else {
-		log.Printf("Verification failure recorded for project %s: %s", projectID, failureReason)
+		logger.Logger.Debug("Verification failure recorded", "project_id", projectID, "reason", failureReason)
 
 		if cs.Hub != nil {
 			cs.Hub.NotifyUpdate()
 		}
 	}
+
+	return &checkData
 }
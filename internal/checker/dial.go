@@ -0,0 +1,132 @@
+package checker
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"leblanc.io/open-go-ssl-checker/internal/logger"
+	"leblanc.io/open-go-ssl-checker/internal/tracing"
+)
+
+// Phases of a check, named in the logs of the checks.
+const (
+	phaseResolve     = "dns"
+	phaseConnect     = "connect"
+	phaseAuthTLS     = "auth_tls"
+	phaseHandshake   = "handshake"
+	phaseCertificate = "certificate"
+)
+
+// phaseError is the failure of a phase of a check.
+type phaseError struct {
+	phase string
+	err   error
+}
+
+func (e *phaseError) Error() string {
+	return e.err.Error()
+}
+
+func (e *phaseError) Unwrap() error {
+	return e.err
+}
+
+// failedPhase returns the phase of the check which failed, the certificate
+// when the connection succeeded.
+func failedPhase(err error) string {
+	var pe *phaseError
+	if errors.As(err, &pe) {
+		return pe.phase
+	}
+
+	return phaseCertificate
+}
+
+// logPhase logs the duration of a successful phase, at the debug level.
+func logPhase(host string, port string, phase string, start time.Time) {
+	logger.Logger.Debug(
+		"Check phase completed",
+		"host", host,
+		"port", port,
+		"phase", phase,
+		"duration", time.Since(start),
+	)
+}
+
+// dial resolves the host and connects to the first of its addresses
+// accepting the connection, with a span for each step.
+func dial(ctx context.Context, host string, port string) (net.Conn, error) {
+	start := time.Now()
+	resolveCtx, span := tracing.Start(ctx, "dns.resolve", attribute.String("server.address", host))
+	addresses, err := net.DefaultResolver.LookupHost(resolveCtx, host)
+	span.SetAttributes(attribute.Int("dns.addresses", len(addresses)))
+	tracing.RecordError(span, err)
+	span.End()
+
+	if err != nil {
+		return nil, &phaseError{phase: phaseResolve, err: fmt.Errorf("DNS resolution: %w", err)}
+	}
+
+	logPhase(host, port, phaseResolve, start)
+
+	start = time.Now()
+
+	connectCtx, span := tracing.StartClient(ctx, "tcp.connect", attribute.String("server.port", port))
+	defer span.End()
+
+	var (
+		dialer net.Dialer
+		errs   []error
+	)
+
+	for _, address := range addresses {
+		conn, err := dialer.DialContext(connectCtx, "tcp", net.JoinHostPort(address, port))
+		if err == nil {
+			span.SetAttributes(attribute.String("network.peer.address", address))
+			logPhase(host, port, phaseConnect, start)
+
+			return conn, nil
+		}
+
+		errs = append(errs, err)
+
+		if connectCtx.Err() != nil {
+			break
+		}
+	}
+
+	err = errors.Join(errs...)
+	tracing.RecordError(span, err)
+
+	return nil, &phaseError{phase: phaseConnect, err: err}
+}
+
+// handshake negotiates TLS over the connection.
+func handshake(ctx context.Context, conn net.Conn, config *tls.Config) (*tls.Conn, error) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "tls.handshake", attribute.String("tls.server_name", config.ServerName))
+	defer span.End()
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		tracing.RecordError(span, err)
+
+		return nil, &phaseError{phase: phaseHandshake, err: err}
+	}
+
+	_, port, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	logPhase(config.ServerName, port, phaseHandshake, start)
+
+	state := tlsConn.ConnectionState()
+	span.SetAttributes(
+		attribute.String("tls.protocol.version", tls.VersionName(state.Version)),
+		attribute.String("tls.cipher", tls.CipherSuiteName(state.CipherSuite)),
+	)
+
+	return tlsConn, nil
+}
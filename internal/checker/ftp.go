@@ -1,26 +1,41 @@
 package checker
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
 	"net"
 	"net/textproto"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/logger"
+	"leblanc.io/open-go-ssl-checker/internal/tracing"
 )
 
-func FtpGetTlsCertificate(
+// FtpGetTlsCertificates upgrades an FTP connection with AUTH TLS and returns
+// the certificate chain presented by the server. The whole exchange is bounded
+// by the context deadline.
+func FtpGetTlsCertificates(
+	ctx context.Context,
 	host, port string,
 	allowInsecure bool,
 	serverNameOverride string,
-) (*x509.Certificate, string, error) {
+) ([]*x509.Certificate, string, error) {
 	serverAddr := net.JoinHostPort(host, port)
 
-	conn, err := net.Dial("tcp", serverAddr)
+	conn, err := dial(ctx, host, port)
 	if err != nil {
 		return nil, "", fmt.Errorf("FTP: unable to connect to %s: %w", serverAddr, err)
 	}
 	defer conn.Close()
 
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, "", fmt.Errorf("FTP: unable to set deadline on %s: %w", serverAddr, err)
+		}
+	}
+
 	tp := textproto.NewConn(conn)
 	defer tp.Close()
 
@@ -32,24 +47,13 @@ func FtpGetTlsCertificate(
 		ip = remoteAddr.String() // Fallback si ce n'est pas une adresse TCP
 	}
 
-	_, _, err = tp.ReadResponse(220) // Welcome message
-	if err != nil {
-		return nil, ip, fmt.Errorf(
-			"FTP: error reading initial response from %s: %w",
-			serverAddr,
-			err,
-		)
-	}
+	start := time.Now()
 
-	err = tp.PrintfLine("AUTH TLS")
-	if err != nil {
-		return nil, ip, fmt.Errorf("FTP: error sending AUTH TLS to %s: %w", serverAddr, err)
+	if err := ftpAuthTLS(ctx, tp, serverAddr); err != nil {
+		return nil, ip, &phaseError{phase: phaseAuthTLS, err: err}
 	}
 
-	_, _, err = tp.ReadResponse(234) // Server ready for TLS
-	if err != nil {
-		return nil, ip, fmt.Errorf("FTP: server %s did not accept AUTH TLS: %w", serverAddr, err)
-	}
+	logPhase(host, port, phaseAuthTLS, start)
 
 	effectiveServerName := serverNameOverride
 	if effectiveServerName == "" {
@@ -67,8 +71,8 @@ func FtpGetTlsCertificate(
 		InsecureSkipVerify: allowInsecure, // WARNING: Security risk
 	}
 
-	tlsConn := tls.Client(conn, tlsConfig)
-	if err = tlsConn.Handshake(); err != nil {
+	tlsConn, err := handshake(ctx, conn, tlsConfig)
+	if err != nil {
 		return nil, ip, fmt.Errorf("FTP: TLS negotiation failed with %s: %w", serverAddr, err)
 	}
 	defer tlsConn.Close() // The original defer on conn will close the underlying connection
@@ -88,18 +92,47 @@ func FtpGetTlsCertificate(
 	err = secureTp.PrintfLine("QUIT")
 	if err != nil {
 		// Not critical for cert retrieval, but good to know
-		fmt.Printf(
-			"FTP: Warning - error sending QUIT to %s: %v\n",
-			serverAddr,
-			err,
-		)
+		logger.Logger.Warn("FTP: error sending QUIT", "host", host, "port", port, "error", err)
 	} else {
 		_, _, err = secureTp.ReadResponse(221) // Bye
 		if err != nil {
-			fmt.Printf("FTP: Warning - error reading QUIT response from %s: %v\n", serverAddr, err)
+			logger.Logger.Warn("FTP: error reading QUIT response", "host", host, "port", port, "error", err)
 		}
 	}
 	// The defer tlsConn.Close() will take care of closing the connection.
 
-	return certs[0], ip, nil
+	return certs, ip, nil
+}
+
+// ftpAuthTLS reads the welcome message and asks the server to upgrade the
+// connection with AUTH TLS.
+func ftpAuthTLS(ctx context.Context, tp *textproto.Conn, serverAddr string) error {
+	_, span := tracing.Start(ctx, "ftp.auth_tls")
+	defer span.End()
+
+	_, _, err := tp.ReadResponse(220) // Welcome message
+	if err != nil {
+		err = fmt.Errorf("FTP: error reading initial response from %s: %w", serverAddr, err)
+		tracing.RecordError(span, err)
+
+		return err
+	}
+
+	err = tp.PrintfLine("AUTH TLS")
+	if err != nil {
+		err = fmt.Errorf("FTP: error sending AUTH TLS to %s: %w", serverAddr, err)
+		tracing.RecordError(span, err)
+
+		return err
+	}
+
+	_, _, err = tp.ReadResponse(234) // Server ready for TLS
+	if err != nil {
+		err = fmt.Errorf("FTP: server %s did not accept AUTH TLS: %w", serverAddr, err)
+		tracing.RecordError(span, err)
+
+		return err
+	}
+
+	return nil
 }
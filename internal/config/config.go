@@ -1,5 +1,11 @@
 package config
 
+import (
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/projectio"
+)
+
 type Config struct {
 	Database struct {
 		Driver string `env:"OGSC_DB_DRIVER" env-default:"sqlite3"   yaml:"driver"`
@@ -7,9 +13,137 @@ type Config struct {
 	} `yaml:"database"`
 
 	Server struct {
-		Host     string `env:"OGSC_SERVER_HOST" env-default:"127.0.0.1" yaml:"host"`
-		Port     int    `env:"OGSC_SERVER_PORT" env-default:"4332"      yaml:"port"`
-		LogLevel string `env:"OGSC_LOG_LEVEL"   env-default:"error"     yaml:"log_level"`
-		ApiKey   string `env:"OGSC_API_KEY"     env-default:""          yaml:"api_key"`
+		Host      string `env:"OGSC_SERVER_HOST" env-default:"127.0.0.1" yaml:"host"`
+		Port      int    `env:"OGSC_SERVER_PORT" env-default:"4332"      yaml:"port"`
+		LogLevel  string `env:"OGSC_LOG_LEVEL"   env-default:"error"     yaml:"log_level"`
+		LogFormat string `env:"OGSC_LOG_FORMAT"  env-default:"text"      yaml:"log_format"`
+		ApiKey    string `env:"OGSC_API_KEY"     env-default:""          yaml:"api_key"`
+		// AllowedOrigins lists the other origins allowed to submit the forms and
+		// to open the WebSocket, e.g. when a reverse proxy changes the host.
+		AllowedOrigins []string `env:"OGSC_SERVER_ALLOWED_ORIGINS" env-default:"" yaml:"allowed_origins"`
+		// ShutdownTimeout bounds the wait for the requests and the checks in
+		// flight on SIGTERM, the checks still running are then canceled.
+		ShutdownTimeout time.Duration `env:"OGSC_SERVER_SHUTDOWN_TIMEOUT" env-default:"30s" yaml:"shutdown_timeout"`
+
+		// TLS serves the web UI over HTTPS. The files are reloaded when they
+		// change, and RedirectPort serves a redirection from HTTP when set.
+		TLS struct {
+			Enabled        bool          `env:"OGSC_SERVER_TLS_ENABLED"         env-default:"false" yaml:"enabled"`
+			CertFile       string        `env:"OGSC_SERVER_TLS_CERT_FILE"                           yaml:"cert_file"`
+			KeyFile        string        `env:"OGSC_SERVER_TLS_KEY_FILE"                            yaml:"key_file"`
+			ReloadInterval time.Duration `env:"OGSC_SERVER_TLS_RELOAD_INTERVAL" env-default:"1m"    yaml:"reload_interval"`
+			RedirectPort   int           `env:"OGSC_SERVER_TLS_REDIRECT_PORT"   env-default:"0"     yaml:"redirect_port"`
+
+			// SelfMonitor adds the certificate of the server as a project,
+			// checked on Host, the first name of the certificate by default.
+			SelfMonitor struct {
+				Enabled       bool   `env:"OGSC_SERVER_TLS_SELF_MONITOR"                env-default:"false"               yaml:"enabled"`
+				Name          string `env:"OGSC_SERVER_TLS_SELF_MONITOR_NAME"           env-default:"open-go-ssl-checker" yaml:"name"`
+				Host          string `env:"OGSC_SERVER_TLS_SELF_MONITOR_HOST"                                             yaml:"host"`
+				AllowInsecure bool   `env:"OGSC_SERVER_TLS_SELF_MONITOR_ALLOW_INSECURE" env-default:"false"               yaml:"allow_insecure"`
+			} `yaml:"self_monitor"`
+		} `yaml:"tls"`
 	} `yaml:"server"`
+
+	Checker struct {
+		Timeout time.Duration `env:"OGSC_CHECK_TIMEOUT" env-default:"30s" yaml:"timeout"`
+	} `yaml:"checker"`
+
+	// Metrics are served on /metrics in the Prometheus format, to an API key
+	// with the read scope unless public.
+	Metrics struct {
+		Enabled bool `env:"OGSC_METRICS_ENABLED" env-default:"true"  yaml:"enabled"`
+		Public  bool `env:"OGSC_METRICS_PUBLIC"  env-default:"false" yaml:"public"`
+	} `yaml:"metrics"`
+
+	// Tracing exports the spans of the checks to an OpenTelemetry collector,
+	// with OTLP over HTTP. The headers are given as key=value.
+	Tracing struct {
+		Enabled     bool     `env:"OGSC_TRACING_ENABLED"      env-default:"false"                 yaml:"enabled"`
+		Endpoint    string   `env:"OGSC_TRACING_ENDPOINT"     env-default:"http://localhost:4318" yaml:"endpoint"`
+		Headers     []string `env:"OGSC_TRACING_HEADERS"                                          yaml:"headers"`
+		ServiceName string   `env:"OGSC_TRACING_SERVICE_NAME" env-default:"open-go-ssl-checker"   yaml:"service_name"`
+		SampleRatio float64  `env:"OGSC_TRACING_SAMPLE_RATIO" env-default:"1"                     yaml:"sample_ratio"`
+	} `yaml:"tracing"`
+
+	Retention struct {
+		Enabled      bool          `env:"OGSC_RETENTION_ENABLED"        env-default:"false" yaml:"enabled"`
+		KeepAllDays  int           `env:"OGSC_RETENTION_KEEP_ALL_DAYS"  env-default:"30"    yaml:"keep_all_days"`
+		Downsample   string        `env:"OGSC_RETENTION_DOWNSAMPLE"     env-default:"day"   yaml:"downsample"`
+		MaxAgeMonths int           `env:"OGSC_RETENTION_MAX_AGE_MONTHS" env-default:"12"    yaml:"max_age_months"`
+		Interval     time.Duration `env:"OGSC_RETENTION_INTERVAL"       env-default:"24h"   yaml:"interval"`
+	} `yaml:"retention"`
+
+	// Projects are declared in the configuration or in a file, e.g. tracked
+	// in git. They are reconciled at startup, on SIGHUP and when the files
+	// change, and are read-only in the UI and the API.
+	Projects struct {
+		File          string            `env:"OGSC_PROJECTS_FILE"           env-default:""      yaml:"file"`
+		Prune         bool              `env:"OGSC_PROJECTS_PRUNE"          env-default:"false" yaml:"prune"`
+		WatchInterval time.Duration     `env:"OGSC_PROJECTS_WATCH_INTERVAL" env-default:"30s"   yaml:"watch_interval"`
+		Items         []projectio.Entry `yaml:"items"`
+	} `yaml:"projects"`
+
+	// Discovery creates the projects of the TLS endpoints found in external
+	// systems, and deletes them when the endpoints disappear.
+	Discovery struct {
+		Interval time.Duration `env:"OGSC_DISCOVERY_INTERVAL" env-default:"5m" yaml:"interval"`
+
+		Kubernetes struct {
+			Enabled    bool     `env:"OGSC_DISCOVERY_KUBERNETES_ENABLED"    env-default:"false"      yaml:"enabled"`
+			Kubeconfig string   `env:"OGSC_DISCOVERY_KUBERNETES_KUBECONFIG"                          yaml:"kubeconfig"`
+			Contexts   []string `env:"OGSC_DISCOVERY_KUBERNETES_CONTEXTS"                            yaml:"contexts"`
+			Namespaces []string `env:"OGSC_DISCOVERY_KUBERNETES_NAMESPACES"                          yaml:"namespaces"`
+			Team       string   `env:"OGSC_DISCOVERY_KUBERNETES_TEAM"                                yaml:"team"`
+			Tags       []string `env:"OGSC_DISCOVERY_KUBERNETES_TAGS"       env-default:"kubernetes" yaml:"tags"`
+		} `yaml:"kubernetes"`
+
+		// CT proposes the hosts of the domains found in the Certificate
+		// Transparency logs, which are only monitored once approved.
+		CT struct {
+			Enabled  bool          `env:"OGSC_DISCOVERY_CT_ENABLED"  env-default:"false"           yaml:"enabled"`
+			URL      string        `env:"OGSC_DISCOVERY_CT_URL"      env-default:"https://crt.sh/" yaml:"url"`
+			Domains  []string      `env:"OGSC_DISCOVERY_CT_DOMAINS"                                yaml:"domains"`
+			Interval time.Duration `env:"OGSC_DISCOVERY_CT_INTERVAL" env-default:"24h"             yaml:"interval"`
+			Team     string        `env:"OGSC_DISCOVERY_CT_TEAM"                                   yaml:"team"`
+			Tags     []string      `env:"OGSC_DISCOVERY_CT_TAGS"     env-default:"ct"              yaml:"tags"`
+		} `yaml:"ct"`
+
+		// DNS proposes the hosts of DNS zones answering TLS on the probed
+		// ports. The zone files are given as [<origin>=]<path>, the zones
+		// transferred with AXFR as <zone>@<server>[:<port>].
+		DNS struct {
+			Enabled      bool          `env:"OGSC_DISCOVERY_DNS_ENABLED"       env-default:"false" yaml:"enabled"`
+			ZoneFiles    []string      `env:"OGSC_DISCOVERY_DNS_ZONE_FILES"                        yaml:"zone_files"`
+			AXFR         []string      `env:"OGSC_DISCOVERY_DNS_AXFR"                              yaml:"axfr"`
+			Ports        []string      `env:"OGSC_DISCOVERY_DNS_PORTS"         env-default:"443"   yaml:"ports"`
+			ProbeTimeout time.Duration `env:"OGSC_DISCOVERY_DNS_PROBE_TIMEOUT" env-default:"5s"    yaml:"probe_timeout"`
+			Interval     time.Duration `env:"OGSC_DISCOVERY_DNS_INTERVAL"      env-default:"24h"   yaml:"interval"`
+			Team         string        `env:"OGSC_DISCOVERY_DNS_TEAM"                              yaml:"team"`
+			Tags         []string      `env:"OGSC_DISCOVERY_DNS_TAGS"          env-default:"dns"   yaml:"tags"`
+		} `yaml:"dns"`
+	} `yaml:"discovery"`
+
+	Auth struct {
+		Enabled       bool          `env:"OGSC_AUTH_ENABLED"        env-default:"false" yaml:"enabled"`
+		AnonymousRead bool          `env:"OGSC_AUTH_ANONYMOUS_READ" env-default:"false" yaml:"anonymous_read"`
+		SessionTTL    time.Duration `env:"OGSC_AUTH_SESSION_TTL"    env-default:"168h"  yaml:"session_ttl"`
+		SecureCookie  bool          `env:"OGSC_AUTH_SECURE_COOKIE"  env-default:"false" yaml:"secure_cookie"`
+
+		OIDC struct {
+			Enabled         bool     `env:"OGSC_AUTH_OIDC_ENABLED"        env-default:"false"                       yaml:"enabled"`
+			Issuer          string   `env:"OGSC_AUTH_OIDC_ISSUER"                                                   yaml:"issuer"`
+			ClientID        string   `env:"OGSC_AUTH_OIDC_CLIENT_ID"                                                yaml:"client_id"`
+			ClientSecret    string   `env:"OGSC_AUTH_OIDC_CLIENT_SECRET"                                            yaml:"client_secret"`
+			RedirectURL     string   `env:"OGSC_AUTH_OIDC_REDIRECT_URL"                                             yaml:"redirect_url"`
+			Scopes          []string `env:"OGSC_AUTH_OIDC_SCOPES"         env-default:"openid,profile,email,groups" yaml:"scopes"`
+			UsernameClaim   string   `env:"OGSC_AUTH_OIDC_USERNAME_CLAIM" env-default:"preferred_username"          yaml:"username_claim"`
+			GroupsClaim     string   `env:"OGSC_AUTH_OIDC_GROUPS_CLAIM"   env-default:"groups"                      yaml:"groups_claim"`
+			ViewerGroups    []string `env:"OGSC_AUTH_OIDC_VIEWER_GROUPS"                                            yaml:"viewer_groups"`
+			EditorGroups    []string `env:"OGSC_AUTH_OIDC_EDITOR_GROUPS"                                            yaml:"editor_groups"`
+			AdminGroups     []string `env:"OGSC_AUTH_OIDC_ADMIN_GROUPS"                                             yaml:"admin_groups"`
+			DefaultRole     string   `env:"OGSC_AUTH_OIDC_DEFAULT_ROLE"                                             yaml:"default_role"`
+			TeamGroupPrefix string   `env:"OGSC_AUTH_OIDC_TEAM_GROUP_PREFIX" yaml:"team_group_prefix"`
+		} `yaml:"oidc"`
+	} `yaml:"auth"`
 }
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/retention"
+)
+
+// Validate checks the values which their types do not constrain, so that a
+// mistake stops the startup instead of being silently ignored.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Retention.Enabled {
+		if err := retention.ValidateDownsample(c.Retention.Downsample); err != nil {
+			errs = append(errs, fmt.Errorf("retention.downsample: %w", err))
+		}
+
+		if c.Retention.KeepAllDays < 0 {
+			errs = append(errs, fmt.Errorf("retention.keep_all_days: invalid value %d", c.Retention.KeepAllDays))
+		}
+
+		if c.Retention.MaxAgeMonths < 0 {
+			errs = append(errs, fmt.Errorf("retention.max_age_months: invalid value %d", c.Retention.MaxAgeMonths))
+		}
+
+		errs = append(errs, validateInterval("retention.interval", c.Retention.Interval))
+	}
+
+	if c.Server.TLS.Enabled {
+		errs = append(errs, validateInterval("server.tls.reload_interval", c.Server.TLS.ReloadInterval))
+	}
+
+	errs = append(errs, validateInterval("discovery.interval", c.Discovery.Interval))
+
+	if c.Discovery.CT.Enabled {
+		if len(c.Discovery.CT.Domains) == 0 {
+			errs = append(errs, errors.New("discovery.ct.domains: no domains"))
+		}
+
+		errs = append(errs, validateInterval("discovery.ct.interval", c.Discovery.CT.Interval))
+	}
+
+	if dns := c.Discovery.DNS; dns.Enabled {
+		if len(dns.ZoneFiles) == 0 && len(dns.AXFR) == 0 {
+			errs = append(errs, errors.New("discovery.dns: no zone files nor AXFR"))
+		}
+
+		for _, transfer := range dns.AXFR {
+			if _, _, err := ParseAXFR(transfer); err != nil {
+				errs = append(errs, fmt.Errorf("discovery.dns.axfr: %w", err))
+			}
+		}
+
+		errs = append(errs, validateInterval("discovery.dns.interval", dns.Interval))
+	}
+
+	if c.Tracing.Enabled {
+		if _, err := ParseHeaders(c.Tracing.Headers); err != nil {
+			errs = append(errs, fmt.Errorf("tracing.headers: %w", err))
+		}
+
+		// Written to also reject NaN
+		if ratio := c.Tracing.SampleRatio; !(ratio >= 0 && ratio <= 1) {
+			errs = append(errs, fmt.Errorf("tracing.sample_ratio: invalid ratio %g, expected a number between 0 and 1", ratio))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ParseHeaders returns the headers given as key=value.
+func ParseHeaders(headers []string) (map[string]string, error) {
+	parsed := make(map[string]string, len(headers))
+
+	for _, header := range headers {
+		key, value, found := strings.Cut(header, "=")
+		if !found || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid header %q, expected key=value", header)
+		}
+
+		parsed[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return parsed, nil
+}
+
+// ParseAXFR returns the zone and the server of a zone transfer given as
+// <zone>@<server>[:<port>].
+func ParseAXFR(transfer string) (string, string, error) {
+	zone, server, found := strings.Cut(transfer, "@")
+	if !found || zone == "" || server == "" {
+		return "", "", fmt.Errorf("invalid zone transfer %q, expected <zone>@<server>", transfer)
+	}
+
+	return zone, server, nil
+}
+
+// validateInterval rejects the negative intervals of the background jobs, a
+// zero interval runs the job at startup, or on SIGHUP, only.
+func validateInterval(name string, interval time.Duration) error {
+	if interval < 0 {
+		return fmt.Errorf("%s: invalid interval %s, expected 0 or a positive duration", name, interval)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		apply func(c *Config)
+		want  string
+	}{
+		{"zero intervals", func(c *Config) {
+			c.Retention.Enabled = true
+			c.Retention.Downsample = "day"
+		}, ""},
+		{"retention downsample", func(c *Config) {
+			c.Retention.Enabled = true
+			c.Retention.Downsample = "weekly"
+		}, "retention.downsample"},
+		{"retention interval", func(c *Config) {
+			c.Retention.Enabled = true
+			c.Retention.Downsample = "day"
+			c.Retention.Interval = -time.Hour
+		}, "retention.interval"},
+		{"TLS reload interval", func(c *Config) {
+			c.Server.TLS.Enabled = true
+			c.Server.TLS.ReloadInterval = -time.Minute
+		}, "server.tls.reload_interval"},
+		{"discovery interval", func(c *Config) { c.Discovery.Interval = -time.Minute }, "discovery.interval"},
+		{"CT interval", func(c *Config) {
+			c.Discovery.CT.Enabled = true
+			c.Discovery.CT.Interval = -time.Hour
+		}, "discovery.ct.interval"},
+		{"DNS interval", func(c *Config) {
+			c.Discovery.DNS.Enabled = true
+			c.Discovery.DNS.Interval = -time.Hour
+		}, "discovery.dns.interval"},
+		{"CT domains", func(c *Config) { c.Discovery.CT.Enabled = true }, "discovery.ct.domains"},
+		{"DNS zones", func(c *Config) { c.Discovery.DNS.Enabled = true }, "no zone files nor AXFR"},
+		{"DNS AXFR", func(c *Config) {
+			c.Discovery.DNS.Enabled = true
+			c.Discovery.DNS.AXFR = []string{"example.com@127.0.0.1", "example.org"}
+		}, `discovery.dns.axfr: invalid zone transfer "example.org"`},
+		{"tracing headers", func(c *Config) {
+			c.Tracing.Enabled = true
+			c.Tracing.Headers = []string{"authorization=Bearer xxx", "=value"}
+		}, `tracing.headers: invalid header "=value"`},
+		{"tracing sample ratio", func(c *Config) {
+			c.Tracing.Enabled = true
+			c.Tracing.SampleRatio = 0.25
+		}, ""},
+		{"tracing sample ratio above 1", func(c *Config) {
+			c.Tracing.Enabled = true
+			c.Tracing.SampleRatio = 1.5
+		}, "tracing.sample_ratio"},
+		{"negative tracing sample ratio", func(c *Config) {
+			c.Tracing.Enabled = true
+			c.Tracing.SampleRatio = -0.1
+		}, "tracing.sample_ratio"},
+		{"NaN tracing sample ratio", func(c *Config) {
+			c.Tracing.Enabled = true
+			c.Tracing.SampleRatio = math.NaN()
+		}, "tracing.sample_ratio"},
+		{"disabled sources", func(c *Config) {
+			c.Discovery.DNS.AXFR = []string{"invalid"}
+			c.Tracing.Headers = []string{"invalid"}
+			c.Tracing.SampleRatio = 2
+		}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c Config
+
+			tt.apply(&c)
+
+			err := c.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Validate() error = %v, want an error on %s", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"leblanc.io/open-go-ssl-checker/internal/store"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// Sources of the candidates.
+const (
+	CandidateSourceCT  = "ct"
+	CandidateSourceDNS = "dns"
+)
+
+// candidateStore is the part of the store used by the discoveries proposing
+// candidates.
+type candidateStore interface {
+	store.ProjectRepository
+	store.CandidateRepository
+}
+
+// endpoint is an endpoint found by a discovery, with the certificate seen.
+type endpoint struct {
+	Host     string
+	Port     string
+	Type     string
+	Issuer   string
+	NotAfter time.Time
+}
+
+// propose records the endpoints found for a domain: the known candidates are
+// seen again, the endpoints already monitored are skipped and the others
+// become pending candidates. It returns the number of new candidates.
+func propose(s candidateStore, source string, domain string, endpoints []endpoint) (int, error) {
+	now := time.Now()
+
+	projects, err := s.ListProjects()
+	if err != nil {
+		return 0, fmt.Errorf("error listing projects: %w", err)
+	}
+
+	monitored := make(map[string]bool, len(projects))
+	for _, p := range projects {
+		monitored[net.JoinHostPort(strings.ToLower(p.Host), p.Port)] = true
+	}
+
+	candidates, err := s.ListCandidates("")
+	if err != nil {
+		return 0, fmt.Errorf("error listing candidates: %w", err)
+	}
+
+	known := make(map[string]types.Candidate, len(candidates))
+	for _, c := range candidates {
+		known[net.JoinHostPort(c.Host, c.Port)] = c
+	}
+
+	created := 0
+
+	for _, e := range endpoints {
+		address := net.JoinHostPort(e.Host, e.Port)
+
+		if candidate, ok := known[address]; ok {
+			candidate.LastSeen = now
+
+			if e.NotAfter.After(candidate.NotAfter) {
+				candidate.Issuer, candidate.NotAfter = e.Issuer, e.NotAfter
+			}
+
+			if err := s.UpdateCandidate(candidate); err != nil {
+				return created, err
+			}
+
+			continue
+		}
+
+		if monitored[address] {
+			continue
+		}
+
+		candidate := types.Candidate{
+			ID:        uuid.New().String(),
+			Host:      e.Host,
+			Port:      e.Port,
+			Type:      e.Type,
+			Domain:    domain,
+			Source:    source,
+			Issuer:    e.Issuer,
+			NotAfter:  e.NotAfter,
+			FirstSeen: now,
+			LastSeen:  now,
+			Status:    types.CandidatePending,
+		}
+		if err := s.AddCandidate(candidate); err != nil {
+			return created, err
+		}
+
+		known[address] = candidate
+		created++
+	}
+
+	return created, nil
+}
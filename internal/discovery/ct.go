@@ -0,0 +1,228 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/logger"
+)
+
+// crtShTimeLayout is the format of the dates of crt.sh, in UTC.
+const crtShTimeLayout = "2006-01-02T15:04:05"
+
+// CTCertificate is a certificate found in the Certificate Transparency logs.
+type CTCertificate struct {
+	Names    []string
+	Issuer   string
+	NotAfter time.Time
+}
+
+// CTLog searches the certificates logged for the subdomains of a domain.
+type CTLog interface {
+	Search(ctx context.Context, domain string) ([]CTCertificate, error)
+}
+
+// CrtSh searches a crt.sh compatible JSON API.
+type CrtSh struct {
+	url  string
+	http *http.Client
+}
+
+func NewCrtSh(baseURL string) *CrtSh {
+	return &CrtSh{url: baseURL, http: &http.Client{Timeout: 2 * time.Minute}}
+}
+
+type crtShEntry struct {
+	IssuerName string `json:"issuer_name"`
+	CommonName string `json:"common_name"`
+	// NameValue lists the names of the certificate, one per line.
+	NameValue string `json:"name_value"`
+	NotAfter  string `json:"not_after"`
+}
+
+// Search returns the certificates of the domain and its subdomains which are
+// not expired.
+func (c *CrtSh) Search(ctx context.Context, domain string) ([]CTCertificate, error) {
+	query := url.Values{"q": {"%." + domain}, "output": {"json"}, "exclude": {"expired"}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error searching %s: %w", domain, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+		return nil, fmt.Errorf("error searching %s: %s: %s", domain, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var entries []crtShEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("error decoding %s results: %w", domain, err)
+	}
+
+	certificates := make([]CTCertificate, 0, len(entries))
+
+	for _, e := range entries {
+		notAfter, err := time.Parse(crtShTimeLayout, e.NotAfter)
+		if err != nil {
+			notAfter, _ = time.Parse(time.RFC3339, e.NotAfter)
+		}
+
+		certificates = append(certificates, CTCertificate{
+			Names:    append(strings.Split(e.NameValue, "\n"), e.CommonName),
+			Issuer:   e.IssuerName,
+			NotAfter: notAfter,
+		})
+	}
+
+	return certificates, nil
+}
+
+// CTDiscovery periodically searches the Certificate Transparency logs for
+// the hosts of the configured domains, and proposes the ones which are not
+// monitored yet as candidates.
+type CTDiscovery struct {
+	log      CTLog
+	store    candidateStore
+	domains  []string
+	interval time.Duration
+	stopChan chan struct{}
+	running  sync.WaitGroup
+}
+
+func NewCTDiscovery(ctLog CTLog, s candidateStore, domains []string, interval time.Duration) *CTDiscovery {
+	return &CTDiscovery{
+		log:      ctLog,
+		store:    s,
+		domains:  domains,
+		interval: interval,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start launches the discovery goroutine. Without interval, the logs are only
+// searched at startup.
+func (d *CTDiscovery) Start() {
+	logger.Logger.Info("Starting CT discovery", "domains", strings.Join(d.domains, ","), "interval", d.interval)
+
+	// Without interval, the nil channel never ticks
+	var (
+		ticker *time.Ticker
+		tick   <-chan time.Time
+	)
+
+	if d.interval > 0 {
+		ticker = time.NewTicker(d.interval)
+		tick = ticker.C
+	}
+
+	d.running.Go(func() {
+		d.RunOnce()
+
+		for {
+			select {
+			case <-tick:
+				d.RunOnce()
+			case <-d.stopChan:
+				if ticker != nil {
+					ticker.Stop()
+				}
+
+				logger.Logger.Info("CT discovery stopped")
+
+				return
+			}
+		}
+	})
+}
+
+// Stop stops the discovery goroutine and waits for the running search to
+// complete, so that the store can be closed.
+func (d *CTDiscovery) Stop() {
+	close(d.stopChan)
+	d.running.Wait()
+}
+
+// RunOnce searches the hosts of every domain.
+func (d *CTDiscovery) RunOnce() {
+	for _, domain := range d.domains {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*discoverTimeout)
+		certificates, err := d.log.Search(ctx, domain)
+		cancel()
+
+		if err != nil {
+			logger.Logger.Error("CT discovery error - Search", "domain", domain, "error", err)
+
+			continue
+		}
+
+		latest := hostCertificates(domain, certificates, time.Now())
+		endpoints := make([]endpoint, 0, len(latest))
+
+		for host, certificate := range latest {
+			endpoints = append(endpoints, endpoint{
+				Host:     host,
+				Port:     "443",
+				Type:     typeHTTPS,
+				Issuer:   certificate.Issuer,
+				NotAfter: certificate.NotAfter,
+			})
+		}
+
+		created, err := propose(d.store, CandidateSourceCT, domain, endpoints)
+		if err != nil {
+			logger.Logger.Error("CT discovery error - propose", "domain", domain, "error", err)
+
+			continue
+		}
+
+		logger.Logger.Info(
+			"CT discovery completed",
+			"domain", domain,
+			"certificates", len(certificates),
+			"created", created,
+		)
+	}
+}
+
+// hostCertificates returns the latest valid certificate of each host of the
+// domain. The wildcard names are skipped: they do not tell which hosts exist.
+func hostCertificates(domain string, certificates []CTCertificate, now time.Time) map[string]CTCertificate {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	latest := map[string]CTCertificate{}
+
+	for _, certificate := range certificates {
+		if !certificate.NotAfter.IsZero() && certificate.NotAfter.Before(now) {
+			continue
+		}
+
+		for _, name := range certificate.Names {
+			host := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+
+			if host == "" || strings.Contains(host, "*") ||
+				(host != domain && !strings.HasSuffix(host, "."+domain)) {
+				continue
+			}
+
+			if current, ok := latest[host]; !ok || certificate.NotAfter.After(current.NotAfter) {
+				latest[host] = certificate
+			}
+		}
+	}
+
+	return latest
+}
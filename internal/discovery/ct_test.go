@@ -0,0 +1,180 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/store"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// stubCTLog returns the certificates set for each domain.
+type stubCTLog map[string][]CTCertificate
+
+func (l stubCTLog) Search(_ context.Context, domain string) ([]CTCertificate, error) {
+	certificates, ok := l[domain]
+	if !ok {
+		return nil, errors.New("unknown domain")
+	}
+
+	return certificates, nil
+}
+
+func candidateHosts(t *testing.T, s store.CandidateRepository) map[string]types.Candidate {
+	t.Helper()
+
+	candidates, err := s.ListCandidates("")
+	if err != nil {
+		t.Fatalf("ListCandidates() error = %v", err)
+	}
+
+	hosts := make(map[string]types.Candidate, len(candidates))
+	for _, c := range candidates {
+		if _, ok := hosts[c.Host]; ok {
+			t.Errorf("host %s proposed twice", c.Host)
+		}
+
+		hosts[c.Host] = c
+	}
+
+	return hosts
+}
+
+func TestCTDiscovery(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	s := store.NewMemoryStore()
+
+	// Already monitored, the host must not be proposed
+	if err := s.AddProject(types.Project{ID: "p1", Name: "www", Host: "WWW.example.com", Port: "443", Type: "http"}); err != nil {
+		t.Fatalf("AddProject() error = %v", err)
+	}
+
+	ctLog := stubCTLog{"example.com": {
+		{Names: []string{"*.example.com", "example.com"}, Issuer: "R10", NotAfter: now.Add(30 * 24 * time.Hour)},
+		{Names: []string{"api.example.com", "API.example.com.", "www.example.com"}, Issuer: "R10", NotAfter: now.Add(24 * time.Hour)},
+		// Renewed: the latest certificate of the host is kept
+		{Names: []string{"api.example.com"}, Issuer: "R11", NotAfter: now.Add(60 * 24 * time.Hour)},
+		{Names: []string{"old.example.com"}, Issuer: "R3", NotAfter: now.Add(-time.Hour)},
+		{Names: []string{"example.org", "notexample.com"}, Issuer: "R10", NotAfter: now.Add(time.Hour)},
+	}}
+
+	d := NewCTDiscovery(ctLog, s, []string{"example.com", "unknown.com"}, 0)
+	d.RunOnce()
+
+	hosts := candidateHosts(t, s)
+
+	names := make([]string, 0, len(hosts))
+	for host := range hosts {
+		names = append(names, host)
+	}
+
+	slices.Sort(names)
+
+	if want := []string{"api.example.com", "example.com"}; !slices.Equal(names, want) {
+		t.Fatalf("candidates = %v, want %v", names, want)
+	}
+
+	api := hosts["api.example.com"]
+	if api.Issuer != "R11" || !api.NotAfter.Equal(now.Add(60*24*time.Hour)) || api.Source != CandidateSourceCT ||
+		api.Domain != "example.com" || api.Port != "443" || api.Status != types.CandidatePending {
+		t.Errorf("candidate = %+v, want the pending api.example.com with the R11 certificate", api)
+	}
+
+	// Found again, the candidates are only seen again, even once ignored
+	api.Status = types.CandidateIgnored
+	if err := s.UpdateCandidate(api); err != nil {
+		t.Fatalf("UpdateCandidate() error = %v", err)
+	}
+
+	d.RunOnce()
+
+	hosts = candidateHosts(t, s)
+	if len(hosts) != 2 {
+		t.Fatalf("got %d candidates after the second run, want 2", len(hosts))
+	}
+
+	if again := hosts["api.example.com"]; again.ID != api.ID || again.Status != types.CandidateIgnored ||
+		again.LastSeen.Before(api.LastSeen) {
+		t.Errorf("candidate = %+v, want the ignored candidate seen again", again)
+	}
+}
+
+func TestCrtShSearch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("q") != "%.example.com" || r.URL.Query().Get("output") != "json" {
+			http.Error(w, "unexpected query", http.StatusBadRequest)
+
+			return
+		}
+
+		w.Write([]byte(`[{"issuer_name":"C=US, O=Let's Encrypt, CN=R11","common_name":"example.com",` +
+			`"name_value":"example.com\nwww.example.com","not_after":"2026-12-01T10:00:00"}]`))
+	}))
+	defer server.Close()
+
+	certificates, err := NewCrtSh(server.URL).Search(t.Context(), "example.com")
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+
+	if len(certificates) != 1 {
+		t.Fatalf("got %d certificates, want 1", len(certificates))
+	}
+
+	c := certificates[0]
+	if !slices.Equal(c.Names, []string{"example.com", "www.example.com", "example.com"}) ||
+		!c.NotAfter.Equal(time.Date(2026, 12, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("certificate = %+v", c)
+	}
+
+	if _, err := NewCrtSh(server.URL).Search(t.Context(), "example.org"); err == nil {
+		t.Error("Search() error = nil on an error response, want an error")
+	}
+}
+
+// blockingCTLog blocks the searches until released.
+type blockingCTLog struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (l blockingCTLog) Search(context.Context, string) ([]CTCertificate, error) {
+	close(l.started)
+	<-l.release
+
+	return nil, nil
+}
+
+func TestCTDiscoveryStopWaitsForSearch(t *testing.T) {
+	ctLog := blockingCTLog{started: make(chan struct{}), release: make(chan struct{})}
+	d := NewCTDiscovery(ctLog, store.NewMemoryStore(), []string{"example.com"}, 0)
+	d.Start()
+
+	<-ctLog.started
+
+	stopped := make(chan struct{})
+
+	go func() {
+		d.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		t.Fatal("Stop() returned during the search")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(ctLog.release)
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop() did not return after the search")
+	}
+}
@@ -0,0 +1,140 @@
+// Package discovery creates projects for the TLS endpoints found in external
+// systems, such as Kubernetes clusters. Each source manages its own projects:
+// they are reconciled periodically and deleted when the endpoint disappears.
+// The hosts found in the Certificate Transparency logs are only proposed, as
+// candidates to approve.
+package discovery
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/audit"
+	"leblanc.io/open-go-ssl-checker/internal/logger"
+	"leblanc.io/open-go-ssl-checker/internal/projectio"
+	"leblanc.io/open-go-ssl-checker/internal/reconcile"
+	"leblanc.io/open-go-ssl-checker/internal/store"
+)
+
+// discoverTimeout bounds a discovery of a source.
+const discoverTimeout = time.Minute
+
+// Source finds TLS endpoints.
+type Source interface {
+	// Name identifies the source, it manages the discovered projects.
+	Name() string
+	// Discover returns the endpoints currently found. On error, the projects
+	// of the source are left unchanged.
+	Discover(ctx context.Context) ([]projectio.Entry, error)
+}
+
+type sourceReconciler struct {
+	source     Source
+	reconciler *reconcile.Reconciler
+}
+
+// Runner periodically discovers the endpoints of its sources and reconciles
+// them with their projects.
+type Runner struct {
+	sources  []sourceReconciler
+	interval time.Duration
+	stopChan chan struct{}
+	running  sync.WaitGroup
+}
+
+func NewRunner(
+	s store.ProjectRepository,
+	checker reconcile.Checker,
+	recorder *audit.Recorder,
+	interval time.Duration,
+	sources ...Source,
+) *Runner {
+	runner := &Runner{interval: interval, stopChan: make(chan struct{})}
+
+	for _, source := range sources {
+		runner.sources = append(runner.sources, sourceReconciler{
+			source: source,
+			// The endpoints which disappeared are removed with their projects
+			reconciler: reconcile.NewReconciler(s, checker, recorder, source.Name(), true),
+		})
+	}
+
+	return runner
+}
+
+// Start launches the discovery goroutine. Without interval, the endpoints are
+// only discovered at startup.
+func (r *Runner) Start() {
+	logger.Logger.Info("Starting discovery", "sources", len(r.sources), "interval", r.interval)
+
+	// Without interval, the nil channel never ticks
+	var (
+		ticker *time.Ticker
+		tick   <-chan time.Time
+	)
+
+	if r.interval > 0 {
+		ticker = time.NewTicker(r.interval)
+		tick = ticker.C
+	}
+
+	r.running.Go(func() {
+		r.RunOnce()
+
+		for {
+			select {
+			case <-tick:
+				r.RunOnce()
+			case <-r.stopChan:
+				if ticker != nil {
+					ticker.Stop()
+				}
+
+				logger.Logger.Info("Discovery stopped")
+
+				return
+			}
+		}
+	})
+}
+
+// Stop stops the discovery goroutine and waits for the running discovery to
+// complete, so that the store can be closed.
+func (r *Runner) Stop() {
+	close(r.stopChan)
+	r.running.Wait()
+}
+
+// RunOnce discovers the endpoints of every source and reconciles them.
+func (r *Runner) RunOnce() {
+	for _, sr := range r.sources {
+		name := sr.source.Name()
+
+		ctx, cancel := context.WithTimeout(context.Background(), discoverTimeout)
+		entries, err := sr.source.Discover(ctx)
+		cancel()
+
+		if err != nil {
+			logger.Logger.Error("Discovery error - Discover", "source", name, "error", err)
+
+			continue
+		}
+
+		result, err := sr.reconciler.Reconcile(entries)
+		if err != nil {
+			logger.Logger.Error("Discovery error - Reconcile", "source", name, "error", err)
+
+			continue
+		}
+
+		logger.Logger.Info(
+			"Discovery completed",
+			"source", name,
+			"endpoints", len(entries),
+			"created", result.Created,
+			"updated", result.Updated,
+			"deleted", result.Deleted,
+		)
+	}
+}
@@ -0,0 +1,200 @@
+package discovery
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/logger"
+)
+
+// probeConcurrency bounds the TLS connections opened at the same time.
+const probeConcurrency = 16
+
+// portTypes are the project types proposed for the well-known TLS ports,
+// the other ports are proposed as custom TLS services.
+var portTypes = map[string]string{
+	"443":  typeHTTPS,
+	"465":  "smtp",
+	"636":  "ldap",
+	"993":  "imap",
+	"995":  "pop3",
+	"5061": "sip",
+	"5223": "xmpp",
+	"6697": "irc",
+}
+
+// DNSDiscovery periodically lists the names of DNS zones, probes their ports
+// for TLS, and proposes the endpoints answering as candidates.
+type DNSDiscovery struct {
+	zones    []Zone
+	ports    []string
+	store    candidateStore
+	timeout  time.Duration
+	interval time.Duration
+	stopChan chan struct{}
+	running  sync.WaitGroup
+}
+
+func NewDNSDiscovery(
+	zones []Zone,
+	ports []string,
+	s candidateStore,
+	timeout time.Duration,
+	interval time.Duration,
+) *DNSDiscovery {
+	return &DNSDiscovery{
+		zones:    zones,
+		ports:    ports,
+		store:    s,
+		timeout:  timeout,
+		interval: interval,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start launches the discovery goroutine. Without interval, the zones are only
+// probed at startup.
+func (d *DNSDiscovery) Start() {
+	logger.Logger.Info("Starting DNS discovery", "zones", len(d.zones), "interval", d.interval)
+
+	// Without interval, the nil channel never ticks
+	var (
+		ticker *time.Ticker
+		tick   <-chan time.Time
+	)
+
+	if d.interval > 0 {
+		ticker = time.NewTicker(d.interval)
+		tick = ticker.C
+	}
+
+	d.running.Go(func() {
+		d.RunOnce()
+
+		for {
+			select {
+			case <-tick:
+				d.RunOnce()
+			case <-d.stopChan:
+				if ticker != nil {
+					ticker.Stop()
+				}
+
+				logger.Logger.Info("DNS discovery stopped")
+
+				return
+			}
+		}
+	})
+}
+
+// Stop stops the discovery goroutine and waits for the running probes to
+// complete, so that the store can be closed.
+func (d *DNSDiscovery) Stop() {
+	close(d.stopChan)
+	d.running.Wait()
+}
+
+// RunOnce probes the hosts of every zone.
+func (d *DNSDiscovery) RunOnce() {
+	for _, zone := range d.zones {
+		ctx, cancel := context.WithTimeout(context.Background(), discoverTimeout)
+		hosts, err := zone.Hosts(ctx)
+		cancel()
+
+		if err != nil {
+			logger.Logger.Error("DNS discovery error - Hosts", "zone", zone.Name(), "error", err)
+
+			continue
+		}
+
+		endpoints := d.probe(hosts)
+
+		created, err := propose(d.store, CandidateSourceDNS, zone.Name(), endpoints)
+		if err != nil {
+			logger.Logger.Error("DNS discovery error - propose", "zone", zone.Name(), "error", err)
+
+			continue
+		}
+
+		logger.Logger.Info(
+			"DNS discovery completed",
+			"zone", zone.Name(),
+			"hosts", len(hosts),
+			"endpoints", len(endpoints),
+			"created", created,
+		)
+	}
+}
+
+// probe returns the ports of the hosts answering a TLS handshake.
+func (d *DNSDiscovery) probe(hosts []string) []endpoint {
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		endpoints []endpoint
+	)
+
+	slots := make(chan struct{}, probeConcurrency)
+
+	for _, host := range hosts {
+		for _, port := range d.ports {
+			wg.Go(func() {
+				slots <- struct{}{}
+				defer func() { <-slots }()
+
+				e, ok := d.probeEndpoint(host, port)
+				if !ok {
+					return
+				}
+
+				mu.Lock()
+				endpoints = append(endpoints, e)
+				mu.Unlock()
+			})
+		}
+	}
+
+	wg.Wait()
+
+	return endpoints
+}
+
+// probeEndpoint connects to the endpoint and returns the certificate it
+// presents. The certificate is not verified: an invalid certificate is worth
+// monitoring too.
+func (d *DNSDiscovery) probeEndpoint(host string, port string) (endpoint, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+
+	dialer := &tls.Dialer{Config: &tls.Config{
+		InsecureSkipVerify: true, // Only probing, the checks verify the certificate
+		ServerName:         host,
+	}}
+
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return endpoint{}, false
+	}
+	defer conn.Close()
+
+	e := endpoint{Host: host, Port: port, Type: portTypes[port]}
+	if e.Type == "" {
+		e.Type = typeTLS
+	}
+
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return e, true
+	}
+
+	if certificates := tlsConn.ConnectionState().PeerCertificates; len(certificates) > 0 {
+		e.Issuer = certificates[0].Issuer.String()
+		e.NotAfter = certificates[0].NotAfter
+	}
+
+	return e, true
+}
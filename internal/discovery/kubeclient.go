@@ -0,0 +1,348 @@
+package discovery
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// In-cluster service account files.
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	inClusterName     = "in-cluster"
+)
+
+// errKubeNotFound is returned when the resource type does not exist, e.g.
+// when the Gateway API is not installed.
+var errKubeNotFound = errors.New("not found")
+
+// kubeClient is a minimal client of the Kubernetes API, only listing
+// resources.
+type kubeClient struct {
+	server string
+	http   *http.Client
+	// token returns the bearer token, read again on every request as the
+	// service account tokens are rotated.
+	token    func() (string, error)
+	username string
+	password string
+}
+
+// kubeconfig is the subset of the kubeconfig file used to connect.
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+			TLSServerName            string `yaml:"tls-server-name"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			TokenFile             string `yaml:"tokenFile"`
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+			Username              string `yaml:"username"`
+			Password              string `yaml:"password"`
+			Exec                  any    `yaml:"exec"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+// defaultKubeconfig returns the path of the kubeconfig file used by kubectl.
+func defaultKubeconfig() string {
+	if path := os.Getenv("KUBECONFIG"); path != "" {
+		// Only the first file of the list is read
+		return filepath.SplitList(path)[0]
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".kube", "config")
+}
+
+// inCluster reports whether the tool runs in a pod.
+func inCluster() bool {
+	return os.Getenv("KUBERNETES_SERVICE_HOST") != ""
+}
+
+// newInClusterClient connects with the service account of the pod.
+func newInClusterClient() (*kubeClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading service account CA: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("invalid service account CA")
+	}
+
+	client := &kubeClient{
+		server: "https://" + net.JoinHostPort(host, port),
+		http:   newKubeHTTPClient(&tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}),
+		token:  readTokenFile(filepath.Join(serviceAccountDir, "token")),
+	}
+
+	return client, nil
+}
+
+// loadKubeconfig reads a kubeconfig file.
+func loadKubeconfig(path string) (*kubeconfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading kubeconfig: %w", err)
+	}
+
+	var config kubeconfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig %s: %w", path, err)
+	}
+
+	return &config, nil
+}
+
+// client connects to the cluster of a context, the current one when empty.
+// The relative file paths are relative to the kubeconfig file in dir.
+func (k *kubeconfig) client(contextName string, dir string) (*kubeClient, error) {
+	if contextName == "" {
+		contextName = k.CurrentContext
+	}
+
+	var clusterName, userName string
+
+	found := false
+
+	for _, c := range k.Contexts {
+		if c.Name == contextName {
+			clusterName, userName, found = c.Context.Cluster, c.Context.User, true
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("context %q not found in kubeconfig", contextName)
+	}
+
+	client := &kubeClient{}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+
+		return filepath.Join(dir, path)
+	}
+
+	for _, c := range k.Clusters {
+		if c.Name != clusterName {
+			continue
+		}
+
+		client.server = strings.TrimSuffix(c.Cluster.Server, "/")
+		tlsConfig.InsecureSkipVerify = c.Cluster.InsecureSkipTLSVerify // As configured for kubectl
+		tlsConfig.ServerName = c.Cluster.TLSServerName
+
+		ca, err := readData(c.Cluster.CertificateAuthorityData, resolve(c.Cluster.CertificateAuthority))
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA of cluster %s: %w", clusterName, err)
+		}
+
+		if ca != nil {
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("invalid CA of cluster %s", clusterName)
+			}
+		}
+	}
+
+	if client.server == "" {
+		return nil, fmt.Errorf("cluster %q of context %q not found in kubeconfig", clusterName, contextName)
+	}
+
+	for _, u := range k.Users {
+		if u.Name != userName {
+			continue
+		}
+
+		if u.User.Exec != nil {
+			return nil, fmt.Errorf("user %s of context %s uses an exec plugin, which is not supported", userName, contextName)
+		}
+
+		switch {
+		case u.User.Token != "":
+			token := u.User.Token
+			client.token = func() (string, error) { return token, nil }
+		case u.User.TokenFile != "":
+			client.token = readTokenFile(resolve(u.User.TokenFile))
+		}
+
+		client.username, client.password = u.User.Username, u.User.Password
+
+		cert, err := readData(u.User.ClientCertificateData, resolve(u.User.ClientCertificate))
+		if err != nil {
+			return nil, fmt.Errorf("error reading client certificate of user %s: %w", userName, err)
+		}
+
+		key, err := readData(u.User.ClientKeyData, resolve(u.User.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("error reading client key of user %s: %w", userName, err)
+		}
+
+		if cert != nil && key != nil {
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return nil, fmt.Errorf("invalid client certificate of user %s: %w", userName, err)
+			}
+
+			tlsConfig.Certificates = []tls.Certificate{pair}
+		}
+	}
+
+	client.http = newKubeHTTPClient(tlsConfig)
+
+	return client, nil
+}
+
+func newKubeHTTPClient(tlsConfig *tls.Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{Transport: transport, Timeout: 30 * time.Second}
+}
+
+// readData returns the base64 decoded data, or the content of the file.
+func readData(data string, path string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+
+	if path != "" {
+		return os.ReadFile(path)
+	}
+
+	return nil, nil
+}
+
+func readTokenFile(path string) func() (string, error) {
+	return func() (string, error) {
+		token, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("error reading token: %w", err)
+		}
+
+		return strings.TrimSpace(string(token)), nil
+	}
+}
+
+// kubeList is a page of a list of resources.
+type kubeList[T any] struct {
+	Metadata struct {
+		Continue string `json:"continue"`
+	} `json:"metadata"`
+	Items []T `json:"items"`
+}
+
+// list returns every resource of the path, reading the pages of the list.
+func list[T any](ctx context.Context, c *kubeClient, path string) ([]T, error) {
+	var items []T
+
+	continueToken := ""
+
+	for {
+		query := url.Values{"limit": {"500"}}
+		if continueToken != "" {
+			query.Set("continue", continueToken)
+		}
+
+		var page kubeList[T]
+		if err := c.get(ctx, path+"?"+query.Encode(), &page); err != nil {
+			return nil, err
+		}
+
+		items = append(items, page.Items...)
+
+		if page.Metadata.Continue == "" {
+			return items, nil
+		}
+
+		continueToken = page.Metadata.Continue
+	}
+}
+
+func (c *kubeClient) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.server+path, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	switch {
+	case c.token != nil:
+		token, err := c.token()
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+	case c.username != "":
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("error requesting %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return errKubeNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+		return fmt.Errorf("error requesting %s: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("error decoding %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"leblanc.io/open-go-ssl-checker/internal/projectio"
+)
+
+// externalDNSAnnotation gives the DNS names of a Service, as published by
+// external-dns.
+const externalDNSAnnotation = "external-dns.alpha.kubernetes.io/hostname"
+
+// Project types of the discovered endpoints.
+const (
+	typeHTTPS = "http"
+	typeTLS   = "custom"
+)
+
+// KubernetesConfig selects the clusters and the namespaces to discover.
+type KubernetesConfig struct {
+	// Kubeconfig is the kubeconfig file, the in-cluster service account is
+	// used when empty and running in a pod, the kubectl file otherwise.
+	Kubeconfig string
+	// Contexts of the kubeconfig to discover, the current one when empty.
+	Contexts []string
+	// Namespaces to discover, every namespace when empty.
+	Namespaces []string
+	// Team and Tags of the discovered projects.
+	Team string
+	Tags []string
+}
+
+type kubeMetadata struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+type kubeIngress struct {
+	Metadata kubeMetadata `json:"metadata"`
+	Spec     struct {
+		TLS []struct {
+			Hosts []string `json:"hosts"`
+		} `json:"tls"`
+	} `json:"spec"`
+}
+
+type kubeGateway struct {
+	Metadata kubeMetadata `json:"metadata"`
+	Spec     struct {
+		Listeners []struct {
+			Hostname string `json:"hostname"`
+			Port     int    `json:"port"`
+			Protocol string `json:"protocol"`
+		} `json:"listeners"`
+	} `json:"spec"`
+}
+
+type kubeService struct {
+	Metadata kubeMetadata `json:"metadata"`
+	Spec     struct {
+		Type  string `json:"type"`
+		Ports []struct {
+			Name string `json:"name"`
+			Port int    `json:"port"`
+		} `json:"ports"`
+	} `json:"spec"`
+}
+
+// KubernetesSource discovers the TLS hosts of the Ingresses, the HTTPS and
+// TLS listeners of the Gateways and the HTTPS ports of the LoadBalancer
+// Services published by external-dns, in a cluster.
+type KubernetesSource struct {
+	cluster string
+	client  *kubeClient
+	config  KubernetesConfig
+}
+
+// NewKubernetesSources returns a source for each configured cluster.
+func NewKubernetesSources(config KubernetesConfig) ([]*KubernetesSource, error) {
+	if config.Kubeconfig == "" && inCluster() {
+		client, err := newInClusterClient()
+		if err != nil {
+			return nil, err
+		}
+
+		return []*KubernetesSource{{cluster: inClusterName, client: client, config: config}}, nil
+	}
+
+	path := config.Kubeconfig
+	if path == "" {
+		path = defaultKubeconfig()
+	}
+
+	kubeconfig, err := loadKubeconfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	contexts := config.Contexts
+	if len(contexts) == 0 {
+		contexts = []string{kubeconfig.CurrentContext}
+	}
+
+	sources := make([]*KubernetesSource, 0, len(contexts))
+
+	for _, name := range contexts {
+		client, err := kubeconfig.client(name, filepath.Dir(path))
+		if err != nil {
+			return nil, err
+		}
+
+		sources = append(sources, &KubernetesSource{cluster: name, client: client, config: config})
+	}
+
+	return sources, nil
+}
+
+// NewKubernetesSource returns a source reading the API server at the given
+// URL without authentication, e.g. a fake API server or kubectl proxy.
+func NewKubernetesSource(cluster string, server string, config KubernetesConfig) *KubernetesSource {
+	client := &kubeClient{server: strings.TrimSuffix(server, "/"), http: newKubeHTTPClient(nil)}
+
+	return &KubernetesSource{cluster: cluster, client: client, config: config}
+}
+
+// Name is "kubernetes/<context>".
+func (s *KubernetesSource) Name() string {
+	return "kubernetes/" + s.cluster
+}
+
+// Discover lists the endpoints of the cluster. The Gateways are skipped when
+// the Gateway API is not installed.
+func (s *KubernetesSource) Discover(ctx context.Context) ([]projectio.Entry, error) {
+	var entries []projectio.Entry
+
+	add := func(namespace string, host string, port int, projectType string) {
+		// Wildcard hosts cannot be connected to
+		if host == "" || strings.HasPrefix(host, "*") {
+			return
+		}
+
+		name := fmt.Sprintf("%s/%s/%s", s.cluster, namespace, host)
+		if port != 443 {
+			name += ":" + strconv.Itoa(port)
+		}
+
+		if slices.ContainsFunc(entries, func(e projectio.Entry) bool { return e.Name == name }) {
+			return
+		}
+
+		entries = append(entries, projectio.Entry{
+			Name:     name,
+			Host:     host,
+			Port:     port,
+			Type:     projectType,
+			Tags:     s.config.Tags,
+			Team:     s.config.Team,
+			Position: len(entries) + 1,
+		})
+	}
+
+	ingresses, err := listNamespaced[kubeIngress](ctx, s, "/apis/networking.k8s.io/v1", "ingresses")
+	if err != nil {
+		return nil, fmt.Errorf("error listing ingresses: %w", err)
+	}
+
+	for _, ingress := range ingresses {
+		for _, tls := range ingress.Spec.TLS {
+			for _, host := range tls.Hosts {
+				add(ingress.Metadata.Namespace, host, 443, typeHTTPS)
+			}
+		}
+	}
+
+	gateways, err := listNamespaced[kubeGateway](ctx, s, "/apis/gateway.networking.k8s.io/v1", "gateways")
+	if err != nil && !errors.Is(err, errKubeNotFound) {
+		return nil, fmt.Errorf("error listing gateways: %w", err)
+	}
+
+	for _, gateway := range gateways {
+		for _, listener := range gateway.Spec.Listeners {
+			switch listener.Protocol {
+			case "HTTPS":
+				add(gateway.Metadata.Namespace, listener.Hostname, listener.Port, typeHTTPS)
+			case "TLS":
+				add(gateway.Metadata.Namespace, listener.Hostname, listener.Port, typeTLS)
+			}
+		}
+	}
+
+	services, err := listNamespaced[kubeService](ctx, s, "/api/v1", "services")
+	if err != nil {
+		return nil, fmt.Errorf("error listing services: %w", err)
+	}
+
+	for _, service := range services {
+		hostnames := service.Metadata.Annotations[externalDNSAnnotation]
+		if service.Spec.Type != "LoadBalancer" || hostnames == "" {
+			continue
+		}
+
+		for _, port := range service.Spec.Ports {
+			if port.Port != 443 && port.Name != "https" {
+				continue
+			}
+
+			for _, host := range strings.Split(hostnames, ",") {
+				add(service.Metadata.Namespace, strings.TrimSpace(host), port.Port, typeHTTPS)
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// listNamespaced lists the resources of every namespace, or of the
+// configured ones.
+func listNamespaced[T any](ctx context.Context, s *KubernetesSource, group string, resource string) ([]T, error) {
+	if len(s.config.Namespaces) == 0 {
+		return list[T](ctx, s.client, group+"/"+resource)
+	}
+
+	var items []T
+
+	for _, namespace := range s.config.Namespaces {
+		namespaced, err := list[T](ctx, s.client, group+"/namespaces/"+namespace+"/"+resource)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, namespaced...)
+	}
+
+	return items, nil
+}
@@ -0,0 +1,262 @@
+package discovery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"leblanc.io/open-go-ssl-checker/internal/store"
+)
+
+// noopChecker skips the checks of the discovered projects.
+type noopChecker struct{}
+
+func (noopChecker) CheckAndStoreCertificate(string, string, string, string, bool) {}
+
+// fakeKubeAPI is a Kubernetes API server serving the resources listed by the
+// discovery, which the tests change between the runs.
+type fakeKubeAPI struct {
+	server *httptest.Server
+
+	mu        sync.Mutex
+	resources map[string][]any
+	// gateways reports whether the Gateway API is installed
+	gateways bool
+	// token is the expected bearer token, when not empty
+	token string
+}
+
+func newFakeKubeAPI(t *testing.T) *fakeKubeAPI {
+	t.Helper()
+
+	api := &fakeKubeAPI{resources: make(map[string][]any), gateways: true}
+	api.server = httptest.NewTLSServer(http.HandlerFunc(api.serve))
+	t.Cleanup(api.server.Close)
+
+	return api
+}
+
+func (api *fakeKubeAPI) set(path string, items ...any) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	api.resources[path] = items
+}
+
+func (api *fakeKubeAPI) serve(w http.ResponseWriter, r *http.Request) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	if api.token != "" && r.Header.Get("Authorization") != "Bearer "+api.token {
+		http.Error(w, `{"kind":"Status","code":401}`, http.StatusUnauthorized)
+
+		return
+	}
+
+	items, ok := api.resources[r.URL.Path]
+	if !ok || (!api.gateways && filepath.Base(r.URL.Path) == "gateways") {
+		http.Error(w, `{"kind":"Status","code":404}`, http.StatusNotFound)
+
+		return
+	}
+
+	// Every page holds a single item, to read the whole list
+	page := map[string]any{"metadata": map[string]string{}, "items": []any{}}
+
+	index, _ := strconv.Atoi(r.URL.Query().Get("continue"))
+
+	if index < len(items) {
+		page["items"] = items[index : index+1]
+	}
+
+	if index+1 < len(items) {
+		page["metadata"] = map[string]string{"continue": strconv.Itoa(index + 1)}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+func ingress(namespace string, name string, hosts ...string) map[string]any {
+	return map[string]any{
+		"metadata": map[string]any{"name": name, "namespace": namespace},
+		"spec":     map[string]any{"tls": []any{map[string]any{"hosts": hosts}}},
+	}
+}
+
+func gateway(namespace string, name string, listeners ...map[string]any) map[string]any {
+	return map[string]any{
+		"metadata": map[string]any{"name": name, "namespace": namespace},
+		"spec":     map[string]any{"listeners": listeners},
+	}
+}
+
+func projectNames(t *testing.T, s store.ProjectRepository) []string {
+	t.Helper()
+
+	projects, err := s.ListProjects()
+	if err != nil {
+		t.Fatalf("ListProjects() error = %v", err)
+	}
+
+	names := make([]string, 0, len(projects))
+	for _, p := range projects {
+		names = append(names, p.Name)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+func TestKubernetesDiscovery(t *testing.T) {
+	api := newFakeKubeAPI(t)
+	api.set("/apis/networking.k8s.io/v1/ingresses",
+		ingress("shop", "web", "shop.example.com", "*.shop.example.com"),
+		ingress("shop", "api", "api.example.com", "shop.example.com"),
+	)
+	api.set("/apis/gateway.networking.k8s.io/v1/gateways",
+		gateway("edge", "public",
+			map[string]any{"hostname": "gw.example.com", "port": 443, "protocol": "HTTPS"},
+			map[string]any{"hostname": "db.example.com", "port": 5432, "protocol": "TLS"},
+			map[string]any{"hostname": "plain.example.com", "port": 80, "protocol": "HTTP"},
+		),
+	)
+	api.set("/api/v1/services", map[string]any{
+		"metadata": map[string]any{
+			"name": "lb", "namespace": "edge",
+			"annotations": map[string]string{externalDNSAnnotation: "lb.example.com"},
+		},
+		"spec": map[string]any{"type": "LoadBalancer", "ports": []any{map[string]any{"name": "https", "port": 8443}}},
+	})
+
+	source := NewKubernetesSource("test", api.server.URL, KubernetesConfig{Team: "platform", Tags: []string{"kubernetes"}})
+	source.client.http = api.server.Client()
+
+	s := store.NewMemoryStore()
+	runner := NewRunner(s, noopChecker{}, nil, 0, source)
+	runner.RunOnce()
+
+	want := []string{
+		"test/edge/db.example.com:5432",
+		"test/edge/gw.example.com",
+		"test/edge/lb.example.com:8443",
+		"test/shop/api.example.com",
+		"test/shop/shop.example.com",
+	}
+	if got := projectNames(t, s); !slices.Equal(got, want) {
+		t.Fatalf("projects = %v, want %v", got, want)
+	}
+
+	projects, _ := s.ListProjects()
+	for _, p := range projects {
+		if p.ManagedBy != "kubernetes/test" || p.Team != "platform" || !slices.Equal(p.Tags, []string{"kubernetes"}) {
+			t.Errorf("project = %+v, want managed by kubernetes/test in team platform", p)
+		}
+
+		if p.Name == "test/edge/db.example.com:5432" && (p.Type != typeTLS || p.Port != "5432") {
+			t.Errorf("TLS listener project = %+v, want a custom project on port 5432", p)
+		}
+	}
+
+	// The api Ingress is deleted and the Gateway API uninstalled
+	api.set("/apis/networking.k8s.io/v1/ingresses", ingress("shop", "web", "shop.example.com"))
+	api.mu.Lock()
+	api.gateways = false
+	api.mu.Unlock()
+
+	runner.RunOnce()
+
+	want = []string{"test/edge/lb.example.com:8443", "test/shop/shop.example.com"}
+	if got := projectNames(t, s); !slices.Equal(got, want) {
+		t.Fatalf("projects = %v, want %v", got, want)
+	}
+
+	// A failing listing leaves the projects unchanged
+	api.mu.Lock()
+	api.token = "required"
+	api.mu.Unlock()
+
+	runner.RunOnce()
+
+	if got := projectNames(t, s); !slices.Equal(got, want) {
+		t.Fatalf("projects = %v after an error, want %v", got, want)
+	}
+}
+
+func TestKubernetesDiscoveryNamespaces(t *testing.T) {
+	api := newFakeKubeAPI(t)
+	api.set("/apis/networking.k8s.io/v1/namespaces/shop/ingresses", ingress("shop", "web", "shop.example.com"))
+	api.set("/apis/gateway.networking.k8s.io/v1/namespaces/shop/gateways")
+	api.set("/api/v1/namespaces/shop/services")
+
+	source := NewKubernetesSource("test", api.server.URL, KubernetesConfig{Namespaces: []string{"shop"}})
+	source.client.http = api.server.Client()
+
+	entries, err := source.Discover(t.Context())
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+
+	if len(entries) != 1 || entries[0].Host != "shop.example.com" || entries[0].Type != typeHTTPS {
+		t.Fatalf("entries = %+v, want shop.example.com", entries)
+	}
+}
+
+func TestKubeconfigToken(t *testing.T) {
+	api := newFakeKubeAPI(t)
+	api.token = "secret-token"
+	api.set("/apis/networking.k8s.io/v1/ingresses", ingress("shop", "web", "shop.example.com"))
+	api.set("/apis/gateway.networking.k8s.io/v1/gateways")
+	api.set("/api/v1/services")
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte("secret-token\n"), 0o600); err != nil {
+		t.Fatalf("error writing token: %v", err)
+	}
+
+	kubeconfig := `
+current-context: test
+clusters:
+- name: test
+  cluster:
+    server: ` + api.server.URL + `
+    insecure-skip-tls-verify: true
+users:
+- name: ci
+  user:
+    tokenFile: token
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: ci
+`
+	if err := os.WriteFile(filepath.Join(dir, "config"), []byte(kubeconfig), 0o600); err != nil {
+		t.Fatalf("error writing kubeconfig: %v", err)
+	}
+
+	sources, err := NewKubernetesSources(KubernetesConfig{Kubeconfig: filepath.Join(dir, "config")})
+	if err != nil {
+		t.Fatalf("NewKubernetesSources() error = %v", err)
+	}
+
+	if len(sources) != 1 || sources[0].Name() != "kubernetes/test" {
+		t.Fatalf("sources = %+v, want kubernetes/test", sources)
+	}
+
+	entries, err := sources[0].Discover(t.Context())
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+
+	if len(entries) != 1 || entries[0].Name != "test/shop/shop.example.com" {
+		t.Fatalf("entries = %+v, want test/shop/shop.example.com", entries)
+	}
+}
@@ -0,0 +1,444 @@
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strings"
+)
+
+// DNS record types and class used by the zone discovery.
+const (
+	dnsTypeA     = 1
+	dnsTypeCNAME = 5
+	dnsTypeSOA   = 6
+	dnsTypeAAAA  = 28
+	dnsTypeAXFR  = 252
+	dnsClassIN   = 1
+)
+
+// Zone lists the names of a DNS zone.
+type Zone interface {
+	// Name is the origin of the zone, e.g. example.com.
+	Name() string
+	// Hosts returns the names of the A, AAAA and CNAME records of the zone.
+	Hosts(ctx context.Context) ([]string, error)
+}
+
+// ZoneFile is a zone file in the BIND format.
+type ZoneFile struct {
+	origin string
+	path   string
+}
+
+// NewZoneFile reads the zone file at path. The origin is used until the file
+// sets one with $ORIGIN, it is required when the file uses relative names
+// without setting it.
+func NewZoneFile(origin string, path string) *ZoneFile {
+	return &ZoneFile{origin: canonicalName(origin), path: path}
+}
+
+func (z *ZoneFile) Name() string {
+	if z.origin == "" {
+		return z.path
+	}
+
+	return z.origin
+}
+
+func (z *ZoneFile) Hosts(_ context.Context) ([]string, error) {
+	file, err := os.Open(z.path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening zone file: %w", err)
+	}
+	defer file.Close()
+
+	hosts, err := parseZone(file, z.origin)
+	if err != nil {
+		return nil, fmt.Errorf("invalid zone file %s: %w", z.path, err)
+	}
+
+	return hosts, nil
+}
+
+// parseZone returns the owner names of the A, AAAA and CNAME records of a
+// zone file. The $INCLUDE directive is not supported.
+func parseZone(r io.Reader, origin string) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+
+	var (
+		hosts   []string
+		owner   string
+		entry   []string
+		depth   int
+		lineNo  int
+		startNo int
+	)
+
+	for scanner.Scan() {
+		lineNo++
+		line := stripComment(scanner.Text())
+
+		if depth == 0 {
+			startNo = lineNo
+			entry = entry[:0]
+
+			// A record starting with a blank has the owner of the previous one
+			if line != "" && (line[0] == ' ' || line[0] == '\t') && strings.TrimSpace(line) != "" {
+				entry = append(entry, "")
+			}
+		}
+
+		depth += strings.Count(line, "(") - strings.Count(line, ")")
+		line = strings.NewReplacer("(", " ", ")", " ").Replace(line)
+		entry = append(entry, strings.Fields(line)...)
+
+		if depth > 0 || len(entry) == 0 || (len(entry) == 1 && entry[0] == "") {
+			continue
+		}
+
+		if depth < 0 {
+			return nil, fmt.Errorf("line %d: unbalanced parentheses", startNo)
+		}
+
+		switch strings.ToUpper(entry[0]) {
+		case "$ORIGIN":
+			if len(entry) < 2 {
+				return nil, fmt.Errorf("line %d: $ORIGIN without a name", startNo)
+			}
+
+			origin = absoluteName(entry[1], origin)
+
+			continue
+		case "$TTL":
+			continue
+		case "$INCLUDE":
+			return nil, fmt.Errorf("line %d: $INCLUDE is not supported", startNo)
+		}
+
+		if entry[0] != "" {
+			owner = entry[0]
+		}
+
+		recordType, ok := zoneRecordType(entry[1:])
+		if !ok {
+			return nil, fmt.Errorf("line %d: record without a type", startNo)
+		}
+
+		if recordType != "A" && recordType != "AAAA" && recordType != "CNAME" {
+			continue
+		}
+
+		if owner == "" {
+			return nil, fmt.Errorf("line %d: record without an owner", startNo)
+		}
+
+		if origin == "" && !strings.HasSuffix(owner, ".") {
+			return nil, fmt.Errorf("line %d: relative name %s without an origin", startNo, owner)
+		}
+
+		hosts = append(hosts, absoluteName(owner, origin))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading zone: %w", err)
+	}
+
+	if depth != 0 {
+		return nil, fmt.Errorf("line %d: unbalanced parentheses", startNo)
+	}
+
+	return compactHosts(hosts), nil
+}
+
+// stripComment removes the comment of a line, outside of the quoted strings.
+func stripComment(line string) string {
+	quoted := false
+
+	for i, c := range line {
+		switch c {
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				return strings.TrimRight(line[:i], " \t")
+			}
+		}
+	}
+
+	return strings.TrimRight(line, " \t")
+}
+
+// zoneRecordType returns the type of a record, after its optional TTL and
+// class, in any order.
+func zoneRecordType(fields []string) (string, bool) {
+	for _, field := range fields {
+		upper := strings.ToUpper(field)
+
+		switch {
+		case upper == "IN" || upper == "CH" || upper == "HS":
+			continue
+		case field[0] >= '0' && field[0] <= '9':
+			continue // TTL, e.g. 3600 or 1h
+		default:
+			return upper, true
+		}
+	}
+
+	return "", false
+}
+
+// absoluteName returns the name relative to the origin, "@" being the
+// origin, in lower case and without the final dot.
+func absoluteName(name string, origin string) string {
+	switch {
+	case name == "@":
+		return origin
+	case strings.HasSuffix(name, "."):
+		return canonicalName(name)
+	case origin == "":
+		return canonicalName(name)
+	default:
+		return canonicalName(name + "." + origin)
+	}
+}
+
+func canonicalName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+}
+
+// compactHosts sorts the hosts and drops the duplicated and wildcard names,
+// which cannot be connected to.
+func compactHosts(hosts []string) []string {
+	hosts = slices.DeleteFunc(hosts, func(host string) bool {
+		return host == "" || strings.Contains(host, "*")
+	})
+	slices.Sort(hosts)
+
+	return slices.Compact(hosts)
+}
+
+// AXFR transfers a zone from a DNS server, which must allow the transfers
+// from this host.
+type AXFR struct {
+	zone   string
+	server string
+}
+
+// NewAXFR transfers the zone from the server, given as host or host:port.
+func NewAXFR(zone string, server string) *AXFR {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+	}
+
+	return &AXFR{zone: canonicalName(zone), server: server}
+}
+
+func (a *AXFR) Name() string {
+	return a.zone
+}
+
+// Hosts transfers the zone over TCP, until the closing SOA record.
+func (a *AXFR) Hosts(ctx context.Context) ([]string, error) {
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, "tcp", a.server)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %w", a.server, err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	query, id, err := axfrQuery(a.zone)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, fmt.Errorf("error sending AXFR query to %s: %w", a.server, err)
+	}
+
+	var hosts []string
+
+	soas := 0
+	reader := bufio.NewReader(conn)
+
+	for soas < 2 {
+		var size uint16
+		if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+			return nil, fmt.Errorf("error reading AXFR response of %s: %w", a.server, err)
+		}
+
+		message := make([]byte, size)
+		if _, err := io.ReadFull(reader, message); err != nil {
+			return nil, fmt.Errorf("error reading AXFR response of %s: %w", a.server, err)
+		}
+
+		records, err := parseDNSMessage(message, id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AXFR response of %s: %w", a.server, err)
+		}
+
+		if len(records) == 0 {
+			return nil, fmt.Errorf("empty AXFR response of %s", a.server)
+		}
+
+		for _, record := range records {
+			switch record.Type {
+			case dnsTypeSOA:
+				soas++
+			case dnsTypeA, dnsTypeAAAA, dnsTypeCNAME:
+				hosts = append(hosts, canonicalName(record.Name))
+			}
+		}
+	}
+
+	return compactHosts(hosts), nil
+}
+
+// axfrQuery returns the AXFR query of the zone, prefixed by its length as sent
+// over TCP, and its ID.
+func axfrQuery(zone string) ([]byte, uint16, error) {
+	var idBytes [2]byte
+	if _, err := rand.Read(idBytes[:]); err != nil {
+		return nil, 0, fmt.Errorf("error generating query ID: %w", err)
+	}
+
+	id := binary.BigEndian.Uint16(idBytes[:])
+
+	// Header: ID, flags, one question and no records
+	message := binary.BigEndian.AppendUint16(nil, id)
+	message = append(message, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0)
+
+	for label := range strings.SplitSeq(zone, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, 0, fmt.Errorf("invalid zone name %q", zone)
+		}
+
+		message = append(message, byte(len(label)))
+		message = append(message, label...)
+	}
+
+	message = append(message, 0)
+	message = binary.BigEndian.AppendUint16(message, dnsTypeAXFR)
+	message = binary.BigEndian.AppendUint16(message, dnsClassIN)
+
+	return append(binary.BigEndian.AppendUint16(nil, uint16(len(message))), message...), id, nil
+}
+
+type dnsRecord struct {
+	Name string
+	Type uint16
+}
+
+// parseDNSMessage returns the names and the types of the answers of a
+// response.
+func parseDNSMessage(message []byte, id uint16) ([]dnsRecord, error) {
+	if len(message) < 12 {
+		return nil, errors.New("truncated header")
+	}
+
+	if binary.BigEndian.Uint16(message) != id {
+		return nil, errors.New("unexpected message ID")
+	}
+
+	if rcode := message[3] & 0x0f; rcode != 0 {
+		// e.g. 5 (REFUSED) when the server does not allow the transfer
+		return nil, fmt.Errorf("response code %d", rcode)
+	}
+
+	questions := binary.BigEndian.Uint16(message[4:])
+	answers := binary.BigEndian.Uint16(message[6:])
+	offset := 12
+
+	for range questions {
+		_, next, err := readDNSName(message, offset)
+		if err != nil {
+			return nil, err
+		}
+
+		offset = next + 4 // Type and class
+	}
+
+	records := make([]dnsRecord, 0, answers)
+
+	for range answers {
+		name, next, err := readDNSName(message, offset)
+		if err != nil {
+			return nil, err
+		}
+
+		// Type, class, TTL and data length
+		if next+10 > len(message) {
+			return nil, errors.New("truncated record")
+		}
+
+		recordType := binary.BigEndian.Uint16(message[next:])
+		length := int(binary.BigEndian.Uint16(message[next+8:]))
+		offset = next + 10 + length
+
+		if offset > len(message) {
+			return nil, errors.New("truncated record data")
+		}
+
+		records = append(records, dnsRecord{Name: name, Type: recordType})
+	}
+
+	return records, nil
+}
+
+// readDNSName reads the possibly compressed name at offset, and returns the
+// offset following it.
+func readDNSName(message []byte, offset int) (string, int, error) {
+	var labels []string
+
+	next := -1
+
+	// Bound the pointers followed, against the loops
+	for jumps := 0; jumps < 64; {
+		if offset >= len(message) {
+			return "", 0, errors.New("truncated name")
+		}
+
+		length := int(message[offset])
+
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = offset + 1
+			}
+
+			return strings.Join(labels, "."), next, nil
+		case length&0xc0 == 0xc0:
+			if offset+1 >= len(message) {
+				return "", 0, errors.New("truncated name pointer")
+			}
+
+			if next < 0 {
+				next = offset + 2
+			}
+
+			offset = int(binary.BigEndian.Uint16(message[offset:]) & 0x3fff)
+			jumps++
+		default:
+			if offset+1+length > len(message) {
+				return "", 0, errors.New("truncated label")
+			}
+
+			labels = append(labels, string(message[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+
+	return "", 0, errors.New("too many name pointers")
+}
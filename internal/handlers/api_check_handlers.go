@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/checker"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// certificateJSON is the representation of the certificate returned by the
+// on-demand checks.
+type certificateJSON struct {
+	Host              string    `json:"host"`
+	Port              int       `json:"port"`
+	Type              string    `json:"type"`
+	Status            string    `json:"status"`
+	IP                string    `json:"ip"`
+	Domains           []string  `json:"domains"`
+	Subject           string    `json:"subject"`
+	Issuer            string    `json:"issuer"`
+	SerialNumber      string    `json:"serial_number"`
+	NotBefore         time.Time `json:"not_before"`
+	NotAfter          time.Time `json:"not_after"`
+	ExpiryDate        string    `json:"expiry_date"`
+	DaysRemaining     int       `json:"days_remaining"`
+	FingerprintSHA256 string    `json:"fingerprint_sha256"`
+	ChainValid        bool      `json:"chain_valid"`
+}
+
+// checkResultJSON is the response of POST /api/projects/{id}/check.
+type checkResultJSON struct {
+	ProjectID   string          `json:"project_id"`
+	CheckTime   time.Time       `json:"check_time"`
+	Certificate certificateJSON `json:"certificate"`
+}
+
+// checkErrorJSON is the response of a failed on-demand check.
+type checkErrorJSON struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+}
+
+// adHocCheckRequest is the body expected by POST /api/check.
+type adHocCheckRequest struct {
+	Host          string `json:"host"`
+	Port          int    `json:"port"`
+	Type          string `json:"type"`
+	AllowInsecure bool   `json:"allow_insecure,omitempty"`
+}
+
+func newCertificateJSON(info *checker.CertificateInfo) certificateJSON {
+	port, _ := strconv.Atoi(info.Port)
+
+	return certificateJSON{
+		Host:              info.Host,
+		Port:              port,
+		Type:              info.Type,
+		Status:            types.StatusForDays(info.DaysRemaining),
+		IP:                info.IP,
+		Domains:           info.Domains,
+		Subject:           info.Subject,
+		Issuer:            info.Issuer,
+		SerialNumber:      info.SerialNumber,
+		NotBefore:         info.NotBefore,
+		NotAfter:          info.NotAfter,
+		ExpiryDate:        info.NotAfter.Format("2006-01-02"),
+		DaysRemaining:     info.DaysRemaining,
+		FingerprintSHA256: info.FingerprintSHA256,
+		ChainValid:        info.ChainValid,
+	}
+}
+
+// writeCheckError writes the response of a failed on-demand check:
+// 504 when the check timed out, 502 otherwise.
+func writeCheckError(w http.ResponseWriter, err error) {
+	status := http.StatusBadGateway
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		status = http.StatusGatewayTimeout
+	}
+
+	writeJSON(w, status, checkErrorJSON{Status: types.StatusFailed, Error: err.Error()})
+}
+
+// CheckProjectAPIHandler handles POST /api/projects/{id}/check
+// It checks the certificate of the project synchronously, stores the result in
+// its history and returns the certificate details.
+func (ac *AppContext) CheckProjectAPIHandler(w http.ResponseWriter, r *http.Request) {
+	project, ok := ac.loadAPIProject(w, r)
+	if !ok {
+		return
+	}
+
+	ac.Audit.Record(auditActor(r), types.AuditProjectCheck, projectTarget(*project), nil, nil)
+
+	ctx, cancel := context.WithTimeout(r.Context(), ac.Checker.Timeout)
+	defer cancel()
+
+	check, info, err := ac.Checker.CheckProject(ctx, *project)
+	if err != nil {
+		writeCheckError(w, err)
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, checkResultJSON{
+		ProjectID:   project.ID,
+		CheckTime:   check.CheckTime,
+		Certificate: newCertificateJSON(info),
+	})
+}
+
+// AdHocCheckAPIHandler handles POST /api/check
+// It checks the certificate of any host synchronously, without storing anything.
+// It expects a JSON body with fields: host, port (int), type, allow_insecure (bool).
+func (ac *AppContext) AdHocCheckAPIHandler(w http.ResponseWriter, r *http.Request) {
+	var req adHocCheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+
+		return
+	}
+
+	if req.Host == "" || req.Port == 0 || req.Type == "" {
+		writeJSONError(w, http.StatusBadRequest, "host, port and type are required")
+
+		return
+	}
+
+	if req.Port < 1 || req.Port > 65535 {
+		writeJSONError(w, http.StatusBadRequest, "port must be between 1 and 65535")
+
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), ac.Checker.Timeout)
+	defer cancel()
+
+	info, err := ac.Checker.Probe(ctx, req.Host, strconv.Itoa(req.Port), req.Type, req.AllowInsecure)
+	if err != nil {
+		writeCheckError(w, err)
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newCertificateJSON(info))
+}
@@ -2,89 +2,439 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"leblanc.io/open-go-ssl-checker/internal/auth"
+	"leblanc.io/open-go-ssl-checker/internal/logger"
+	"leblanc.io/open-go-ssl-checker/internal/store"
 	"leblanc.io/open-go-ssl-checker/internal/types"
 )
 
+// projectJSON is the representation of a project used by every API endpoint.
+type projectJSON struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	Host          string   `json:"host"`
+	Port          int      `json:"port"`
+	Type          string   `json:"type"`
+	AllowInsecure bool     `json:"allow_insecure"`
+	Tags          []string `json:"tags"`
+	Team          string   `json:"team"`
+	ManagedBy     string   `json:"managed_by"`
+}
+
+// projectRequest is the body expected by POST /api/projects and PUT /api/projects/{id}.
+type projectRequest struct {
+	Name          string   `json:"name"`
+	Host          string   `json:"host"`
+	Port          int      `json:"port"`
+	Type          string   `json:"type"`
+	AllowInsecure bool     `json:"allow_insecure,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+	Team          string   `json:"team,omitempty"`
+}
+
+// projectPatchRequest is the body expected by PATCH /api/projects/{id}:
+// only the fields present in the body are modified.
+type projectPatchRequest struct {
+	Name          *string   `json:"name"`
+	Host          *string   `json:"host"`
+	Port          *int      `json:"port"`
+	Type          *string   `json:"type"`
+	AllowInsecure *bool     `json:"allow_insecure"`
+	Tags          *[]string `json:"tags"`
+	Team          *string   `json:"team"`
+}
+
+// errorJSON is the body of every API error response.
+type errorJSON struct {
+	Error string `json:"error"`
+}
+
+// createdJSON is the response of POST /api/projects.
+type createdJSON struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+func newProjectJSON(p types.Project) projectJSON {
+	port, _ := strconv.Atoi(p.Port)
+
+	return projectJSON{
+		ID:            p.ID,
+		Name:          p.Name,
+		Host:          p.Host,
+		Port:          port,
+		Type:          p.Type,
+		AllowInsecure: p.AllowInsecure,
+		Tags:          normalizeTags(p.Tags),
+		Team:          p.Team,
+		ManagedBy:     p.ManagedBy,
+	}
+}
+
+// managedMessage is the error returned when changing a project declared by a
+// source: it must be changed in the source.
+func managedMessage(p types.Project) string {
+	return "project is managed by " + p.ManagedBy + ", change it there"
+}
+
+func (req projectRequest) validate() string {
+	if req.Name == "" || req.Host == "" || req.Port == 0 || req.Type == "" {
+		return "name, host, port and type are required"
+	}
+
+	if req.Port < 1 || req.Port > 65535 {
+		return "port must be between 1 and 65535"
+	}
+
+	return ""
+}
+
+func (req projectRequest) apply(p *types.Project) {
+	p.Name = req.Name
+	p.Host = req.Host
+	p.Port = strconv.Itoa(req.Port)
+	p.Type = req.Type
+	p.AllowInsecure = req.AllowInsecure
+	p.Tags = normalizeTags(req.Tags)
+	p.Team = strings.TrimSpace(req.Team)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorJSON{Error: message})
+}
+
+// APIKeyMiddleware rejects the requests without a valid API key granting the
+// scope. The key is read from the X-API-Key header (or an Authorization
+// bearer token) and is either one of the API keys of the store or the legacy
+// server.api_key, which grants every scope.
+func (ac *AppContext) APIKeyMiddleware(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := ac.authenticateAPIKey(r)
+		if key == nil {
+			writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+
+			return
+		}
+
+		if !auth.HasScope(key.Scopes, scope) {
+			writeJSONError(w, http.StatusForbidden, "API key lacks the "+scope+" scope")
+
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithAPIKey(r.Context(), key)))
+	})
+}
+
+// authenticateAPIKey returns the API key of the request, or nil when the
+// request does not carry a valid API key.
+func (ac *AppContext) authenticateAPIKey(r *http.Request) *types.APIKey {
+	apiKey := r.Header.Get("X-API-Key")
+	if apiKey == "" {
+		apiKey, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+
+	if apiKey == "" {
+		return nil
+	}
+
+	if ac.ApiKey != "" && auth.ConstantTimeEqual(apiKey, ac.ApiKey) {
+		return &types.APIKey{Name: "legacy server.api_key", Scopes: []string{types.ScopeAdmin}}
+	}
+
+	prefix, ok := auth.ParseAPIKeyPrefix(apiKey)
+	if !ok {
+		return nil
+	}
+
+	key, err := ac.Store.GetAPIKeyByPrefix(prefix)
+	if err != nil {
+		logger.Logger.Error("APIKeyMiddleware error - GetAPIKeyByPrefix", "error", err)
+
+		return nil
+	}
+
+	now := time.Now()
+	if key == nil || !auth.VerifyAPIKey(*key, apiKey, now) {
+		return nil
+	}
+
+	// Avoid a database write on every request
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > time.Minute {
+		if err := ac.Store.TouchAPIKey(key.ID, now); err != nil {
+			logger.Logger.Error("APIKeyMiddleware error - TouchAPIKey", "api_key_id", key.ID, "error", err)
+		}
+	}
+
+	return key
+}
+
 // AddProjectAPIHandler handles POST /api/projects
-// It expects a JSON body with fields: name, host, port (int), type, allow_insecure (bool)
-// Authentication: header X-API-Key must match the configured API key.
+// It expects a JSON body with fields: name, host, port (int), type, allow_insecure (bool),
+// tags (list) and team.
 func (ac *AppContext) AddProjectAPIHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+	var req projectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
 
-	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		_ = json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
 		return
 	}
 
-	// API key check
-	apiKey := r.Header.Get("X-API-Key")
-	if ac.ApiKey == "" || apiKey == "" || apiKey != ac.ApiKey {
-		w.WriteHeader(http.StatusUnauthorized)
-		_ = json.NewEncoder(w).Encode(map[string]string{"error": "unauthorized"})
+	if msg := req.validate(); msg != "" {
+		writeJSONError(w, http.StatusBadRequest, msg)
+
+		return
+	}
+
+	project := types.Project{ID: uuid.New().String()}
+	req.apply(&project)
+
+	if !access(r).CanAccess(project) {
+		writeJSONError(w, http.StatusForbidden, "API key cannot manage the projects of team "+project.Team)
+
+		return
+	}
+
+	if err := ac.Store.AddProject(project); err != nil {
+		writeStoreError(w, "AddProjectAPIHandler error - AddProject", err, "unable to add project")
+
+		return
+	}
+
+	ac.auditProject(r, types.AuditProjectCreate, nil, &project)
+
+	// Trigger immediate check in background
+	ac.checkInBackground(project)
+
+	writeJSON(w, http.StatusCreated, createdJSON{ID: project.ID, Status: "created"})
+}
+
+// ListProjectsAPIHandler handles GET /api/projects
+func (ac *AppContext) ListProjectsAPIHandler(w http.ResponseWriter, r *http.Request) {
+	projects, err := ac.Store.ListProjects()
+	if err != nil {
+		logger.Logger.Error("ListProjectsAPIHandler error - ListProjects", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "unable to retrieve projects")
+
 		return
 	}
 
-	type addProjectRequest struct {
-		Name          string `json:"name"`
-		Host          string `json:"host"`
-		Port          int    `json:"port"`
-		Type          string `json:"type"`
-		AllowInsecure bool   `json:"allow_insecure"`
+	projects = access(r).FilterProjects(projects)
+
+	res := make([]projectJSON, 0, len(projects))
+	for _, p := range projects {
+		res = append(res, newProjectJSON(p))
 	}
 
-	var req addProjectRequest
+	writeJSON(w, http.StatusOK, res)
+}
+
+// GetProjectAPIHandler handles GET /api/projects/{id}
+func (ac *AppContext) GetProjectAPIHandler(w http.ResponseWriter, r *http.Request) {
+	project, ok := ac.loadAPIProject(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newProjectJSON(*project))
+}
+
+// UpdateProjectAPIHandler handles PUT /api/projects/{id}
+// The body is the same as for POST /api/projects and replaces the whole project.
+func (ac *AppContext) UpdateProjectAPIHandler(w http.ResponseWriter, r *http.Request) {
+	project, ok := ac.loadAPIProject(w, r)
+	if !ok {
+		return
+	}
+
+	var req projectRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid JSON body"})
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+
 		return
 	}
 
-	if req.Name == "" || req.Host == "" || req.Port == 0 || req.Type == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		_ = json.NewEncoder(w).Encode(map[string]string{"error": "name, host, port and type are required"})
+	ac.saveAPIProject(w, r, *project, req)
+}
+
+// PatchProjectAPIHandler handles PATCH /api/projects/{id}
+// Only the fields present in the body are modified.
+func (ac *AppContext) PatchProjectAPIHandler(w http.ResponseWriter, r *http.Request) {
+	project, ok := ac.loadAPIProject(w, r)
+	if !ok {
 		return
 	}
 
-	if req.Port < 1 || req.Port > 65535 {
-		w.WriteHeader(http.StatusBadRequest)
-		_ = json.NewEncoder(w).Encode(map[string]string{"error": "port must be between 1 and 65535"})
+	var patch projectPatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+
 		return
 	}
 
-	project := types.Project{
-		ID:            uuid.New().String(),
-		Name:          req.Name,
-		Host:          req.Host,
-		Port:          strconv.Itoa(req.Port),
-		Type:          req.Type,
-		AllowInsecure: req.AllowInsecure,
+	current := newProjectJSON(*project)
+	req := projectRequest{
+		Name:          current.Name,
+		Host:          current.Host,
+		Port:          current.Port,
+		Type:          current.Type,
+		AllowInsecure: current.AllowInsecure,
+		Tags:          current.Tags,
+		Team:          current.Team,
 	}
 
-	if err := ac.Store.AddProject(project); err != nil {
-		log.Printf("AddProjectAPIHandler error - AddProject: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		_ = json.NewEncoder(w).Encode(map[string]string{"error": "unable to add project"})
+	if patch.Name != nil {
+		req.Name = *patch.Name
+	}
+
+	if patch.Host != nil {
+		req.Host = *patch.Host
+	}
+
+	if patch.Port != nil {
+		req.Port = *patch.Port
+	}
+
+	if patch.Type != nil {
+		req.Type = *patch.Type
+	}
+
+	if patch.AllowInsecure != nil {
+		req.AllowInsecure = *patch.AllowInsecure
+	}
+
+	if patch.Tags != nil {
+		req.Tags = *patch.Tags
+	}
+
+	if patch.Team != nil {
+		req.Team = *patch.Team
+	}
+
+	ac.saveAPIProject(w, r, *project, req)
+}
+
+// DeleteProjectAPIHandler handles DELETE /api/projects/{id}
+func (ac *AppContext) DeleteProjectAPIHandler(w http.ResponseWriter, r *http.Request) {
+	project, ok := ac.loadAPIProject(w, r)
+	if !ok {
 		return
 	}
 
-	// Trigger immediate check in background
-	go ac.Checker.CheckAndStoreCertificate(
-		project.ID,
-		project.Host,
-		project.Port,
-		project.Type,
-		project.AllowInsecure,
-	)
-
-	w.WriteHeader(http.StatusCreated)
-	_ = json.NewEncoder(w).Encode(map[string]string{
-		"id":     project.ID,
-		"status": "created",
-	})
+	if project.Managed() {
+		writeJSONError(w, http.StatusConflict, managedMessage(*project))
+
+		return
+	}
+
+	if err := ac.Store.DeleteProject(project.ID); err != nil {
+		logger.Logger.Error("DeleteProjectAPIHandler error - DeleteProject", "project_id", project.ID, "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "unable to delete project")
+
+		return
+	}
+
+	ac.auditProject(r, types.AuditProjectDelete, project, nil)
+	logger.Logger.Info("Project deleted through the API", "project_id", project.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadAPIProject retrieves the project identified by the {id} route variable.
+// It writes the error response and returns false when the project cannot be
+// loaded. The projects the API key cannot access are reported as not found.
+func (ac *AppContext) loadAPIProject(w http.ResponseWriter, r *http.Request) (*types.Project, bool) {
+	projectID := mux.Vars(r)["id"]
+
+	project, err := ac.Store.GetProject(projectID)
+	if err != nil {
+		logger.Logger.Error("API error - GetProject", "project_id", projectID, "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "unable to retrieve project")
+
+		return nil, false
+	}
+
+	if project == nil || !access(r).CanAccess(*project) {
+		writeJSONError(w, http.StatusNotFound, "project not found")
+
+		return nil, false
+	}
+
+	return project, true
+}
+
+// saveAPIProject validates req, applies it to project and stores the result.
+// A new check is triggered when the connection parameters changed.
+func (ac *AppContext) saveAPIProject(
+	w http.ResponseWriter,
+	r *http.Request,
+	project types.Project,
+	req projectRequest,
+) {
+	if project.Managed() {
+		writeJSONError(w, http.StatusConflict, managedMessage(project))
+
+		return
+	}
+
+	if msg := req.validate(); msg != "" {
+		writeJSONError(w, http.StatusBadRequest, msg)
+
+		return
+	}
+
+	previous := project
+	req.apply(&project)
+
+	if !access(r).CanAccess(project) {
+		writeJSONError(w, http.StatusForbidden, "API key cannot manage the projects of team "+project.Team)
+
+		return
+	}
+
+	if !access(r).CanMove(previous.Team, project.Team) {
+		writeJSONError(w, http.StatusForbidden, "API key restricted to teams cannot move the projects to another team")
+
+		return
+	}
+
+	if err := ac.Store.UpdateProject(project); err != nil {
+		writeStoreError(w, "API error - UpdateProject", err, "unable to update project")
+
+		return
+	}
+
+	ac.auditProject(r, types.AuditProjectUpdate, &previous, &project)
+
+	if connectionChanged(previous, project) {
+		ac.checkInBackground(project)
+	}
+
+	writeJSON(w, http.StatusOK, newProjectJSON(project))
+}
+
+// writeStoreError maps the store errors to the matching HTTP status.
+func writeStoreError(w http.ResponseWriter, context string, err error, message string) {
+	switch {
+	case errors.Is(err, store.ErrDuplicateProjectName):
+		writeJSONError(w, http.StatusConflict, "a project with this name already exists")
+	case errors.Is(err, store.ErrProjectNotFound):
+		writeJSONError(w, http.StatusNotFound, "project not found")
+	default:
+		logger.Logger.Error(context, "error", err)
+		writeJSONError(w, http.StatusInternalServerError, message)
+	}
 }
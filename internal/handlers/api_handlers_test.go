@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"leblanc.io/open-go-ssl-checker/internal/audit"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+func TestUpdateProjectTeamRestrictedKey(t *testing.T) {
+	_, s, router := newTestAPI(t)
+
+	key := addTestAPIKey(t, s, types.APIKey{
+		ID: "k1", Name: "payments-ci",
+		Scopes: []string{types.ScopeRead, types.ScopeWrite}, Teams: []string{"payments", "billing"},
+	})
+
+	addTestProject(t, s, types.Project{ID: "p1", Name: "payments", Host: "pay.example.com", Team: "payments"})
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"shared team", `{"team": ""}`, http.StatusForbidden},
+		{"another team of the key", `{"team": "billing"}`, http.StatusForbidden},
+		{"team of another key", `{"team": "other"}`, http.StatusForbidden},
+		{"same team", `{"team": "payments", "tags": ["prod"]}`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, "/api/projects/p1", strings.NewReader(tt.body))
+			req.Header.Set("X-API-Key", key)
+			req.Header.Set("Content-Type", "application/json")
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("PATCH status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+
+			if project, _ := s.GetProject("p1"); project.Team != "payments" {
+				t.Fatalf("team = %q, want payments", project.Team)
+			}
+		})
+	}
+
+	// A key granted every project moves them
+	if code := callAPI(t, router, http.MethodPatch, "/api/projects/p1", strings.NewReader(`{"team": ""}`), nil); code != http.StatusOK {
+		t.Fatalf("PATCH status = %d with the admin key, want 200", code)
+	}
+
+	if project, _ := s.GetProject("p1"); project.Team != "" {
+		t.Fatalf("team = %q, want the shared team", project.Team)
+	}
+}
+
+func TestAPIKeysTeamRestrictedKey(t *testing.T) {
+	_, s, router := newTestAPI(t)
+
+	key := addTestAPIKey(t, s, types.APIKey{
+		ID: "k1", Name: "payments-admin", Scopes: []string{types.ScopeAdmin}, Teams: []string{"payments", "billing"},
+	})
+	addTestAPIKey(t, s, types.APIKey{ID: "k2", Name: "billing-ci", Scopes: []string{types.ScopeRead}, Teams: []string{"billing"}})
+	addTestAPIKey(t, s, types.APIKey{ID: "k3", Name: "other-ci", Scopes: []string{types.ScopeRead}, Teams: []string{"other"}})
+	addTestAPIKey(t, s, types.APIKey{ID: "k4", Name: "global-ci", Scopes: []string{types.ScopeRead}})
+
+	call := func(method string, target string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		req.Header.Set("Content-Type", "application/json")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{"create unrestricted", http.MethodPost, "/api/keys", `{"name": "a", "scopes": ["read"], "teams": []}`, http.StatusForbidden},
+		{"create without teams", http.MethodPost, "/api/keys", `{"name": "b", "scopes": ["admin"]}`, http.StatusForbidden},
+		{"create other team", http.MethodPost, "/api/keys", `{"name": "c", "scopes": ["read"], "teams": ["billing", "other"]}`, http.StatusForbidden},
+		{"revoke other team", http.MethodDelete, "/api/keys/k3", "", http.StatusNotFound},
+		{"revoke unrestricted", http.MethodDelete, "/api/keys/k4", "", http.StatusNotFound},
+		{"read audit log", http.MethodGet, "/api/audit", "", http.StatusForbidden},
+		{"create own team", http.MethodPost, "/api/keys", `{"name": "d", "scopes": ["read"], "teams": ["billing"]}`, http.StatusCreated},
+		{"revoke own team", http.MethodDelete, "/api/keys/k2", "", http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := call(tt.method, tt.target, tt.body); rec.Code != tt.want {
+				t.Fatalf("%s %s status = %d, want %d: %s", tt.method, tt.target, rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+
+	var keys []apiKeyJSON
+	if err := json.Unmarshal(call(http.MethodGet, "/api/keys", "").Body.Bytes(), &keys); err != nil {
+		t.Fatalf("error decoding the keys: %v", err)
+	}
+
+	names := make([]string, 0, len(keys))
+	for _, k := range keys {
+		names = append(names, k.Name)
+	}
+
+	if want := []string{"billing-ci", "d", "payments-admin"}; !slices.Equal(names, want) {
+		t.Errorf("keys = %v, want %v", names, want)
+	}
+
+	stored, _ := s.ListAPIKeys()
+	for _, k := range stored {
+		if (k.ID == "k3" || k.ID == "k4") && k.RevokedAt != nil {
+			t.Errorf("key %s revoked by a key of other teams", k.Name)
+		}
+	}
+}
+
+// The audit entries of the API and of the reconciliations record the projects
+// the way the API returns them.
+func TestProjectSnapshotMatchesAPI(t *testing.T) {
+	for _, project := range []types.Project{
+		{ID: "p1", Name: "shared", Host: "example.com", Port: "443", Type: "http"},
+		{
+			ID: "p2", Name: "managed", Host: "example.org", Port: "8443", Type: "smtp", AllowInsecure: true,
+			Tags: []string{"prod", "eu"}, Team: "payments", ManagedBy: "file",
+		},
+	} {
+		want, _ := json.Marshal(newProjectJSON(project))
+		got, _ := json.Marshal(audit.ProjectSnapshot(project))
+
+		var wantFields, gotFields map[string]any
+
+		json.Unmarshal(want, &wantFields)
+		json.Unmarshal(got, &gotFields)
+
+		if !reflect.DeepEqual(gotFields, wantFields) {
+			t.Errorf("ProjectSnapshot() = %s, want %s", got, want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"leblanc.io/open-go-ssl-checker/internal/audit"
+	"leblanc.io/open-go-ssl-checker/internal/auth"
+	"leblanc.io/open-go-ssl-checker/internal/checker"
+	"leblanc.io/open-go-ssl-checker/internal/store"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// testAPIKey is the legacy key of the test API, granted every scope.
+const testAPIKey = "test-key"
+
+// newTestAPI returns the router of the API served from an in-memory store.
+func newTestAPI(t *testing.T) (*AppContext, *store.MemoryStore, *mux.Router) {
+	t.Helper()
+
+	s := store.NewMemoryStore()
+	ac := &AppContext{
+		Store:   s,
+		Checker: checker.NewCertificateService(s, nil, 5*time.Second),
+		ApiKey:  testAPIKey,
+		Version: "test",
+		Audit:   audit.NewRecorder(s),
+	}
+
+	// Wait for the checks of the created projects
+	t.Cleanup(func() {
+		if err := ac.Checker.Shutdown(context.Background()); err != nil {
+			t.Errorf("Shutdown() error = %v", err)
+		}
+	})
+
+	router := mux.NewRouter()
+	ac.RegisterAPI(router)
+
+	return ac, s, router
+}
+
+// callAPI sends the request with the test API key and decodes the JSON
+// response into out, when not nil.
+func callAPI(t *testing.T, handler http.Handler, method string, target string, body io.Reader, out any) int {
+	t.Helper()
+
+	req := httptest.NewRequest(method, target, body)
+	req.Header.Set("X-API-Key", testAPIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: error decoding %q: %v", method, target, rec.Body.String(), err)
+		}
+	}
+
+	return rec.Code
+}
+
+// addTestAPIKey stores the API key and returns its secret.
+func addTestAPIKey(t *testing.T, s store.Store, apiKey types.APIKey) string {
+	t.Helper()
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("GenerateAPIKey() error = %v", err)
+	}
+
+	apiKey.Prefix, apiKey.Hash, apiKey.CreatedAt = prefix, hash, time.Now()
+	if err := s.AddAPIKey(apiKey); err != nil {
+		t.Fatalf("AddAPIKey() error = %v", err)
+	}
+
+	return key
+}
+
+func addTestProject(t *testing.T, s store.Store, project types.Project) {
+	t.Helper()
+
+	if project.Port == "" {
+		project.Port = "443"
+	}
+
+	if project.Type == "" {
+		project.Type = "http"
+	}
+
+	if err := s.AddProject(project); err != nil {
+		t.Fatalf("AddProject() error = %v", err)
+	}
+}
@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/logger"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// summaryJSON is the representation of the latest check of a project.
+type summaryJSON struct {
+	ProjectID     string     `json:"project_id"`
+	Name          string     `json:"name"`
+	Host          string     `json:"host"`
+	Port          int        `json:"port"`
+	Type          string     `json:"type"`
+	AllowInsecure bool       `json:"allow_insecure"`
+	Tags          []string   `json:"tags"`
+	Team          string     `json:"team"`
+	Status        string     `json:"status"`
+	CheckTime     *time.Time `json:"check_time"`
+	Domains       []string   `json:"domains"`
+	IP            string     `json:"ip"`
+	Issuer        string     `json:"issuer"`
+	ExpiryDate    string     `json:"expiry_date"`
+	DaysRemaining *int       `json:"days_remaining"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// checkJSON is the representation of a certificate check of the history.
+type checkJSON struct {
+	ID            int64     `json:"id"`
+	ProjectID     string    `json:"project_id"`
+	Status        string    `json:"status"`
+	CheckTime     time.Time `json:"check_time"`
+	Domains       []string  `json:"domains"`
+	IP            string    `json:"ip"`
+	Issuer        string    `json:"issuer"`
+	ExpiryDate    string    `json:"expiry_date"`
+	DaysRemaining int       `json:"days_remaining"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// page is the envelope of the paginated API responses.
+type page[T any] struct {
+	Items  []T `json:"items"`
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// splitDomains converts the stored domains of a check to a list. Failed checks
+// store the failure reason in place of the domains: it is returned as the error.
+func splitDomains(domains string, status string) ([]string, string) {
+	if status == types.StatusFailed {
+		return []string{}, strings.TrimPrefix(domains, "Failure: ")
+	}
+
+	list := []string{}
+
+	for _, domain := range strings.Split(domains, ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			list = append(list, domain)
+		}
+	}
+
+	return list, ""
+}
+
+func newSummaryJSON(s types.ProjectCheckSummary) summaryJSON {
+	port, _ := strconv.Atoi(s.Port)
+	status := s.Status()
+	domains, checkError := splitDomains(s.Domains, status)
+
+	return summaryJSON{
+		ProjectID:     s.ProjectID,
+		Name:          s.ProjectName,
+		Host:          s.Host,
+		Port:          port,
+		Type:          s.Type,
+		AllowInsecure: s.AllowInsecure,
+		Tags:          normalizeTags(s.Tags),
+		Team:          s.Team,
+		Status:        status,
+		CheckTime:     s.CheckTime,
+		Domains:       domains,
+		IP:            s.IP,
+		Issuer:        s.Issuer,
+		ExpiryDate:    s.ExpiryDate,
+		DaysRemaining: s.DaysRemaining,
+		Error:         checkError,
+	}
+}
+
+func newCheckJSON(c types.CertificateCheck) checkJSON {
+	status := c.Status()
+	domains, checkError := splitDomains(c.Domains, status)
+
+	return checkJSON{
+		ID:            c.ID,
+		ProjectID:     c.ProjectID,
+		Status:        status,
+		CheckTime:     c.CheckTime,
+		Domains:       domains,
+		IP:            c.IP,
+		Issuer:        c.Issuer,
+		ExpiryDate:    c.ExpiryDate,
+		DaysRemaining: c.DaysRemaining,
+		Error:         checkError,
+	}
+}
+
+// parsePagination reads the limit and offset query parameters.
+func parsePagination(r *http.Request) (int, int, string) {
+	limit := defaultPageLimit
+	offset := 0
+
+	if value := r.URL.Query().Get("limit"); value != "" {
+		l, err := strconv.Atoi(value)
+		if err != nil || l < 1 || l > maxPageLimit {
+			return 0, 0, "limit must be an integer between 1 and " + strconv.Itoa(maxPageLimit)
+		}
+
+		limit = l
+	}
+
+	if value := r.URL.Query().Get("offset"); value != "" {
+		o, err := strconv.Atoi(value)
+		if err != nil || o < 0 {
+			return 0, 0, "offset must be a positive integer"
+		}
+
+		offset = o
+	}
+
+	return limit, offset, ""
+}
+
+// paginate returns the requested page of items.
+func paginate[T any](items []T, limit int, offset int) page[T] {
+	p := page[T]{Items: []T{}, Total: len(items), Limit: limit, Offset: offset}
+
+	if offset < len(items) {
+		end := min(offset+limit, len(items))
+		p.Items = items[offset:end]
+	}
+
+	return p
+}
+
+// parseStatuses reads the comma separated status query parameter.
+func parseStatuses(r *http.Request) ([]string, string) {
+	value := r.URL.Query().Get("status")
+	if value == "" {
+		return nil, ""
+	}
+
+	valid := []string{
+		types.StatusUnchecked,
+		types.StatusFailed,
+		types.StatusExpired,
+		types.StatusCritical,
+		types.StatusWarning,
+		types.StatusOK,
+	}
+
+	var statuses []string
+
+	for _, status := range strings.Split(value, ",") {
+		status = strings.TrimSpace(status)
+		if !slices.Contains(valid, status) {
+			return nil, "status must be one of " + strings.Join(valid, ", ")
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, ""
+}
+
+// parseTime reads a RFC 3339 date (or a YYYY-MM-DD day, from its start) from
+// the query parameter.
+func parseTime(r *http.Request, name string) (*time.Time, string) {
+	return parseTimeBound(r, name, false)
+}
+
+// parseEndTime reads the upper bound of a time range like parseTime, but a
+// YYYY-MM-DD day is included until its end.
+func parseEndTime(r *http.Request, name string) (*time.Time, string) {
+	return parseTimeBound(r, name, true)
+}
+
+func parseTimeBound(r *http.Request, name string, end bool) (*time.Time, string) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, ""
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t, err = time.Parse(time.DateOnly, value)
+		if err != nil {
+			return nil, name + " must be a RFC 3339 date or a YYYY-MM-DD day"
+		}
+
+		if end {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+	}
+
+	return &t, ""
+}
+
+// ListStatusAPIHandler handles GET /api/status
+// It returns the latest check of each project, filtered by the optional query
+// parameters status (comma separated), max_days, type, tag and team.
+func (ac *AppContext) ListStatusAPIHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, msg := parsePagination(r)
+	if msg != "" {
+		writeJSONError(w, http.StatusBadRequest, msg)
+
+		return
+	}
+
+	statuses, msg := parseStatuses(r)
+	if msg != "" {
+		writeJSONError(w, http.StatusBadRequest, msg)
+
+		return
+	}
+
+	var maxDays *int
+
+	if value := r.URL.Query().Get("max_days"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "max_days must be an integer")
+
+			return
+		}
+
+		maxDays = &days
+	}
+
+	projectType := r.URL.Query().Get("type")
+	tag := r.URL.Query().Get("tag")
+	team, filterTeam := r.URL.Query().Get("team"), r.URL.Query().Has("team")
+
+	summaries, err := ac.Store.GetLatestChecksSummary()
+	if err != nil {
+		logger.Logger.Error("ListStatusAPIHandler error - GetLatestChecksSummary", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "unable to retrieve latest checks")
+
+		return
+	}
+
+	items := []summaryJSON{}
+
+	for _, s := range access(r).FilterSummaries(summaries) {
+		if statuses != nil && !slices.Contains(statuses, s.Status()) {
+			continue
+		}
+
+		if maxDays != nil && (s.DaysRemaining == nil || *s.DaysRemaining > *maxDays) {
+			continue
+		}
+
+		if projectType != "" && !strings.EqualFold(s.Type, projectType) {
+			continue
+		}
+
+		if tag != "" && !slices.Contains(s.Tags, tag) {
+			continue
+		}
+
+		if filterTeam && s.Team != team {
+			continue
+		}
+
+		items = append(items, newSummaryJSON(s))
+	}
+
+	writeJSON(w, http.StatusOK, paginate(items, limit, offset))
+}
+
+// GetProjectStatusAPIHandler handles GET /api/projects/{id}/status
+func (ac *AppContext) GetProjectStatusAPIHandler(w http.ResponseWriter, r *http.Request) {
+	project, ok := ac.loadAPIProject(w, r)
+	if !ok {
+		return
+	}
+
+	summaries, err := ac.Store.GetLatestChecksSummary()
+	if err != nil {
+		logger.Logger.Error("GetProjectStatusAPIHandler error - GetLatestChecksSummary", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "unable to retrieve latest check")
+
+		return
+	}
+
+	for _, s := range summaries {
+		if s.ProjectID == project.ID {
+			writeJSON(w, http.StatusOK, newSummaryJSON(s))
+
+			return
+		}
+	}
+
+	writeJSONError(w, http.StatusNotFound, "project not found")
+}
+
+// ListProjectChecksAPIHandler handles GET /api/projects/{id}/checks
+// It returns the check history of the project, most recent first, filtered by
+// the optional query parameters from, to and status (comma separated).
+func (ac *AppContext) ListProjectChecksAPIHandler(w http.ResponseWriter, r *http.Request) {
+	project, ok := ac.loadAPIProject(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset, msg := parsePagination(r)
+	if msg != "" {
+		writeJSONError(w, http.StatusBadRequest, msg)
+
+		return
+	}
+
+	statuses, msg := parseStatuses(r)
+	if msg != "" {
+		writeJSONError(w, http.StatusBadRequest, msg)
+
+		return
+	}
+
+	from, msg := parseTime(r, "from")
+	if msg != "" {
+		writeJSONError(w, http.StatusBadRequest, msg)
+
+		return
+	}
+
+	to, msg := parseEndTime(r, "to")
+	if msg != "" {
+		writeJSONError(w, http.StatusBadRequest, msg)
+
+		return
+	}
+
+	checks, err := ac.Store.GetCertificateChecksForProject(project.ID)
+	if err != nil {
+		logger.Logger.Error(
+			"ListProjectChecksAPIHandler error - GetCertificateChecksForProject",
+			"project_id", project.ID,
+			"error", err,
+		)
+		writeJSONError(w, http.StatusInternalServerError, "unable to retrieve check history")
+
+		return
+	}
+
+	items := []checkJSON{}
+
+	for _, c := range checks {
+		if from != nil && c.CheckTime.Before(*from) {
+			continue
+		}
+
+		if to != nil && c.CheckTime.After(*to) {
+			continue
+		}
+
+		if statuses != nil && !slices.Contains(statuses, c.Status()) {
+			continue
+		}
+
+		items = append(items, newCheckJSON(c))
+	}
+
+	writeJSON(w, http.StatusOK, paginate(items, limit, offset))
+}
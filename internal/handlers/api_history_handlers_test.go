@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+func TestListProjectChecksDayRange(t *testing.T) {
+	_, s, api := newTestAPI(t)
+	addTestProject(t, s, types.Project{ID: "p1", Name: "example", Host: "example.com"})
+
+	for i, checkTime := range []string{
+		"2026-01-30T23:59:59Z",
+		"2026-01-31T00:00:00Z",
+		"2026-01-31T23:59:59Z",
+		"2026-02-01T00:00:00Z",
+	} {
+		at, _ := time.Parse(time.RFC3339, checkTime)
+		check := types.CertificateCheck{ProjectID: "p1", CheckTime: at, DaysRemaining: 90 + i}
+
+		if err := s.AddCertificateCheck(check); err != nil {
+			t.Fatalf("AddCertificateCheck() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"from=2026-01-31&to=2026-01-31", 2},
+		{"to=2026-01-31", 3},
+		{"from=2026-01-31", 3},
+		{"to=2026-01-31T00:00:00Z", 2},
+	}
+
+	for _, tt := range tests {
+		var got page[checkJSON]
+
+		status := callAPI(t, api, http.MethodGet, "/api/projects/p1/checks?"+tt.query, nil, &got)
+		if status != http.StatusOK || got.Total != tt.want {
+			t.Errorf("%s: status %d, %d checks, want 200 and %d checks", tt.query, status, got.Total, tt.want)
+		}
+	}
+}
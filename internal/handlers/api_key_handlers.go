@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"leblanc.io/open-go-ssl-checker/internal/audit"
+	"leblanc.io/open-go-ssl-checker/internal/auth"
+	"leblanc.io/open-go-ssl-checker/internal/logger"
+	"leblanc.io/open-go-ssl-checker/internal/store"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// apiKeyJSON is the representation of an API key. The key itself is never
+// returned, except once in apiKeyCreatedJSON.
+type apiKeyJSON struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	Teams      []string   `json:"teams"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// apiKeyCreatedJSON is the response of POST /api/keys.
+type apiKeyCreatedJSON struct {
+	apiKeyJSON
+
+	Key string `json:"key"`
+}
+
+// apiKeyRequest is the body expected by POST /api/keys.
+type apiKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	Teams     []string   `json:"teams,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func newAPIKeyJSON(k types.APIKey) apiKeyJSON {
+	return apiKeyJSON{
+		ID:         k.ID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     k.Scopes,
+		Teams:      normalizeTags(k.Teams),
+		CreatedAt:  k.CreatedAt,
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+	}
+}
+
+// ListAPIKeysAPIHandler handles GET /api/keys
+// The keys restricted to teams only list the keys of their teams.
+func (ac *AppContext) ListAPIKeysAPIHandler(w http.ResponseWriter, r *http.Request) {
+	access := access(r)
+
+	keys, err := ac.Store.ListAPIKeys()
+	if err != nil {
+		logger.Logger.Error("ListAPIKeysAPIHandler error - ListAPIKeys", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "unable to retrieve API keys")
+
+		return
+	}
+
+	res := make([]apiKeyJSON, 0, len(keys))
+	for _, k := range keys {
+		if access.CanGrant(k.Teams) {
+			res = append(res, newAPIKeyJSON(k))
+		}
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+// CreateAPIKeyAPIHandler handles POST /api/keys
+// It expects a JSON body with fields: name, scopes (list), teams (optional list restricting
+// the key to the projects of these teams), expires_at (optional RFC 3339 date).
+// The keys restricted to teams only create keys restricted to some of their
+// teams.
+func (ac *AppContext) CreateAPIKeyAPIHandler(w http.ResponseWriter, r *http.Request) {
+	var req apiKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+
+		return
+	}
+
+	if req.Name == "" {
+		writeJSONError(w, http.StatusBadRequest, "name is required")
+
+		return
+	}
+
+	scopes, err := auth.ParseScopes(strings.Join(req.Scopes, ","))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		writeJSONError(w, http.StatusBadRequest, "expires_at must be in the future")
+
+		return
+	}
+
+	teams := normalizeTags(req.Teams)
+	if !access(r).CanGrant(teams) {
+		writeJSONError(w, http.StatusForbidden, "API key restricted to teams cannot create keys for other teams")
+
+		return
+	}
+
+	apiKey, key, err := auth.CreateAPIKey(ac.Store, req.Name, scopes, teams, req.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, store.ErrDuplicateAPIKeyName) {
+			writeJSONError(w, http.StatusConflict, "an API key with this name already exists")
+
+			return
+		}
+
+		logger.Logger.Error("CreateAPIKeyAPIHandler error - CreateAPIKey", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "unable to create API key")
+
+		return
+	}
+
+	ac.Audit.Record(
+		auditActor(r),
+		types.AuditAPIKeyCreate,
+		audit.Target{Type: audit.TargetAPIKey, ID: apiKey.ID, Name: apiKey.Name},
+		nil,
+		audit.APIKeySnapshot(*apiKey),
+	)
+	logger.Logger.Info(
+		"API key created",
+		"name", apiKey.Name,
+		"prefix", apiKey.Prefix,
+		"scopes", strings.Join(scopes, ","),
+	)
+	writeJSON(w, http.StatusCreated, apiKeyCreatedJSON{apiKeyJSON: newAPIKeyJSON(*apiKey), Key: key})
+}
+
+// RevokeAPIKeyAPIHandler handles DELETE /api/keys/{id}
+// The API key is kept (for the last used date) but can no longer be used. The
+// keys restricted to teams only revoke the keys of their teams.
+func (ac *AppContext) RevokeAPIKeyAPIHandler(w http.ResponseWriter, r *http.Request) {
+	keyID := mux.Vars(r)["id"]
+
+	if access := access(r); !access.All {
+		keys, err := ac.Store.ListAPIKeys()
+		if err != nil {
+			logger.Logger.Error("RevokeAPIKeyAPIHandler error - ListAPIKeys", "error", err)
+			writeJSONError(w, http.StatusInternalServerError, "unable to revoke API key")
+
+			return
+		}
+
+		// The keys of the other teams are not found, like the missing ones
+		i := slices.IndexFunc(keys, func(k types.APIKey) bool { return k.ID == keyID })
+		if i < 0 || !access.CanGrant(keys[i].Teams) {
+			writeJSONError(w, http.StatusNotFound, "API key not found")
+
+			return
+		}
+	}
+
+	if err := ac.Store.RevokeAPIKey(keyID, time.Now()); err != nil {
+		if errors.Is(err, store.ErrAPIKeyNotFound) {
+			writeJSONError(w, http.StatusNotFound, "API key not found")
+
+			return
+		}
+
+		logger.Logger.Error("RevokeAPIKeyAPIHandler error - RevokeAPIKey", "api_key_id", keyID, "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "unable to revoke API key")
+
+		return
+	}
+
+	ac.Audit.Record(auditActor(r), types.AuditAPIKeyRevoke, audit.Target{Type: audit.TargetAPIKey, ID: keyID}, nil, nil)
+	logger.Logger.Info("API key revoked", "api_key_id", keyID)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"maps"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"leblanc.io/open-go-ssl-checker/internal/middleware"
+	"leblanc.io/open-go-ssl-checker/internal/openapi"
+	"leblanc.io/open-go-ssl-checker/internal/projectio"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// APIRoute is an API endpoint and its handler. The same table is used to
+// register the routes and to generate the OpenAPI document, so the document
+// always matches the served endpoints.
+type APIRoute struct {
+	openapi.Endpoint
+
+	// Scope is the API key scope required to call the endpoint.
+	Scope   string
+	Handler http.HandlerFunc
+}
+
+var (
+	paginationParams = []openapi.Param{
+		{Name: "limit", Type: "integer", Description: "Maximum number of items (default 100, max 1000)"},
+		{Name: "offset", Type: "integer", Description: "Number of items to skip"},
+	}
+	statusParam = openapi.Param{
+		Name:        "status",
+		Type:        "string",
+		Description: "Comma separated list of statuses: unchecked, failed, expired, critical, warning, ok",
+	}
+)
+
+// APIRoutes returns the API endpoints, relative to the /api prefix.
+func (ac *AppContext) APIRoutes() []APIRoute {
+	return []APIRoute{
+		{
+			Endpoint: openapi.Endpoint{
+				Method:  http.MethodGet,
+				Path:    "/projects",
+				Summary: "List the projects",
+				Responses: map[int]any{
+					http.StatusOK: []projectJSON{},
+				},
+			},
+			Scope:   types.ScopeRead,
+			Handler: ac.ListProjectsAPIHandler,
+		},
+		{
+			Endpoint: openapi.Endpoint{
+				Method:  http.MethodPost,
+				Path:    "/projects",
+				Summary: "Create a project and check its certificate in background",
+				Request: projectRequest{},
+				Responses: map[int]any{
+					http.StatusCreated:    createdJSON{},
+					http.StatusBadRequest: errorJSON{},
+					http.StatusConflict:   errorJSON{},
+				},
+			},
+			Scope:   types.ScopeWrite,
+			Handler: ac.AddProjectAPIHandler,
+		},
+		{
+			Endpoint: openapi.Endpoint{
+				Method: http.MethodPost,
+				Path:   "/projects/import",
+				Summary: "Import a CSV, JSON or YAML list of projects, matched by name, in a single transaction. " +
+					"The body is the file, JSON is described here",
+				Query: []openapi.Param{
+					{Name: "format", Type: "string", Description: "csv, json or yaml, detected from the Content-Type by default"},
+					{Name: "dry_run", Type: "boolean", Description: "Only report the changes, without applying them"},
+				},
+				Request: []projectio.Entry{},
+				Responses: map[int]any{
+					http.StatusOK:                  importJSON{},
+					http.StatusBadRequest:          errorJSON{},
+					http.StatusUnprocessableEntity: importJSON{},
+				},
+			},
+			Scope:   types.ScopeWrite,
+			Handler: ac.ImportProjectsAPIHandler,
+		},
+		{
+			Endpoint: openapi.Endpoint{
+				Method:  http.MethodGet,
+				Path:    "/projects/export",
+				Summary: "Export the projects as a CSV, JSON or YAML file which can be imported back",
+				Query: []openapi.Param{
+					{Name: "format", Type: "string", Description: "csv (default), json or yaml"},
+				},
+				Responses: map[int]any{
+					http.StatusOK:         []projectio.Entry{},
+					http.StatusBadRequest: errorJSON{},
+				},
+			},
+			Scope:   types.ScopeRead,
+			Handler: ac.ExportProjectsAPIHandler,
+		},
+		{
+			Endpoint: openapi.Endpoint{
+				Method:  http.MethodGet,
+				Path:    "/checks/export",
+				Summary: "Export the check history of a project, or of every project, most recent first",
+				Query: []openapi.Param{
+					{Name: "format", Type: "string", Description: "csv (default) or json"},
+					{Name: "project", Type: "string", Description: "Project id, every project by default"},
+					statusParam,
+					{Name: "from", Type: "string", Description: "RFC 3339 date or YYYY-MM-DD day (UTC), from its start"},
+					{Name: "to", Type: "string", Description: "RFC 3339 date or YYYY-MM-DD day (UTC), included until its end"},
+				},
+				Responses: map[int]any{
+					http.StatusOK:         []checkExportJSON{},
+					http.StatusBadRequest: errorJSON{},
+					http.StatusNotFound:   errorJSON{},
+				},
+			},
+			Scope:   types.ScopeRead,
+			Handler: ac.ExportChecksAPIHandler,
+		},
+		{
+			Endpoint: openapi.Endpoint{
+				Method:  http.MethodGet,
+				Path:    "/projects/{id}",
+				Summary: "Get a project",
+				Responses: map[int]any{
+					http.StatusOK:       projectJSON{},
+					http.StatusNotFound: errorJSON{},
+				},
+			},
+			Scope:   types.ScopeRead,
+			Handler: ac.GetProjectAPIHandler,
+		},
+		{
+			Endpoint: openapi.Endpoint{
+				Method:  http.MethodPut,
+				Path:    "/projects/{id}",
+				Summary: "Replace a project",
+				Request: projectRequest{},
+				Responses: map[int]any{
+					http.StatusOK:         projectJSON{},
+					http.StatusBadRequest: errorJSON{},
+					http.StatusNotFound:   errorJSON{},
+					http.StatusConflict:   errorJSON{},
+				},
+			},
+			Scope:   types.ScopeWrite,
+			Handler: ac.UpdateProjectAPIHandler,
+		},
+		{
+			Endpoint: openapi.Endpoint{
+				Method:  http.MethodPatch,
+				Path:    "/projects/{id}",
+				Summary: "Update the given fields of a project",
+				Request: projectPatchRequest{},
+				Responses: map[int]any{
+					http.StatusOK:         projectJSON{},
+					http.StatusBadRequest: errorJSON{},
+					http.StatusNotFound:   errorJSON{},
+					http.StatusConflict:   errorJSON{},
+				},
+			},
+			Scope:   types.ScopeWrite,
+			Handler: ac.PatchProjectAPIHandler,
+		},
+		{
+			Endpoint: openapi.Endpoint{
+				Method:  http.MethodDelete,
+				Path:    "/projects/{id}",
+				Summary: "Delete a project and its history",
+				Responses: map[int]any{
+					http.StatusNoContent: nil,
+					http.StatusNotFound:  errorJSON{},
+					http.StatusConflict:  errorJSON{},
+				},
+			},
+			Scope:   types.ScopeWrite,
+			Handler: ac.DeleteProjectAPIHandler,
+		},
+		{
+			Endpoint: openapi.Endpoint{
+				Method:  http.MethodGet,
+				Path:    "/projects/{id}/status",
+				Summary: "Get the latest check of a project",
+				Responses: map[int]any{
+					http.StatusOK:       summaryJSON{},
+					http.StatusNotFound: errorJSON{},
+				},
+			},
+			Scope:   types.ScopeRead,
+			Handler: ac.GetProjectStatusAPIHandler,
+		},
+		{
+			Endpoint: openapi.Endpoint{
+				Method:  http.MethodGet,
+				Path:    "/projects/{id}/checks",
+				Summary: "List the check history of a project, most recent first",
+				Query: append([]openapi.Param{
+					statusParam,
+					{Name: "from", Type: "string", Description: "RFC 3339 date or YYYY-MM-DD day (UTC), from its start"},
+					{Name: "to", Type: "string", Description: "RFC 3339 date or YYYY-MM-DD day (UTC), included until its end"},
+				}, paginationParams...),
+				Responses: map[int]any{
+					http.StatusOK:         page[checkJSON]{},
+					http.StatusBadRequest: errorJSON{},
+					http.StatusNotFound:   errorJSON{},
+				},
+			},
+			Scope:   types.ScopeRead,
+			Handler: ac.ListProjectChecksAPIHandler,
+		},
+		{
+			Endpoint: openapi.Endpoint{
+				Method:  http.MethodPost,
+				Path:    "/projects/{id}/check",
+				Summary: "Check the certificate of a project now and store the result",
+				Responses: map[int]any{
+					http.StatusOK:             checkResultJSON{},
+					http.StatusNotFound:       errorJSON{},
+					http.StatusBadGateway:     checkErrorJSON{},
+					http.StatusGatewayTimeout: checkErrorJSON{},
+				},
+			},
+			Scope:   types.ScopeCheck,
+			Handler: ac.CheckProjectAPIHandler,
+		},
+		{
+			Endpoint: openapi.Endpoint{
+				Method:  http.MethodGet,
+				Path:    "/status",
+				Summary: "List the latest check of every project",
+				Query: append([]openapi.Param{
+					statusParam,
+					{Name: "max_days", Type: "integer", Description: "Maximum number of days remaining"},
+					{Name: "type", Type: "string", Description: "Project type"},
+					{Name: "tag", Type: "string", Description: "Project tag"},
+					{Name: "team", Type: "string", Description: "Project team (empty for the shared projects)"},
+				}, paginationParams...),
+				Responses: map[int]any{
+					http.StatusOK:         page[summaryJSON]{},
+					http.StatusBadRequest: errorJSON{},
+				},
+			},
+			Scope:   types.ScopeRead,
+			Handler: ac.ListStatusAPIHandler,
+		},
+		{
+			Endpoint: openapi.Endpoint{
+				Method:  http.MethodPost,
+				Path:    "/check",
+				Summary: "Check the certificate of any host now, without storing the result",
+				Request: adHocCheckRequest{},
+				Responses: map[int]any{
+					http.StatusOK:             certificateJSON{},
+					http.StatusBadRequest:     errorJSON{},
+					http.StatusBadGateway:     checkErrorJSON{},
+					http.StatusGatewayTimeout: checkErrorJSON{},
+				},
+			},
+			Scope:   types.ScopeCheck,
+			Handler: ac.AdHocCheckAPIHandler,
+		},
+		{
+			Endpoint: openapi.Endpoint{
+				Method:  http.MethodGet,
+				Path:    "/candidates",
+				Summary: "List the endpoints discovered in the Certificate Transparency logs and the DNS zones, most recent first",
+				Query: []openapi.Param{
+					{
+						Name:        "status",
+						Type:        "string",
+						Description: "pending (default), ignored, approved, or all",
+					},
+				},
+				Responses: map[int]any{
+					http.StatusOK:         []candidateJSON{},
+					http.StatusBadRequest: errorJSON{},
+				},
+			},
+			Scope:   types.ScopeRead,
+			Handler: ac.ListCandidatesAPIHandler,
+		},
+		{
+			Endpoint: openapi.Endpoint{
+				Method:  http.MethodPost,
+				Path:    "/candidates/{id}/approve",
+				Summary: "Create the project of a discovered host and check its certificate in background",
+				Responses: map[int]any{
+					http.StatusCreated:  projectJSON{},
+					http.StatusNotFound: errorJSON{},
+					http.StatusConflict: errorJSON{},
+				},
+			},
+			Scope:   types.ScopeWrite,
+			Handler: ac.ApproveCandidateAPIHandler,
+		},
+		{
+			Endpoint: openapi.Endpoint{
+				Method:  http.MethodPost,
+				Path:    "/candidates/{id}/ignore",
+				Summary: "Stop proposing a discovered host",
+				Responses: map[int]any{
+					http.StatusOK:       candidateJSON{},
+					http.StatusNotFound: errorJSON{},
+					http.StatusConflict: errorJSON{},
+				},
+			},
+			Scope:   types.ScopeWrite,
+			Handler: ac.IgnoreCandidateAPIHandler,
+		},
+		{
+			Endpoint: openapi.Endpoint{
+				Method:  http.MethodGet,
+				Path:    "/keys",
+				Summary: "List the API keys",
+				Responses: map[int]any{
+					http.StatusOK: []apiKeyJSON{},
+				},
+			},
+			Scope:   types.ScopeAdmin,
+			Handler: ac.ListAPIKeysAPIHandler,
+		},
+		{
+			Endpoint: openapi.Endpoint{
+				Method:  http.MethodPost,
+				Path:    "/keys",
+				Summary: "Create an API key, the key is only returned in this response",
+				Request: apiKeyRequest{},
+				Responses: map[int]any{
+					http.StatusCreated:    apiKeyCreatedJSON{},
+					http.StatusBadRequest: errorJSON{},
+					http.StatusConflict:   errorJSON{},
+				},
+			},
+			Scope:   types.ScopeAdmin,
+			Handler: ac.CreateAPIKeyAPIHandler,
+		},
+		{
+			Endpoint: openapi.Endpoint{
+				Method:  http.MethodDelete,
+				Path:    "/keys/{id}",
+				Summary: "Revoke an API key",
+				Responses: map[int]any{
+					http.StatusNoContent: nil,
+					http.StatusNotFound:  errorJSON{},
+				},
+			},
+			Scope:   types.ScopeAdmin,
+			Handler: ac.RevokeAPIKeyAPIHandler,
+		},
+		{
+			Endpoint: openapi.Endpoint{
+				Method:  http.MethodGet,
+				Path:    "/audit",
+				Summary: "Audit log of the changes, most recent first",
+				Query: append([]openapi.Param{
+					{Name: "actor", Type: "string", Description: "Username, or api-key:<name> for an API key"},
+					{Name: "action", Type: "string", Description: "Action, e.g. project.update"},
+					{Name: "target", Type: "string", Description: "Identifier of the changed project, API key or user"},
+					{Name: "since", Type: "string", Description: "Only the changes made since this RFC 3339 date"},
+				}, paginationParams...),
+				Responses: map[int]any{
+					http.StatusOK:         page[auditEntryJSON]{},
+					http.StatusBadRequest: errorJSON{},
+				},
+			},
+			Scope:   types.ScopeAdmin,
+			Handler: ac.ListAuditAPIHandler,
+		},
+	}
+}
+
+// RegisterAPI registers the API endpoints, protected by the API keys, and
+// their OpenAPI document.
+func (ac *AppContext) RegisterAPI(router *mux.Router) {
+	router.HandleFunc("/api/openapi.json", ac.OpenAPIHandler).Methods(http.MethodGet)
+
+	api := router.PathPrefix("/api").Subrouter()
+	api.Use(middleware.TraceContextMiddleware)
+
+	for _, route := range ac.APIRoutes() {
+		api.Handle(route.Path, ac.APIKeyMiddleware(route.Scope, route.Handler)).Methods(route.Method)
+	}
+}
+
+// OpenAPIHandler handles GET /api/openapi.json
+func (ac *AppContext) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	routes := ac.APIRoutes()
+	endpoints := make([]openapi.Endpoint, 0, len(routes))
+
+	for _, route := range routes {
+		e := route.Endpoint
+		e.Path = "/api" + e.Path
+
+		// Every endpoint is protected by the API key middleware
+		e.Summary += " (scope: " + route.Scope + ")"
+		e.Responses = make(map[int]any, len(route.Responses)+2)
+		maps.Copy(e.Responses, route.Responses)
+		e.Responses[http.StatusUnauthorized] = errorJSON{}
+		e.Responses[http.StatusForbidden] = errorJSON{}
+
+		endpoints = append(endpoints, e)
+	}
+
+	writeJSON(w, http.StatusOK, openapi.Build("OpenGoSSLChecker API", ac.Version, endpoints))
+}
@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"leblanc.io/open-go-ssl-checker/internal/openapi"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// contractCase is a request to an endpoint of the OpenAPI document.
+type contractCase struct {
+	method string
+	// path is the path of the endpoint in the document.
+	path   string
+	target string
+	body   string
+	status int
+}
+
+// TestAPIContract checks that the router serves exactly the endpoints of the
+// OpenAPI document, and that their responses match it.
+func TestAPIContract(t *testing.T) {
+	_, s, router := newTestAPI(t)
+
+	doc := fetchOpenAPI(t, router)
+
+	documented := map[string]bool{}
+
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	served := servedRoutes(t, router)
+
+	for _, route := range served {
+		if !documented[route] {
+			t.Errorf("%s is served but not documented", route)
+		}
+	}
+
+	for route := range documented {
+		if !slices.Contains(served, route) {
+			t.Errorf("%s is documented but not served", route)
+		}
+	}
+
+	// A TLS server for the certificate checks
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(tlsServer.Close)
+
+	_, port, _ := net.SplitHostPort(tlsServer.Listener.Addr().String())
+
+	for _, id := range []string{"p1", "p2", "p3"} {
+		addTestProject(t, s, types.Project{
+			ID: id, Name: "project-" + id, Host: "127.0.0.1", Port: port, AllowInsecure: true, Tags: []string{"web"},
+		})
+	}
+
+	if err := s.AddCertificateCheck(types.CertificateCheck{
+		ProjectID: "p1", CheckTime: time.Now(), Domains: "example.com", ExpiryDate: "2030-01-01", DaysRemaining: 90,
+	}); err != nil {
+		t.Fatalf("AddCertificateCheck() error = %v", err)
+	}
+
+	for _, id := range []string{"c1", "c2"} {
+		if err := s.AddCandidate(types.Candidate{
+			ID: id, Host: id + ".example.com", Port: "443", Type: "http", Source: "ct",
+			FirstSeen: time.Now(), LastSeen: time.Now(), Status: types.CandidatePending,
+		}); err != nil {
+			t.Fatalf("AddCandidate() error = %v", err)
+		}
+	}
+
+	if err := s.AddAPIKey(types.APIKey{ID: "k1", Name: "ci", Prefix: "abc", Scopes: []string{types.ScopeRead}}); err != nil {
+		t.Fatalf("AddAPIKey() error = %v", err)
+	}
+
+	checkBody := fmt.Sprintf(`{"host":"127.0.0.1","port":%s,"type":"http","allow_insecure":true}`, port)
+
+	cases := []contractCase{
+		{"GET", "/api/projects", "/api/projects", "", http.StatusOK},
+		{"POST", "/api/projects", "/api/projects", `{"name":"created","host":"127.0.0.1","port":` + port +
+			`,"type":"http","allow_insecure":true}`, http.StatusCreated},
+		{"POST", "/api/projects", "/api/projects", `{"name":""}`, http.StatusBadRequest},
+		{
+			"POST", "/api/projects/import", "/api/projects/import?dry_run=true",
+			`[{"name":"imported","host":"example.com","port":443,"type":"http"}]`, http.StatusOK,
+		},
+		{"GET", "/api/projects/export", "/api/projects/export?format=json", "", http.StatusOK},
+		{"GET", "/api/checks/export", "/api/checks/export?format=json", "", http.StatusOK},
+		{"GET", "/api/projects/{id}", "/api/projects/p1", "", http.StatusOK},
+		{"GET", "/api/projects/{id}", "/api/projects/unknown", "", http.StatusNotFound},
+		{"PUT", "/api/projects/{id}", "/api/projects/p2", `{"name":"renamed","host":"127.0.0.1","port":` + port +
+			`,"type":"http","allow_insecure":true}`, http.StatusOK},
+		{"PATCH", "/api/projects/{id}", "/api/projects/p2", `{"tags":["api"]}`, http.StatusOK},
+		{"DELETE", "/api/projects/{id}", "/api/projects/p3", "", http.StatusNoContent},
+		{"GET", "/api/projects/{id}/status", "/api/projects/p1/status", "", http.StatusOK},
+		{"GET", "/api/projects/{id}/checks", "/api/projects/p1/checks", "", http.StatusOK},
+		{"GET", "/api/projects/{id}/checks", "/api/projects/p1/checks?from=yesterday", "", http.StatusBadRequest},
+		{"POST", "/api/projects/{id}/check", "/api/projects/p1/check", "", http.StatusOK},
+		{"GET", "/api/status", "/api/status?status=ok,unchecked", "", http.StatusOK},
+		{"POST", "/api/check", "/api/check", checkBody, http.StatusOK},
+		{"GET", "/api/candidates", "/api/candidates", "", http.StatusOK},
+		{"POST", "/api/candidates/{id}/approve", "/api/candidates/c1/approve", "", http.StatusCreated},
+		{"POST", "/api/candidates/{id}/ignore", "/api/candidates/c2/ignore", "", http.StatusOK},
+		{"GET", "/api/keys", "/api/keys", "", http.StatusOK},
+		{"POST", "/api/keys", "/api/keys", `{"name":"created","scopes":["read"]}`, http.StatusCreated},
+		{"DELETE", "/api/keys/{id}", "/api/keys/k1", "", http.StatusNoContent},
+		{"GET", "/api/audit", "/api/audit", "", http.StatusOK},
+	}
+
+	for route := range documented {
+		if !slices.ContainsFunc(cases, func(c contractCase) bool { return c.method+" "+c.path == route }) {
+			t.Errorf("%s has no contract case", route)
+		}
+	}
+
+	for _, c := range cases {
+		t.Run(c.method+" "+c.target, func(t *testing.T) {
+			operation := doc.Paths[c.path][strings.ToLower(c.method)]
+			if operation == nil {
+				t.Fatalf("%s %s is not documented", c.method, c.path)
+			}
+
+			req := httptest.NewRequest(c.method, c.target, bytes.NewBufferString(c.body))
+			req.Header.Set("X-API-Key", testAPIKey)
+			req.Header.Set("Content-Type", "application/json")
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != c.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, c.status, rec.Body.String())
+			}
+
+			response := operation.Responses[strconv.Itoa(rec.Code)]
+			if response == nil {
+				t.Fatalf("status %d is not documented", rec.Code)
+			}
+
+			media, ok := response.Content["application/json"]
+			if !ok {
+				if rec.Body.Len() != 0 {
+					t.Fatalf("undocumented body %q", rec.Body.String())
+				}
+
+				return
+			}
+
+			var body any
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("error decoding %q: %v", rec.Body.String(), err)
+			}
+
+			for _, err := range validateSchema(doc, media.Schema, body, "body") {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func fetchOpenAPI(t *testing.T, router http.Handler) openapi.Document {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+
+	var doc openapi.Document
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("error decoding the OpenAPI document: %v", err)
+	}
+
+	return doc
+}
+
+// servedRoutes returns the "METHOD /path" of the API routes of the router,
+// except the OpenAPI document itself.
+func servedRoutes(t *testing.T, router *mux.Router) []string {
+	t.Helper()
+
+	var routes []string
+
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		// The prefix of the subrouter has no method, it is not an endpoint
+		path, _ := route.GetPathTemplate()
+		methods, _ := route.GetMethods()
+
+		if path == "/api/openapi.json" {
+			return nil
+		}
+
+		for _, method := range methods {
+			routes = append(routes, method+" "+path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error walking the router: %v", err)
+	}
+
+	return routes
+}
+
+// validateSchema returns the differences between the decoded JSON value and
+// the schema. The properties missing from the schema are reported too.
+func validateSchema(doc openapi.Document, schema *openapi.Schema, value any, at string) []error {
+	if schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+
+		resolved, ok := doc.Components.Schemas[name]
+		if !ok {
+			return []error{fmt.Errorf("%s: unknown schema %s", at, schema.Ref)}
+		}
+
+		// A reference cannot be nullable in OpenAPI 3.0, pointers to structs
+		// are references
+		if value == nil {
+			return nil
+		}
+
+		return validateSchema(doc, resolved, value, at)
+	}
+
+	if value == nil {
+		if schema.Nullable || schema.Type == "" {
+			return nil
+		}
+
+		return []error{fmt.Errorf("%s: null, want %s", at, schema.Type)}
+	}
+
+	var errs []error
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return []error{fmt.Errorf("%s: %T, want object", at, value)}
+		}
+
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				errs = append(errs, fmt.Errorf("%s: missing required property %s", at, name))
+			}
+		}
+
+		for name, property := range object {
+			propertySchema := schema.Properties[name]
+			if propertySchema == nil {
+				propertySchema = schema.AdditionalProperties
+			}
+
+			if propertySchema == nil {
+				errs = append(errs, fmt.Errorf("%s: undocumented property %s", at, name))
+
+				continue
+			}
+
+			errs = append(errs, validateSchema(doc, propertySchema, property, at+"."+name)...)
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return []error{fmt.Errorf("%s: %T, want array", at, value)}
+		}
+
+		for i, item := range items {
+			errs = append(errs, validateSchema(doc, schema.Items, item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return []error{fmt.Errorf("%s: %T, want string", at, value)}
+		}
+
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a date-time", at, s))
+			}
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != math.Trunc(n) {
+			errs = append(errs, fmt.Errorf("%s: %v, want integer", at, value))
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			errs = append(errs, fmt.Errorf("%s: %T, want number", at, value))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			errs = append(errs, fmt.Errorf("%s: %T, want boolean", at, value))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/audit"
+	"leblanc.io/open-go-ssl-checker/internal/auth"
+	"leblanc.io/open-go-ssl-checker/internal/logger"
+	"leblanc.io/open-go-ssl-checker/internal/template"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+const auditPageSize = 50
+
+// auditEntryJSON is the representation of an audit log entry. Before and after
+// are the JSON snapshots of the target.
+type auditEntryJSON struct {
+	ID         int64          `json:"id"`
+	Time       time.Time      `json:"time"`
+	Actor      string         `json:"actor"`
+	Source     string         `json:"source"`
+	RemoteAddr string         `json:"remote_addr"`
+	Action     string         `json:"action"`
+	TargetType string         `json:"target_type"`
+	TargetID   string         `json:"target_id"`
+	TargetName string         `json:"target_name"`
+	Before     map[string]any `json:"before"`
+	After      map[string]any `json:"after"`
+}
+
+// decodeSnapshot decodes a stored JSON snapshot, nil when empty.
+func decodeSnapshot(snapshot string) map[string]any {
+	if snapshot == "" {
+		return nil
+	}
+
+	var value map[string]any
+	if err := json.Unmarshal([]byte(snapshot), &value); err != nil {
+		logger.Logger.Error("decodeSnapshot error - Unmarshal", "error", err)
+
+		return nil
+	}
+
+	return value
+}
+
+type auditPage struct {
+	Entries    []types.AuditEntry
+	Actions    []string
+	Actor      string
+	Action     string
+	Target     string
+	Total      int
+	PrevURL    string
+	NextURL    string
+	FirstIndex int
+	LastIndex  int
+}
+
+// auditActions lists the actions offered in the filters.
+var auditActions = []string{
+	types.AuditProjectCreate,
+	types.AuditProjectUpdate,
+	types.AuditProjectDelete,
+	types.AuditProjectCheck,
+	types.AuditRefresh,
+	types.AuditAPIKeyCreate,
+	types.AuditAPIKeyRevoke,
+	types.AuditUserCreate,
+	types.AuditUserUpdate,
+	types.AuditUserDelete,
+	types.AuditCandidateIgnore,
+}
+
+// auditActor returns who makes the request: the API key, the logged in user,
+// or "anonymous" when the web UI authentication is disabled.
+func auditActor(r *http.Request) audit.Actor {
+	actor := audit.Actor{Name: "anonymous", Source: types.AuditSourceWeb, RemoteAddr: r.RemoteAddr}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		actor.RemoteAddr = host
+	}
+
+	if key := auth.APIKeyFromContext(r.Context()); key != nil {
+		actor.Name = "api-key:" + key.Name
+		actor.Source = types.AuditSourceAPI
+	} else if viewer := auth.ViewerFromContext(r.Context()); viewer.User != nil {
+		actor.Name = viewer.User.Username
+	}
+
+	return actor
+}
+
+func projectTarget(p types.Project) audit.Target {
+	return audit.Target{Type: audit.TargetProject, ID: p.ID, Name: p.Name}
+}
+
+// auditProject records a change of a project, before is nil for a creation
+// and after for a deletion.
+func (ac *AppContext) auditProject(r *http.Request, action string, before *types.Project, after *types.Project) {
+	var (
+		target         types.Project
+		beforeSnapshot any
+		afterSnapshot  any
+	)
+
+	if before != nil {
+		target = *before
+		beforeSnapshot = audit.ProjectSnapshot(*before)
+	}
+
+	if after != nil {
+		target = *after
+		afterSnapshot = audit.ProjectSnapshot(*after)
+	}
+
+	ac.Audit.Record(auditActor(r), action, projectTarget(target), beforeSnapshot, afterSnapshot)
+}
+
+// RefreshAuditor returns the function recording the refresh requests sent
+// through the WebSocket opened by the request.
+func (ac *AppContext) RefreshAuditor(r *http.Request) func() {
+	actor := auditActor(r)
+	actor.Source = types.AuditSourceWebSocket
+
+	return func() {
+		ac.Audit.Record(actor, types.AuditRefresh, audit.Target{}, nil, nil)
+	}
+}
+
+// parseAuditFilter reads the actor, action, target, since, limit and offset
+// query parameters.
+func parseAuditFilter(r *http.Request, defaultLimit int) (types.AuditFilter, string) {
+	filter := types.AuditFilter{
+		Actor:    r.URL.Query().Get("actor"),
+		Action:   r.URL.Query().Get("action"),
+		TargetID: r.URL.Query().Get("target"),
+	}
+
+	since, msg := parseTime(r, "since")
+	if msg != "" {
+		return filter, msg
+	}
+
+	filter.Since = since
+
+	limit, offset, msg := parsePagination(r)
+	if msg != "" {
+		return filter, msg
+	}
+
+	if !r.URL.Query().Has("limit") {
+		limit = defaultLimit
+	}
+
+	filter.Limit, filter.Offset = limit, offset
+
+	return filter, ""
+}
+
+// AuditHandler shows the audit log to the admins. The log records the
+// changes of every team, so it is refused to the access restricted to teams.
+func (ac *AppContext) AuditHandler(w http.ResponseWriter, r *http.Request) {
+	if !access(r).All {
+		http.Error(w, "You cannot read the audit log.", http.StatusForbidden)
+
+		return
+	}
+
+	filter, msg := parseAuditFilter(r, auditPageSize)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+
+		return
+	}
+
+	entries, total, err := ac.Store.ListAuditEntries(filter)
+	if err != nil {
+		logger.Logger.Error("AuditHandler error - ListAuditEntries", "error", err)
+		http.Error(w, "Unable to retrieve the audit log.", http.StatusInternalServerError)
+
+		return
+	}
+
+	data := auditPage{
+		Entries:    entries,
+		Actions:    auditActions,
+		Actor:      filter.Actor,
+		Action:     filter.Action,
+		Target:     filter.TargetID,
+		Total:      total,
+		FirstIndex: filter.Offset + 1,
+		LastIndex:  filter.Offset + len(entries),
+	}
+
+	pageURL := func(offset int) string {
+		query := r.URL.Query()
+		query.Set("offset", strconv.Itoa(offset))
+
+		return (&url.URL{Path: r.URL.Path, RawQuery: query.Encode()}).String()
+	}
+
+	if filter.Offset > 0 {
+		data.PrevURL = pageURL(max(filter.Offset-filter.Limit, 0))
+	}
+
+	if filter.Offset+filter.Limit < total {
+		data.NextURL = pageURL(filter.Offset + filter.Limit)
+	}
+
+	template.Execute(w, r, "audit", data)
+}
+
+// ListAuditAPIHandler handles GET /api/audit
+// It returns the audit log, most recent first, filtered by the optional query
+// parameters actor, action, target (id of the target) and since. The keys
+// restricted to teams cannot read it.
+func (ac *AppContext) ListAuditAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if !access(r).All {
+		writeJSONError(w, http.StatusForbidden, "API key restricted to teams cannot read the audit log")
+
+		return
+	}
+
+	filter, msg := parseAuditFilter(r, defaultPageLimit)
+	if msg != "" {
+		writeJSONError(w, http.StatusBadRequest, msg)
+
+		return
+	}
+
+	entries, total, err := ac.Store.ListAuditEntries(filter)
+	if err != nil {
+		logger.Logger.Error("ListAuditAPIHandler error - ListAuditEntries", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "unable to retrieve the audit log")
+
+		return
+	}
+
+	res := page[auditEntryJSON]{Items: []auditEntryJSON{}, Total: total, Limit: filter.Limit, Offset: filter.Offset}
+	for _, e := range entries {
+		res.Items = append(res.Items, auditEntryJSON{
+			ID:         e.ID,
+			Time:       e.Time,
+			Actor:      e.Actor,
+			Source:     e.Source,
+			RemoteAddr: e.RemoteAddr,
+			Action:     e.Action,
+			TargetType: e.TargetType,
+			TargetID:   e.TargetID,
+			TargetName: e.TargetName,
+			Before:     decodeSnapshot(e.Before),
+			After:      decodeSnapshot(e.After),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
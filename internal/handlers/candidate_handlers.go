@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"leblanc.io/open-go-ssl-checker/internal/audit"
+	"leblanc.io/open-go-ssl-checker/internal/logger"
+	"leblanc.io/open-go-ssl-checker/internal/store"
+	"leblanc.io/open-go-ssl-checker/internal/template"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// candidateStatuses lists the statuses offered in the filters.
+var candidateStatuses = []string{types.CandidatePending, types.CandidateIgnored, types.CandidateApproved}
+
+// candidateJSON is the representation of a discovered candidate.
+type candidateJSON struct {
+	ID        string    `json:"id"`
+	Host      string    `json:"host"`
+	Port      int       `json:"port"`
+	Type      string    `json:"type"`
+	Domain    string    `json:"domain"`
+	Source    string    `json:"source"`
+	Issuer    string    `json:"issuer"`
+	NotAfter  time.Time `json:"not_after"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Status    string    `json:"status"`
+	ProjectID string    `json:"project_id,omitempty"`
+}
+
+type candidatesPage struct {
+	Status     string
+	Statuses   []string
+	Candidates []types.Candidate
+}
+
+// candidateError is a refused action on a candidate, with its HTTP status.
+type candidateError struct {
+	Status  int
+	Message string
+}
+
+func (e *candidateError) Error() string {
+	return e.Message
+}
+
+func newCandidateJSON(c types.Candidate) candidateJSON {
+	port, _ := strconv.Atoi(c.Port)
+
+	return candidateJSON{
+		ID:        c.ID,
+		Host:      c.Host,
+		Port:      port,
+		Type:      c.Type,
+		Domain:    c.Domain,
+		Source:    c.Source,
+		Issuer:    c.Issuer,
+		NotAfter:  c.NotAfter,
+		FirstSeen: c.FirstSeen,
+		LastSeen:  c.LastSeen,
+		Status:    c.Status,
+		ProjectID: c.ProjectID,
+	}
+}
+
+// candidateStatus returns the status filter of the request, pending by
+// default and empty for every candidate.
+func candidateStatus(r *http.Request) (string, bool) {
+	status, ok := r.URL.Query()["status"]
+	if !ok {
+		return types.CandidatePending, true
+	}
+
+	if status[0] == "" || status[0] == "all" {
+		return "", true
+	}
+
+	return status[0], slices.Contains(candidateStatuses, status[0])
+}
+
+// loadCandidate returns the candidate of the request.
+func (ac *AppContext) loadCandidate(r *http.Request) (*types.Candidate, error) {
+	id := mux.Vars(r)["id"]
+
+	candidate, err := ac.Store.GetCandidate(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if candidate == nil {
+		return nil, &candidateError{Status: http.StatusNotFound, Message: "candidate not found"}
+	}
+
+	return candidate, nil
+}
+
+// approveCandidate creates the project of a pending or ignored candidate,
+// with the team and the tags configured for its source. The project is named
+// after the host, followed by the port when it is not 443.
+func (ac *AppContext) approveCandidate(r *http.Request) (*types.Project, error) {
+	candidate, err := ac.loadCandidate(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if candidate.Status == types.CandidateApproved {
+		return nil, &candidateError{Status: http.StatusConflict, Message: "candidate is already approved"}
+	}
+
+	defaults := ac.CandidateProjects[candidate.Source]
+
+	if !access(r).CanAccessTeam(defaults.Team) {
+		return nil, &candidateError{
+			Status:  http.StatusForbidden,
+			Message: "cannot add projects to team " + defaults.Team,
+		}
+	}
+
+	project := types.Project{
+		ID:   uuid.New().String(),
+		Name: candidate.Host,
+		Host: candidate.Host,
+		Port: candidate.Port,
+		Type: candidate.Type,
+		Tags: normalizeTags(defaults.Tags),
+		Team: defaults.Team,
+	}
+
+	if candidate.Port != "443" {
+		project.Name = net.JoinHostPort(candidate.Host, candidate.Port)
+	}
+
+	if err := ac.Store.AddProject(project); err != nil {
+		if errors.Is(err, store.ErrDuplicateProjectName) {
+			return nil, &candidateError{Status: http.StatusConflict, Message: "a project with this name already exists"}
+		}
+
+		return nil, err
+	}
+
+	ac.auditProject(r, types.AuditProjectCreate, nil, &project)
+
+	candidate.Status, candidate.ProjectID = types.CandidateApproved, project.ID
+	if err := ac.Store.UpdateCandidate(*candidate); err != nil {
+		// The project is created, the candidate is proposed again until updated
+		logger.Logger.Error("approveCandidate error - UpdateCandidate", "candidate_id", candidate.ID, "error", err)
+	}
+
+	ac.checkInBackground(project)
+
+	return &project, nil
+}
+
+// ignoreCandidate stops proposing a pending candidate.
+func (ac *AppContext) ignoreCandidate(r *http.Request) (*types.Candidate, error) {
+	candidate, err := ac.loadCandidate(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if candidate.Status != types.CandidatePending {
+		return nil, &candidateError{Status: http.StatusConflict, Message: "candidate is not pending"}
+	}
+
+	before := *candidate
+	candidate.Status = types.CandidateIgnored
+
+	if err := ac.Store.UpdateCandidate(*candidate); err != nil {
+		return nil, err
+	}
+
+	ac.Audit.Record(
+		auditActor(r),
+		types.AuditCandidateIgnore,
+		audit.Target{Type: audit.TargetCandidate, ID: candidate.ID, Name: candidate.Host},
+		newCandidateJSON(before),
+		newCandidateJSON(*candidate),
+	)
+
+	return candidate, nil
+}
+
+// candidateFailure returns the status and the message of a failed action,
+// logging the unexpected errors.
+func candidateFailure(context string, err error) (int, string) {
+	var refused *candidateError
+	if errors.As(err, &refused) {
+		return refused.Status, refused.Message
+	}
+
+	logger.Logger.Error(context, "error", err)
+
+	return http.StatusInternalServerError, "unable to update the candidate"
+}
+
+// CandidatesHandler lists the discovered candidates, the pending ones by
+// default.
+func (ac *AppContext) CandidatesHandler(w http.ResponseWriter, r *http.Request) {
+	status, ok := candidateStatus(r)
+	if !ok {
+		http.Error(w, "Invalid status.", http.StatusBadRequest)
+
+		return
+	}
+
+	candidates, err := ac.Store.ListCandidates(status)
+	if err != nil {
+		logger.Logger.Error("CandidatesHandler error - ListCandidates", "error", err)
+		http.Error(w, "Unable to retrieve candidates.", http.StatusInternalServerError)
+
+		return
+	}
+
+	template.Execute(w, r, "candidates", candidatesPage{
+		Status:     status,
+		Statuses:   candidateStatuses,
+		Candidates: candidates,
+	})
+}
+
+// ApproveCandidateHandler creates the project of a candidate.
+func (ac *AppContext) ApproveCandidateHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := ac.approveCandidate(r); err != nil {
+		status, message := candidateFailure("ApproveCandidateHandler error - approveCandidate", err)
+		http.Error(w, message, status)
+
+		return
+	}
+
+	http.Redirect(w, r, "/discovered", http.StatusSeeOther)
+}
+
+// IgnoreCandidateHandler stops proposing a candidate.
+func (ac *AppContext) IgnoreCandidateHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := ac.ignoreCandidate(r); err != nil {
+		status, message := candidateFailure("IgnoreCandidateHandler error - ignoreCandidate", err)
+		http.Error(w, message, status)
+
+		return
+	}
+
+	http.Redirect(w, r, "/discovered", http.StatusSeeOther)
+}
+
+// ListCandidatesAPIHandler handles GET /api/candidates
+func (ac *AppContext) ListCandidatesAPIHandler(w http.ResponseWriter, r *http.Request) {
+	status, ok := candidateStatus(r)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "invalid status")
+
+		return
+	}
+
+	candidates, err := ac.Store.ListCandidates(status)
+	if err != nil {
+		logger.Logger.Error("ListCandidatesAPIHandler error - ListCandidates", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "unable to retrieve candidates")
+
+		return
+	}
+
+	res := make([]candidateJSON, 0, len(candidates))
+	for _, c := range candidates {
+		res = append(res, newCandidateJSON(c))
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+// ApproveCandidateAPIHandler handles POST /api/candidates/{id}/approve
+func (ac *AppContext) ApproveCandidateAPIHandler(w http.ResponseWriter, r *http.Request) {
+	project, err := ac.approveCandidate(r)
+	if err != nil {
+		status, message := candidateFailure("ApproveCandidateAPIHandler error - approveCandidate", err)
+		writeJSONError(w, status, message)
+
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newProjectJSON(*project))
+}
+
+// IgnoreCandidateAPIHandler handles POST /api/candidates/{id}/ignore
+func (ac *AppContext) IgnoreCandidateAPIHandler(w http.ResponseWriter, r *http.Request) {
+	candidate, err := ac.ignoreCandidate(r)
+	if err != nil {
+		status, message := candidateFailure("IgnoreCandidateAPIHandler error - ignoreCandidate", err)
+		writeJSONError(w, status, message)
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newCandidateJSON(*candidate))
+}
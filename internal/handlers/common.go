@@ -1,12 +1,88 @@
 package handlers
 
 import (
+	"net/http"
+	"strings"
+
+	"leblanc.io/open-go-ssl-checker/internal/audit"
+	"leblanc.io/open-go-ssl-checker/internal/auth"
 	"leblanc.io/open-go-ssl-checker/internal/checker"
+	"leblanc.io/open-go-ssl-checker/internal/scheduler"
 	"leblanc.io/open-go-ssl-checker/internal/store"
+	"leblanc.io/open-go-ssl-checker/internal/types"
 )
 
 type AppContext struct {
-	Store   *store.Store
+	Store   store.Store
 	Checker *checker.CertificateService
 	ApiKey  string
+	Version string
+	Audit   *audit.Recorder
+
+	// Sessions is nil when the web UI authentication is disabled.
+	Sessions *auth.SessionManager
+	// OIDC is nil when the OpenID Connect login is disabled.
+	OIDC *auth.OIDC
+	// AnonymousRead lets the visitors which are not logged in see the
+	// dashboard and the history, without changing anything.
+	AnonymousRead bool
+
+	// Scheduler runs the periodic checks, its state is reported by /readyz.
+	Scheduler *scheduler.PeriodicChecker
+
+	// CandidateProjects gives, for each discovery source, the team and the
+	// tags of the projects created by approving its candidates.
+	CandidateProjects map[string]CandidateProject
+}
+
+// CandidateProject is the team and the tags of the projects created by
+// approving the candidates of a discovery source.
+type CandidateProject struct {
+	Team string
+	Tags []string
+}
+
+// normalizeTags trims the tags and drops the empty and duplicated ones.
+func normalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	return normalized
+}
+
+// parseTags parses a comma separated list of tags.
+func parseTags(value string) []string {
+	return normalizeTags(strings.Split(value, ","))
+}
+
+// access returns the projects accessible to the API key or to the web UI
+// visitor of the request.
+func access(r *http.Request) auth.Access {
+	if key := auth.APIKeyFromContext(r.Context()); key != nil {
+		return auth.APIKeyAccess(key)
+	}
+
+	return auth.ViewerFromContext(r.Context()).Access()
+}
+
+// checkInBackground checks the certificate of a new or changed project
+// without waiting for the result.
+func (ac *AppContext) checkInBackground(project types.Project) {
+	go ac.Checker.CheckAndStoreCertificate(
+		project.ID,
+		project.Host,
+		project.Port,
+		project.Type,
+		project.AllowInsecure,
+	)
 }
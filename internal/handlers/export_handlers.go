@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/logger"
+	"leblanc.io/open-go-ssl-checker/internal/projectio"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// checkExportColumns are the columns of the check history CSV files.
+var checkExportColumns = []string{
+	"project_id",
+	"project_name",
+	"check_time",
+	"status",
+	"domains",
+	"ip",
+	"issuer",
+	"expiry_date",
+	"days_remaining",
+	"error",
+}
+
+// checkExportJSON is a check of the exported history, with the name of its
+// project.
+type checkExportJSON struct {
+	ProjectName string `json:"project_name"`
+	checkJSON
+}
+
+// exportFailure reports an export error, as a page or as JSON.
+type exportFailure func(w http.ResponseWriter, status int, message string)
+
+func pageExportFailure(w http.ResponseWriter, status int, message string) {
+	http.Error(w, message, status)
+}
+
+// writeDownload sends the exported file as an attachment named after the
+// exported data and the day.
+func writeDownload(w http.ResponseWriter, name string, format string, body []byte) {
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102"), format)
+
+	w.Header().Set("Content-Type", projectio.ContentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write(body); err != nil {
+		logger.Logger.Error("writeDownload error - Write", "error", err)
+	}
+}
+
+// exportProjects sends the projects accessible to the request in the format
+// query parameter, CSV by default.
+func (ac *AppContext) exportProjects(w http.ResponseWriter, r *http.Request, fail exportFailure) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = projectio.FormatCSV
+	}
+
+	if !slices.Contains(projectio.Formats, format) {
+		fail(w, http.StatusBadRequest, "format must be one of "+strings.Join(projectio.Formats, ", "))
+
+		return
+	}
+
+	projects, err := ac.Store.ListProjects()
+	if err != nil {
+		logger.Logger.Error("exportProjects error - ListProjects", "error", err)
+		fail(w, http.StatusInternalServerError, "unable to retrieve projects")
+
+		return
+	}
+
+	var body bytes.Buffer
+	if err := projectio.Write(&body, format, access(r).FilterProjects(projects)); err != nil {
+		logger.Logger.Error("exportProjects error - Write", "error", err)
+		fail(w, http.StatusInternalServerError, "unable to export projects")
+
+		return
+	}
+
+	writeDownload(w, "projects", format, body.Bytes())
+}
+
+// exportChecks sends the check history of the project query parameter, or of
+// every project accessible to the request, filtered by the optional from, to
+// and status query parameters.
+func (ac *AppContext) exportChecks(w http.ResponseWriter, r *http.Request, fail exportFailure) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = projectio.FormatCSV
+	}
+
+	if format != projectio.FormatCSV && format != projectio.FormatJSON {
+		fail(w, http.StatusBadRequest, "format must be one of csv, json")
+
+		return
+	}
+
+	statuses, msg := parseStatuses(r)
+	if msg != "" {
+		fail(w, http.StatusBadRequest, msg)
+
+		return
+	}
+
+	from, msg := parseTime(r, "from")
+	if msg != "" {
+		fail(w, http.StatusBadRequest, msg)
+
+		return
+	}
+
+	to, msg := parseEndTime(r, "to")
+	if msg != "" {
+		fail(w, http.StatusBadRequest, msg)
+
+		return
+	}
+
+	projects, err := ac.Store.ListProjects()
+	if err != nil {
+		logger.Logger.Error("exportChecks error - ListProjects", "error", err)
+		fail(w, http.StatusInternalServerError, "unable to retrieve projects")
+
+		return
+	}
+
+	projects = access(r).FilterProjects(projects)
+
+	if projectID := r.URL.Query().Get("project"); projectID != "" {
+		projects = slices.DeleteFunc(projects, func(p types.Project) bool { return p.ID != projectID })
+		if len(projects) == 0 {
+			fail(w, http.StatusNotFound, "project not found")
+
+			return
+		}
+	}
+
+	items := []checkExportJSON{}
+
+	for _, p := range projects {
+		checks, err := ac.Store.GetCertificateChecksForProject(p.ID)
+		if err != nil {
+			logger.Logger.Error("exportChecks error - GetCertificateChecksForProject", "project_id", p.ID, "error", err)
+			fail(w, http.StatusInternalServerError, "unable to retrieve check history")
+
+			return
+		}
+
+		for _, c := range checks {
+			if from != nil && c.CheckTime.Before(*from) {
+				continue
+			}
+
+			if to != nil && c.CheckTime.After(*to) {
+				continue
+			}
+
+			if statuses != nil && !slices.Contains(statuses, c.Status()) {
+				continue
+			}
+
+			items = append(items, checkExportJSON{ProjectName: p.Name, checkJSON: newCheckJSON(c)})
+		}
+	}
+
+	// Most recent first, like the history of a project
+	slices.SortStableFunc(items, func(a, b checkExportJSON) int {
+		return b.CheckTime.Compare(a.CheckTime)
+	})
+
+	var body bytes.Buffer
+	if format == projectio.FormatJSON {
+		err = json.NewEncoder(&body).Encode(items)
+	} else {
+		err = writeChecksCSV(&body, items)
+	}
+
+	if err != nil {
+		logger.Logger.Error("exportChecks error - Write", "error", err)
+		fail(w, http.StatusInternalServerError, "unable to export check history")
+
+		return
+	}
+
+	writeDownload(w, "checks", format, body.Bytes())
+}
+
+func writeChecksCSV(body *bytes.Buffer, items []checkExportJSON) error {
+	writer := csv.NewWriter(body)
+
+	if err := writer.Write(checkExportColumns); err != nil {
+		return err
+	}
+
+	for _, c := range items {
+		record := []string{
+			projectio.CSVCell(c.ProjectID),
+			projectio.CSVCell(c.ProjectName),
+			c.CheckTime.Format(time.RFC3339),
+			projectio.CSVCell(c.Status),
+			projectio.CSVCell(strings.Join(c.Domains, ",")),
+			projectio.CSVCell(c.IP),
+			projectio.CSVCell(c.Issuer),
+			projectio.CSVCell(c.ExpiryDate),
+			strconv.Itoa(c.DaysRemaining),
+			projectio.CSVCell(c.Error),
+		}
+
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// ExportProjectsHandler downloads the projects from the projects page.
+func (ac *AppContext) ExportProjectsHandler(w http.ResponseWriter, r *http.Request) {
+	ac.exportProjects(w, r, pageExportFailure)
+}
+
+// ExportChecksHandler downloads the check history from the projects and the
+// history pages.
+func (ac *AppContext) ExportChecksHandler(w http.ResponseWriter, r *http.Request) {
+	ac.exportChecks(w, r, pageExportFailure)
+}
+
+// ExportProjectsAPIHandler handles GET /api/projects/export
+// It returns the projects as a CSV, JSON or YAML file which can be imported
+// back with POST /api/projects/import.
+func (ac *AppContext) ExportProjectsAPIHandler(w http.ResponseWriter, r *http.Request) {
+	ac.exportProjects(w, r, writeJSONError)
+}
+
+// ExportChecksAPIHandler handles GET /api/checks/export
+// It returns the check history as a CSV or JSON file, of one project with the
+// project query parameter, of every project otherwise.
+func (ac *AppContext) ExportChecksAPIHandler(w http.ResponseWriter, r *http.Request) {
+	ac.exportChecks(w, r, writeJSONError)
+}
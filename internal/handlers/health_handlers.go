@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/store"
+)
+
+// healthTimeout bounds the database queries of the readiness checks, so a
+// stuck database fails the probe instead of hanging it.
+const healthTimeout = 2 * time.Second
+
+// Health check statuses.
+const (
+	healthOK      = "ok"
+	healthFailing = "failing"
+)
+
+type healthJSON struct {
+	Status  string `json:"status"`
+	Version string `json:"version"`
+}
+
+type readinessJSON struct {
+	Status string                     `json:"status"`
+	Checks map[string]healthCheckJSON `json:"checks"`
+}
+
+type healthCheckJSON struct {
+	Status  string         `json:"status"`
+	Message string         `json:"message,omitempty"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// HealthzHandler handles GET /healthz
+// It answers as long as the process serves requests.
+func (ac *AppContext) HealthzHandler(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, healthJSON{Status: healthOK, Version: ac.Version})
+}
+
+// ReadyzHandler handles GET /readyz
+// It checks that the database answers with the current schema and that the
+// periodic checks run, and answers 503 when one of them fails.
+func (ac *AppContext) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthTimeout)
+	defer cancel()
+
+	response := readinessJSON{
+		Status: healthOK,
+		Checks: map[string]healthCheckJSON{
+			"database":  ac.checkDatabase(ctx),
+			"schema":    ac.checkSchema(ctx),
+			"scheduler": ac.checkScheduler(time.Now()),
+		},
+	}
+
+	status := http.StatusOK
+
+	for _, check := range response.Checks {
+		if check.Status != healthOK {
+			response.Status = healthFailing
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, response)
+}
+
+func (ac *AppContext) checkDatabase(ctx context.Context) healthCheckJSON {
+	if err := ac.Store.Ping(ctx); err != nil {
+		return healthCheckJSON{Status: healthFailing, Message: err.Error()}
+	}
+
+	return healthCheckJSON{Status: healthOK}
+}
+
+func (ac *AppContext) checkSchema(ctx context.Context) healthCheckJSON {
+	version, err := ac.Store.GetSchemaVersion(ctx)
+	if err != nil {
+		return healthCheckJSON{Status: healthFailing, Message: err.Error()}
+	}
+
+	check := healthCheckJSON{
+		Status:  healthOK,
+		Details: map[string]any{"version": version, "expected": store.SchemaVersion},
+	}
+
+	if version != store.SchemaVersion {
+		check.Status = healthFailing
+		check.Message = fmt.Sprintf("schema version %d, expected %d", version, store.SchemaVersion)
+	}
+
+	return check
+}
+
+func (ac *AppContext) checkScheduler(now time.Time) healthCheckJSON {
+	if ac.Scheduler == nil {
+		return healthCheckJSON{Status: healthFailing, Message: "scheduler not configured"}
+	}
+
+	status := ac.Scheduler.Status()
+	check := healthCheckJSON{
+		Status:  healthOK,
+		Details: map[string]any{"interval": status.Interval.String()},
+	}
+
+	if !status.LastRun.IsZero() {
+		check.Details["last_run"] = status.LastRun.UTC().Format(time.RFC3339)
+	}
+
+	switch {
+	case !status.Running:
+		check.Status = healthFailing
+		check.Message = "scheduler not running"
+	case status.Stale(now):
+		check.Status = healthFailing
+		check.Message = "no check run completed for twice the interval"
+	}
+
+	return check
+}
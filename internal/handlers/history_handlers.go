@@ -1,10 +1,10 @@
 package handlers
 
 import (
-	"log"
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"leblanc.io/open-go-ssl-checker/internal/logger"
 	"leblanc.io/open-go-ssl-checker/internal/template"
 	"leblanc.io/open-go-ssl-checker/internal/types"
 )
@@ -21,13 +21,13 @@ func (ac *AppContext) HistoryHandler(w http.ResponseWriter, r *http.Request) {
 
 	project, err := ac.Store.GetProject(projectID)
 	if err != nil {
-		log.Printf("HistoryHandler error - GetProject %s: %v", projectID, err)
+		logger.Logger.Error("HistoryHandler error - GetProject", "project_id", projectID, "error", err)
 		http.Error(w, "Error retrieving project.", http.StatusInternalServerError)
 
 		return
 	}
 
-	if project == nil {
+	if project == nil || !access(r).CanAccess(*project) {
 		http.NotFound(w, r)
 
 		return
@@ -35,7 +35,7 @@ func (ac *AppContext) HistoryHandler(w http.ResponseWriter, r *http.Request) {
 
 	checks, err := ac.Store.GetCertificateChecksForProject(projectID)
 	if err != nil {
-		log.Printf("HistoryHandler error - GetCertificateChecksForProject %s: %v", projectID, err)
+		logger.Logger.Error("HistoryHandler error - GetCertificateChecksForProject", "project_id", projectID, "error", err)
 		http.Error(
 			w,
 			"Unable to retrieve verification history.",
@@ -47,12 +47,14 @@ func (ac *AppContext) HistoryHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Pour passer le nom du projet au template, on peut l'encapsuler
 	data := struct {
+		ProjectID   string
 		ProjectName string
 		Checks      []types.CertificateCheck
 	}{
+		ProjectID:   project.ID,
 		ProjectName: project.Name,
 		Checks:      checks,
 	}
 
-	template.Execute(w, "history", r.Header.Get("Accept-Language"), data)
+	template.Execute(w, r, "history", data)
 }
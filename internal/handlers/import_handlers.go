@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"leblanc.io/open-go-ssl-checker/internal/logger"
+	"leblanc.io/open-go-ssl-checker/internal/projectio"
+	"leblanc.io/open-go-ssl-checker/internal/template"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// maxImportSize is the maximum size of an imported file.
+const maxImportSize = 5 << 20
+
+type importPage struct {
+	Content string
+	Format  string
+	Formats []string
+	Plan    *projectio.Plan
+	Error   string
+	Applied bool
+}
+
+// importJSON is the response of POST /api/projects/import.
+type importJSON struct {
+	DryRun     bool                `json:"dry_run"`
+	Applied    bool                `json:"applied"`
+	New        []projectJSON       `json:"new"`
+	Changed    []projectChangeJSON `json:"changed"`
+	Duplicates []projectJSON       `json:"duplicates"`
+	Invalid    []invalidEntryJSON  `json:"invalid"`
+}
+
+type projectChangeJSON struct {
+	Before projectJSON `json:"before"`
+	After  projectJSON `json:"after"`
+}
+
+// invalidEntryJSON is a rejected entry, its position is its line in a CSV
+// file or its index (from 1) in a JSON or YAML list.
+type invalidEntryJSON struct {
+	Position int    `json:"position"`
+	Name     string `json:"name"`
+	Error    string `json:"error"`
+}
+
+func newImportJSON(plan projectio.Plan, dryRun bool, applied bool) importJSON {
+	res := importJSON{
+		DryRun:     dryRun,
+		Applied:    applied,
+		New:        []projectJSON{},
+		Changed:    []projectChangeJSON{},
+		Duplicates: []projectJSON{},
+		Invalid:    []invalidEntryJSON{},
+	}
+
+	for _, p := range plan.New {
+		res.New = append(res.New, newProjectJSON(p))
+	}
+
+	for _, c := range plan.Changed {
+		res.Changed = append(res.Changed, projectChangeJSON{
+			Before: newProjectJSON(c.Before),
+			After:  newProjectJSON(c.After),
+		})
+	}
+
+	for _, p := range plan.Duplicates {
+		res.Duplicates = append(res.Duplicates, newProjectJSON(p))
+	}
+
+	for _, i := range plan.Invalid {
+		res.Invalid = append(res.Invalid, invalidEntryJSON{Position: i.Position, Name: i.Name, Error: i.Error})
+	}
+
+	return res
+}
+
+// planImport compares the imported entries with the projects accessible to
+// the request.
+func (ac *AppContext) planImport(r *http.Request, entries []projectio.Entry) (*projectio.Plan, error) {
+	projects, err := ac.Store.ListProjects()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := projectio.NewPlan(projects, entries, "", access(r).CanAccessTeam)
+
+	return &plan, nil
+}
+
+// applyImport stores the new and changed projects of a valid plan in a single
+// transaction, then records them and checks their certificates.
+func (ac *AppContext) applyImport(r *http.Request, plan *projectio.Plan) error {
+	updated := make([]types.Project, 0, len(plan.Changed))
+	for _, c := range plan.Changed {
+		updated = append(updated, c.After)
+	}
+
+	for i := range plan.New {
+		plan.New[i].ID = uuid.New().String()
+	}
+
+	if err := ac.Store.ImportProjects(plan.New, updated); err != nil {
+		return err
+	}
+
+	for i := range plan.New {
+		project := plan.New[i]
+		ac.auditProject(r, types.AuditProjectCreate, nil, &project)
+		ac.checkInBackground(project)
+	}
+
+	for _, c := range plan.Changed {
+		ac.auditProject(r, types.AuditProjectUpdate, &c.Before, &c.After)
+
+		if connectionChanged(c.Before, c.After) {
+			ac.checkInBackground(c.After)
+		}
+	}
+
+	logger.Logger.Info("Import applied", "created", len(plan.New), "updated", len(plan.Changed))
+
+	return nil
+}
+
+// ImportHandler shows the import form, the preview of an import and applies
+// it once confirmed.
+func (ac *AppContext) ImportHandler(w http.ResponseWriter, r *http.Request) {
+	data := importPage{Formats: projectio.Formats}
+
+	if r.Method == http.MethodGet {
+		template.Execute(w, r, "import", data)
+
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	// ParseMultipartForm hides the errors of the confirmation form, which is
+	// not multipart, so it is parsed first
+	err := r.ParseForm()
+	if err == nil {
+		err = r.ParseMultipartForm(maxImportSize)
+	}
+
+	var tooLarge *http.MaxBytesError
+
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "The imported file is too large.", http.StatusRequestEntityTooLarge)
+
+		return
+	} else if err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		http.Error(w, "Unable to read the imported file.", http.StatusBadRequest)
+
+		return
+	}
+
+	data.Content = r.FormValue("content")
+	data.Format = r.FormValue("format")
+	filename := ""
+
+	if file, header, err := r.FormFile("file"); err == nil {
+		content, err := io.ReadAll(file)
+		file.Close()
+
+		if err != nil {
+			http.Error(w, "Unable to read the imported file.", http.StatusBadRequest)
+
+			return
+		}
+
+		if len(content) > 0 {
+			data.Content, filename = string(content), header.Filename
+		}
+	}
+
+	if data.Format == "" {
+		data.Format = projectio.DetectFormat(filename, "", []byte(data.Content))
+	}
+
+	entries, err := projectio.Parse(data.Format, []byte(data.Content))
+	if err != nil {
+		data.Error = err.Error()
+		template.Execute(w, r, "import", data)
+
+		return
+	}
+
+	plan, err := ac.planImport(r, entries)
+	if err != nil {
+		logger.Logger.Error("ImportHandler error - planImport", "error", err)
+		http.Error(w, "Unable to retrieve projects.", http.StatusInternalServerError)
+
+		return
+	}
+
+	data.Plan = plan
+
+	if r.FormValue("apply") == "true" && plan.Valid() {
+		if err := ac.applyImport(r, plan); err != nil {
+			logger.Logger.Error("ImportHandler error - applyImport", "error", err)
+			data.Error = "Unable to apply the import, nothing was changed."
+		} else {
+			data.Applied = true
+		}
+	}
+
+	template.Execute(w, r, "import", data)
+}
+
+// ImportProjectsAPIHandler handles POST /api/projects/import
+// The body is a CSV, JSON or YAML list of projects, its format is given by the
+// format query parameter or the Content-Type header. With dry_run=true, the
+// changes are only reported. The import is refused when an entry is invalid.
+func (ac *AppContext) ImportProjectsAPIHandler(w http.ResponseWriter, r *http.Request) {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "the imported file is too large")
+
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = projectio.DetectFormat("", r.Header.Get("Content-Type"), data)
+	}
+
+	entries, err := projectio.Parse(format, data)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	plan, err := ac.planImport(r, entries)
+	if err != nil {
+		logger.Logger.Error("ImportProjectsAPIHandler error - planImport", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "unable to retrieve projects")
+
+		return
+	}
+
+	if dryRun {
+		writeJSON(w, http.StatusOK, newImportJSON(*plan, true, false))
+
+		return
+	}
+
+	if !plan.Valid() {
+		writeJSON(w, http.StatusUnprocessableEntity, newImportJSON(*plan, false, false))
+
+		return
+	}
+
+	if err := ac.applyImport(r, plan); err != nil {
+		writeStoreError(w, "ImportProjectsAPIHandler error - applyImport", err, "unable to apply the import")
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newImportJSON(*plan, false, true))
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"leblanc.io/open-go-ssl-checker/internal/middleware"
+	"leblanc.io/open-go-ssl-checker/internal/projectio"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+func TestImportHandlerTooLarge(t *testing.T) {
+	ac, _, _ := newTestAPI(t)
+	handler := middleware.NewCSRF(nil, false).Middleware(ac.RequireEditor(http.HandlerFunc(ac.ImportHandler)))
+
+	const token = "csrf-token"
+
+	post := func(contentType string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/import", bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.AddCookie(&http.Cookie{Name: middleware.CSRFCookieName, Value: token})
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	content := strings.Repeat("a", maxImportSize+1)
+
+	var body bytes.Buffer
+
+	form := multipart.NewWriter(&body)
+	form.WriteField(middleware.CSRFFieldName, token)
+
+	file, _ := form.CreateFormFile("file", "projects.csv")
+	file.Write([]byte(content))
+	form.Close()
+
+	if rec := post(form.FormDataContentType(), body.Bytes()); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("multipart status = %d, want 413: %s", rec.Code, rec.Body.String())
+	}
+
+	// The forms send the token first
+	values := url.Values{"content": {content}}.Encode()
+	if rec := post("application/x-www-form-urlencoded", []byte(middleware.CSRFFieldName+"="+token+"&"+values)); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("form status = %d, want 413: %s", rec.Code, rec.Body.String())
+	}
+
+	// The token is still required
+	if rec := post("application/x-www-form-urlencoded", []byte(values)); rec.Code != http.StatusForbidden {
+		t.Errorf("status without CSRF token = %d, want 403", rec.Code)
+	}
+}
+
+// An export, including the projects managed by another source, is imported
+// back without any change.
+func TestImportExportRoundTrip(t *testing.T) {
+	_, s, router := newTestAPI(t)
+
+	addTestProject(t, s, types.Project{ID: "p1", Name: "web", Host: "www.example.com", Tags: []string{"prod"}, Team: "web"})
+	addTestProject(t, s, types.Project{ID: "p2", Name: "config", Host: "config.example.com", ManagedBy: "config"})
+	addTestProject(t, s, types.Project{
+		ID: "p3", Name: "test/shop/shop.example.com", Host: "shop.example.com", Port: "8443", ManagedBy: "kubernetes/test",
+	})
+	// Written as text in the CSV files, not as formulas
+	addTestProject(t, s, types.Project{ID: "p4", Name: "=SUM(A1:A2)", Host: "sum.example.com", Tags: []string{"@ops"}})
+	addTestProject(t, s, types.Project{ID: "p5", Name: "'=quoted", Host: "quoted.example.com"})
+
+	for _, format := range projectio.Formats {
+		t.Run(format, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/projects/export?format="+format, nil)
+			req.Header.Set("X-API-Key", testAPIKey)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("export status = %d: %s", rec.Code, rec.Body.String())
+			}
+
+			if format == projectio.FormatCSV {
+				for _, cell := range []string{"'=SUM(A1:A2)", "'@ops", "''=quoted"} {
+					if !strings.Contains(rec.Body.String(), "\n"+cell+",") && !strings.Contains(rec.Body.String(), ","+cell+",") {
+						t.Errorf("export = %q, want the cell %s", rec.Body.String(), cell)
+					}
+				}
+			}
+
+			var res importJSON
+			if code := callAPI(t, router, http.MethodPost, "/api/projects/import?format="+format, rec.Body, &res); code != http.StatusOK {
+				t.Fatalf("import status = %d, want 200: %+v", code, res.Invalid)
+			}
+
+			if len(res.New) != 0 || len(res.Changed) != 0 || len(res.Invalid) != 0 || len(res.Duplicates) != 5 {
+				t.Errorf("import = %+v, want 5 unchanged projects", res)
+			}
+		})
+	}
+
+	// A managed project cannot be changed by an import
+	body := strings.NewReader("name,host,port,type\nconfig,other.example.com,443,http\n")
+
+	var res importJSON
+	if code := callAPI(t, router, http.MethodPost, "/api/projects/import?format=csv", body, &res); code != http.StatusUnprocessableEntity {
+		t.Fatalf("import status = %d, want 422", code)
+	}
+
+	if project, _ := s.GetProject("p2"); project.Host != "config.example.com" || project.ManagedBy != "config" {
+		t.Errorf("project = %+v, want the unchanged config project", project)
+	}
+}
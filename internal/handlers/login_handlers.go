@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"leblanc.io/open-go-ssl-checker/internal/auth"
+	"leblanc.io/open-go-ssl-checker/internal/logger"
+	"leblanc.io/open-go-ssl-checker/internal/template"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// maxLoginSize is the maximum size of the login form, posted before any
+// authentication.
+const maxLoginSize = 64 << 10
+
+type loginPage struct {
+	Username string
+	Next     string
+	Failed   bool
+	SSO      bool
+}
+
+// RequireViewer lets through the visitors allowed to see the web UI: every
+// visitor when the authentication is disabled or anonymous read is enabled,
+// the logged in users otherwise.
+func (ac *AppContext) RequireViewer(next http.Handler) http.Handler {
+	return ac.webAuthMiddleware(types.RoleViewer, next)
+}
+
+// RequireEditor lets through the visitors allowed to change the projects and
+// trigger checks: every visitor when the authentication is disabled, the
+// users with the editor or admin role otherwise.
+func (ac *AppContext) RequireEditor(next http.Handler) http.Handler {
+	return ac.webAuthMiddleware(types.RoleEditor, next)
+}
+
+// RequireAdmin lets through the users with the admin role, or every visitor
+// when the authentication is disabled.
+func (ac *AppContext) RequireAdmin(next http.Handler) http.Handler {
+	return ac.webAuthMiddleware(types.RoleAdmin, next)
+}
+
+func (ac *AppContext) webAuthMiddleware(role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		viewer, err := ac.viewer(r)
+		if err != nil {
+			logger.Logger.Error("webAuthMiddleware error - viewer", "error", err)
+			http.Error(w, "Unable to check the session.", http.StatusInternalServerError)
+
+			return
+		}
+
+		anonymousAllowed := role == types.RoleViewer && ac.AnonymousRead
+
+		if !viewer.HasRole(role) && !anonymousAllowed {
+			if viewer.User != nil {
+				http.Error(w, "Your role does not allow this action.", http.StatusForbidden)
+
+				return
+			}
+
+			// Browsers are sent to the login page, other clients (WebSocket,
+			// forms posted after the session expired) get an error.
+			if r.Method == http.MethodGet && r.Header.Get("Upgrade") == "" {
+				http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+
+				return
+			}
+
+			http.Error(w, "Authentication required.", http.StatusUnauthorized)
+
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithViewer(r.Context(), viewer)))
+	})
+}
+
+// viewer returns the visitor of the request.
+func (ac *AppContext) viewer(r *http.Request) (auth.Viewer, error) {
+	if ac.Sessions == nil {
+		return auth.Viewer{AuthEnabled: false}, nil
+	}
+
+	user, err := ac.Sessions.User(r)
+	if err != nil {
+		return auth.Viewer{}, err
+	}
+
+	return auth.Viewer{User: user, AuthEnabled: true}, nil
+}
+
+// LoginHandler handles GET and POST /login
+func (ac *AppContext) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	if ac.Sessions == nil {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxLoginSize)
+
+	page := loginPage{Next: safeRedirect(r.FormValue("next")), SSO: ac.OIDC != nil}
+
+	if r.Method == http.MethodGet {
+		template.Execute(w, r, "login", page)
+
+		return
+	}
+
+	page.Username = r.FormValue("username")
+
+	user, err := ac.Sessions.Login(w, page.Username, r.FormValue("password"))
+	if err != nil {
+		logger.Logger.Error("LoginHandler error - Login", "error", err)
+		http.Error(w, "Unable to log in.", http.StatusInternalServerError)
+
+		return
+	}
+
+	if user == nil {
+		logger.Logger.Warn("Failed login", "username", page.Username, "remote_addr", r.RemoteAddr)
+
+		page.Failed = true
+
+		w.WriteHeader(http.StatusUnauthorized)
+		template.Execute(w, r, "login", page)
+
+		return
+	}
+
+	logger.Logger.Info("User logged in", "username", user.Username)
+	http.Redirect(w, r, page.Next, http.StatusSeeOther)
+}
+
+// OIDCLoginHandler handles GET /auth/oidc/login
+// It redirects to the OpenID Connect provider.
+func (ac *AppContext) OIDCLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if ac.OIDC == nil || ac.Sessions == nil {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	authURL, err := ac.OIDC.AuthCodeURL(r.Context(), w, safeRedirect(r.FormValue("next")))
+	if err != nil {
+		logger.Logger.Error("OIDCLoginHandler error - AuthCodeURL", "error", err)
+		http.Error(w, "Unable to reach the identity provider.", http.StatusBadGateway)
+
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallbackHandler handles GET /auth/oidc/callback
+// It completes the OpenID Connect login and opens a session.
+func (ac *AppContext) OIDCCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if ac.OIDC == nil || ac.Sessions == nil {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	user, next, err := ac.OIDC.Callback(w, r)
+	if err != nil {
+		logger.Logger.Error("OIDCCallbackHandler error - Callback", "error", err)
+
+		switch {
+		case errors.Is(err, auth.ErrOIDCAccessDenied), errors.Is(err, auth.ErrOIDCUsernameTaken):
+			http.Error(w, "You are not allowed to use this application.", http.StatusForbidden)
+		case errors.Is(err, auth.ErrOIDCInvalidFlow):
+			http.Error(w, "Invalid or expired login, please try again.", http.StatusBadRequest)
+		default:
+			http.Error(w, "Unable to log in with the identity provider.", http.StatusBadGateway)
+		}
+
+		return
+	}
+
+	if err := ac.Sessions.StartSession(w, user); err != nil {
+		logger.Logger.Error("OIDCCallbackHandler error - StartSession", "error", err)
+		http.Error(w, "Unable to log in.", http.StatusInternalServerError)
+
+		return
+	}
+
+	logger.Logger.Info("User logged in with OIDC", "username", user.Username, "role", user.Role)
+	http.Redirect(w, r, safeRedirect(next), http.StatusSeeOther)
+}
+
+// LogoutHandler handles POST /logout
+func (ac *AppContext) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if ac.Sessions != nil {
+		if err := ac.Sessions.Logout(w, r); err != nil {
+			logger.Logger.Error("LogoutHandler error - Logout", "error", err)
+		}
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// safeRedirect returns the local path to redirect to after the login, to
+// avoid redirecting to another site.
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.Contains(next, "\\") {
+		return "/"
+	}
+
+	return next
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"leblanc.io/open-go-ssl-checker/internal/auth"
+	"leblanc.io/open-go-ssl-checker/internal/logger"
+)
+
+// MetricsHandler handles GET /metrics
+// It serves the metrics of the projects accessible to the API key in the
+// Prometheus format, or of every project when the metrics are public.
+func (ac *AppContext) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	projects, err := ac.Store.ListProjects()
+	if err != nil {
+		logger.Logger.Error("MetricsHandler error - ListProjects", "error", err)
+		http.Error(w, "Unable to retrieve projects.", http.StatusInternalServerError)
+
+		return
+	}
+
+	if auth.APIKeyFromContext(r.Context()) != nil {
+		projects = access(r).FilterProjects(projects)
+	}
+
+	promhttp.HandlerFor(ac.Checker.Metrics.Gatherer(projects), promhttp.HandlerOpts{
+		ErrorLog:      metricsErrorLog{},
+		ErrorHandling: promhttp.ContinueOnError,
+	}).ServeHTTP(w, r)
+}
+
+// metricsErrorLog logs the errors of the metrics collection.
+type metricsErrorLog struct{}
+
+func (metricsErrorLog) Println(v ...any) {
+	logger.Logger.Error("MetricsHandler error - Gather", "error", v)
+}
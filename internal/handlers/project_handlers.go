@@ -1,16 +1,28 @@
 package handlers
 
 import (
-	"log"
+	"errors"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"leblanc.io/open-go-ssl-checker/internal/logger"
+	"leblanc.io/open-go-ssl-checker/internal/store"
 	"leblanc.io/open-go-ssl-checker/internal/template"
 	"leblanc.io/open-go-ssl-checker/internal/types"
 )
 
+// projectFormPage is the form adding a project, or editing it when Edit is set.
+type projectFormPage struct {
+	Project types.Project
+	Edit    bool
+	// Teams suggested for the project
+	Teams []string
+}
+
 func (ac *AppContext) IndexHandler(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
 		http.NotFound(w, r)
@@ -20,7 +32,7 @@ func (ac *AppContext) IndexHandler(w http.ResponseWriter, r *http.Request) {
 
 	summaries, err := ac.Store.GetLatestChecksSummary()
 	if err != nil {
-		log.Printf("IndexHandler error - GetLatestChecksSummary: %v", err)
+		logger.Logger.Error("IndexHandler error - GetLatestChecksSummary", "error", err)
 		http.Error(
 			w,
 			"Unable to retrieve project information.",
@@ -30,72 +42,173 @@ func (ac *AppContext) IndexHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	template.Execute(w, "index", r.Header.Get("Accept-Language"), summaries)
+	template.Execute(w, r, "index", access(r).FilterSummaries(summaries))
 }
 
 func (ac *AppContext) AddProjectHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
-		template.Execute(w, "add", r.Header.Get("Accept-Language"), "nil")
+		ac.renderProjectForm(w, r, projectFormPage{Project: types.Project{Type: "http"}})
 
 		return
 	}
 
-	name := r.FormValue("name")
-	host := r.FormValue("host")
-	port := r.FormValue("port")
-	projectType := r.FormValue("type")
-	allowInsecure := r.FormValue("allow_insecure") == "true"
+	project, msg := parseProjectForm(r)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+
+		return
+	}
 
-	if name == "" || host == "" || port == "" || projectType == "" {
-		http.Error(w, "All fields are required.", http.StatusBadRequest)
+	if !access(r).CanAccessTeam(project.Team) {
+		http.Error(w, "You cannot add projects to this team.", http.StatusForbidden)
 
 		return
 	}
 
-	portInt, err := strconv.Atoi(port)
+	project.ID = uuid.New().String()
+
+	if err := ac.Store.AddProject(project); err != nil {
+		if errors.Is(err, store.ErrDuplicateProjectName) {
+			http.Error(w, "A project with this name already exists.", http.StatusConflict)
+
+			return
+		}
+
+		logger.Logger.Error("AddProjectHandler error - AddProject", "error", err)
+		http.Error(w, "Unable to add project.", http.StatusInternalServerError)
+
+		return
+	}
+
+	ac.auditProject(r, types.AuditProjectCreate, nil, &project)
+
+	ac.checkInBackground(project)
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// EditProjectHandler changes a project in place: its ID, and so its history,
+// are kept.
+func (ac *AppContext) EditProjectHandler(w http.ResponseWriter, r *http.Request) {
+	projectID := mux.Vars(r)["uuid"]
+
+	previous, err := ac.Store.GetProject(projectID)
 	if err != nil {
-		http.Error(w, "Port must be an integer.", http.StatusBadRequest)
+		logger.Logger.Error("EditProjectHandler error - GetProject", "project_id", projectID, "error", err)
+		http.Error(w, "Error retrieving project.", http.StatusInternalServerError)
 
 		return
 	}
 
-	if portInt < 1 || portInt > 65535 {
-		http.Error(w, "Port must be between 1 and 65535.", http.StatusBadRequest)
+	if previous == nil || !access(r).CanAccess(*previous) {
+		http.NotFound(w, r)
 
 		return
 	}
 
-	project := types.Project{
-		ID:            uuid.New().String(),
-		Name:          name,
-		Host:          host,
-		Port:          port,
-		Type:          projectType,
-		AllowInsecure: allowInsecure,
+	if previous.Managed() {
+		http.Error(w, "This project is managed by "+previous.ManagedBy+", change it there.", http.StatusConflict)
+
+		return
 	}
 
-	if err := ac.Store.AddProject(project); err != nil {
-		log.Printf("AddProjectHandler error - AddProject: %v", err)
-		http.Error(w, "Unable to add project.", http.StatusInternalServerError)
+	if r.Method == http.MethodGet {
+		ac.renderProjectForm(w, r, projectFormPage{Project: *previous, Edit: true})
 
 		return
 	}
 
-	go ac.Checker.CheckAndStoreCertificate(
-		project.ID,
-		project.Host,
-		project.Port,
-		project.Type,
-		project.AllowInsecure,
-	)
+	project, msg := parseProjectForm(r)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
 
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	if !access(r).CanAccessTeam(project.Team) || !access(r).CanMove(previous.Team, project.Team) {
+		http.Error(w, "You cannot move projects to this team.", http.StatusForbidden)
+
+		return
+	}
+
+	project.ID = previous.ID
+
+	if err := ac.Store.UpdateProject(project); err != nil {
+		if errors.Is(err, store.ErrDuplicateProjectName) {
+			http.Error(w, "A project with this name already exists.", http.StatusConflict)
+
+			return
+		}
+
+		logger.Logger.Error("EditProjectHandler error - UpdateProject", "project_id", projectID, "error", err)
+		http.Error(w, "Unable to update project.", http.StatusInternalServerError)
+
+		return
+	}
+
+	ac.auditProject(r, types.AuditProjectUpdate, previous, &project)
+
+	if connectionChanged(*previous, project) {
+		ac.checkInBackground(project)
+	}
+
+	http.Redirect(w, r, "/projects", http.StatusSeeOther)
+}
+
+func (ac *AppContext) renderProjectForm(w http.ResponseWriter, r *http.Request, data projectFormPage) {
+	teams, err := ac.accessibleTeams(r)
+	if err != nil {
+		logger.Logger.Error("renderProjectForm error - accessibleTeams", "error", err)
+		http.Error(w, "Unable to retrieve teams.", http.StatusInternalServerError)
+
+		return
+	}
+
+	data.Teams = teams
+
+	template.Execute(w, r, "project_form", data)
+}
+
+// parseProjectForm reads the project submitted by the add and edit forms. It
+// returns an error message when the form is invalid.
+func parseProjectForm(r *http.Request) (types.Project, string) {
+	project := types.Project{
+		Name:          strings.TrimSpace(r.FormValue("name")),
+		Host:          strings.TrimSpace(r.FormValue("host")),
+		Port:          strings.TrimSpace(r.FormValue("port")),
+		Type:          r.FormValue("type"),
+		AllowInsecure: r.FormValue("allow_insecure") == "true",
+		Tags:          parseTags(r.FormValue("tags")),
+		Team:          strings.TrimSpace(r.FormValue("team")),
+	}
+
+	if project.Name == "" || project.Host == "" || project.Port == "" || project.Type == "" {
+		return project, "All fields are required."
+	}
+
+	portInt, err := strconv.Atoi(project.Port)
+	if err != nil {
+		return project, "Port must be an integer."
+	}
+
+	if portInt < 1 || portInt > 65535 {
+		return project, "Port must be between 1 and 65535."
+	}
+
+	return project, ""
+}
+
+// connectionChanged reports whether the certificate must be checked again
+// after the project changed.
+func connectionChanged(previous types.Project, project types.Project) bool {
+	return previous.Host != project.Host || previous.Port != project.Port ||
+		previous.Type != project.Type || previous.AllowInsecure != project.AllowInsecure
 }
 
 func (ac *AppContext) ProjectsHandler(w http.ResponseWriter, r *http.Request) {
 	projects, err := ac.Store.ListProjects()
 	if err != nil {
-		log.Printf("ProjectsHandler error - ListProjects: %v", err)
+		logger.Logger.Error("ProjectsHandler error - ListProjects", "error", err)
 		http.Error(
 			w,
 			"Unable to retrieve projects list.",
@@ -105,7 +218,7 @@ func (ac *AppContext) ProjectsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	template.Execute(w, "projects", r.Header.Get("Accept-Language"), projects)
+	template.Execute(w, r, "projects", access(r).FilterProjects(projects))
 }
 
 func (ac *AppContext) DeleteProjectHandler(w http.ResponseWriter, r *http.Request) {
@@ -121,25 +234,54 @@ func (ac *AppContext) DeleteProjectHandler(w http.ResponseWriter, r *http.Reques
 	// Vérifier si le projet existe avant de tenter de le supprimer (optionnel mais propre)
 	proj, err := ac.Store.GetProject(projectID)
 	if err != nil {
-		log.Printf("Erreur DeleteProjectHandler - GetProject %s: %v", projectID, err)
+		logger.Logger.Error("DeleteProjectHandler error - GetProject", "project_id", projectID, "error", err)
 		http.Error(w, "Erreur lors de la vérification du projet.", http.StatusInternalServerError)
 
 		return
 	}
 
-	if proj == nil {
+	if proj == nil || !access(r).CanAccess(*proj) {
 		http.NotFound(w, r)
 
 		return
 	}
 
+	if proj.Managed() {
+		http.Error(w, "This project is managed by "+proj.ManagedBy+", change it there.", http.StatusConflict)
+
+		return
+	}
+
 	if err := ac.Store.DeleteProject(projectID); err != nil {
-		log.Printf("DeleteProjectHandler error - DeleteProject %s: %v", projectID, err)
+		logger.Logger.Error("DeleteProjectHandler error - DeleteProject", "project_id", projectID, "error", err)
 		http.Error(w, "Unable to delete project.", http.StatusInternalServerError)
 
 		return
 	}
 
-	log.Printf("Project %s successfully deleted.", projectID)
+	ac.auditProject(r, types.AuditProjectDelete, proj, nil)
+	logger.Logger.Info("Project deleted", "project_id", projectID)
 	http.Redirect(w, r, "/projects", http.StatusSeeOther)
 }
+
+// accessibleTeams returns the teams of the visitor and of the projects it can
+// access, to suggest them in the forms.
+func (ac *AppContext) accessibleTeams(r *http.Request) ([]string, error) {
+	projects, err := ac.Store.ListProjects()
+	if err != nil {
+		return nil, err
+	}
+
+	a := access(r)
+	teams := slices.Clone(a.Teams)
+
+	for _, p := range a.FilterProjects(projects) {
+		if p.Team != "" && !slices.Contains(teams, p.Team) {
+			teams = append(teams, p.Team)
+		}
+	}
+
+	slices.Sort(teams)
+
+	return teams, nil
+}
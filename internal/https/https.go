@@ -0,0 +1,196 @@
+// Package https serves the web UI over TLS, with a certificate reloaded when
+// its files change, and redirects the plain HTTP requests to it.
+package https
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/logger"
+)
+
+// Reloader serves the certificate read from a certificate and a key file,
+// reloaded when one of them changes or on SIGHUP. A certificate which cannot
+// be loaded is logged and the previous one is kept.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+	stopChan chan struct{}
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	modTimes    map[string]time.Time
+}
+
+// NewReloader loads the certificate, which must be valid for the server to
+// start.
+func NewReloader(certFile string, keyFile string, interval time.Duration) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: interval,
+		stopChan: make(chan struct{}),
+	}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, for tls.Config.
+func (r *Reloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.certificate, nil
+}
+
+// Leaf returns the parsed certificate of the server.
+func (r *Reloader) Leaf() *x509.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.certificate.Leaf
+}
+
+// Start launches the goroutine watching the files. Without interval, the
+// certificate is only reloaded on SIGHUP.
+func (r *Reloader) Start() {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	// Without interval, the nil channel never ticks
+	var (
+		ticker *time.Ticker
+		tick   <-chan time.Time
+	)
+
+	if r.interval > 0 {
+		logger.Logger.Info("Watching the TLS certificate", "cert_file", r.certFile, "interval", r.interval)
+		ticker = time.NewTicker(r.interval)
+		tick = ticker.C
+	}
+
+	go func() {
+		for {
+			select {
+			case <-hangup:
+				logger.Logger.Info("SIGHUP received, reloading the TLS certificate")
+				r.reload()
+			case <-tick:
+				if r.changed() {
+					logger.Logger.Info("TLS certificate changed, reloading it")
+					r.reload()
+				}
+			case <-r.stopChan:
+				signal.Stop(hangup)
+
+				if ticker != nil {
+					ticker.Stop()
+				}
+
+				logger.Logger.Info("TLS certificate watcher stopped")
+
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the watching goroutine.
+func (r *Reloader) Stop() {
+	close(r.stopChan)
+}
+
+func (r *Reloader) reload() {
+	if err := r.load(); err != nil {
+		logger.Logger.Error("Error reloading the TLS certificate, keeping the previous one", "error", err)
+	}
+}
+
+// load reads the files and replaces the certificate when they are valid.
+func (r *Reloader) load() error {
+	// Read before loading: a file replaced meanwhile is loaded again later,
+	// and invalid files are not retried until they change
+	modTimes := fileModTimes(r.certFile, r.keyFile)
+
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+
+	r.mu.Lock()
+	r.modTimes = modTimes
+	if err == nil {
+		r.certificate = &certificate
+	}
+	r.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("error loading TLS certificate: %w", err)
+	}
+
+	logger.Logger.Info(
+		"TLS certificate loaded",
+		"subject", certificate.Leaf.Subject.String(),
+		"not_after", certificate.Leaf.NotAfter,
+	)
+
+	return nil
+}
+
+// changed reports whether a file was modified since the last load.
+func (r *Reloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for file, modTime := range fileModTimes(r.certFile, r.keyFile) {
+		if !modTime.Equal(r.modTimes[file]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func fileModTimes(files ...string) map[string]time.Time {
+	times := make(map[string]time.Time, len(files))
+
+	for _, file := range files {
+		times[file] = time.Time{}
+
+		if info, err := os.Stat(file); err == nil {
+			times[file] = info.ModTime()
+		}
+	}
+
+	return times
+}
+
+// RedirectHandler redirects the requests to the same URL over HTTPS, on the
+// port of the HTTPS server.
+func RedirectHandler(port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = strings.Trim(r.Host, "[]") // No port
+		}
+
+		target := net.JoinHostPort(host, strconv.Itoa(port))
+		if port == 443 {
+			target = strings.TrimSuffix(target, ":443")
+		}
+
+		http.Redirect(w, r, "https://"+target+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
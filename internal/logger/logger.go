@@ -1,11 +1,42 @@
+// Package logger writes the structured logs of the application with slog.
 package logger
 
 import (
+	"fmt"
 	"log/slog"
+	"os"
+	"strings"
 )
 
-var Logger *slog.Logger
+// Logger is the logger of the application, the default slog logger until
+// Init configures it.
+var Logger = slog.Default()
 
-func Init(loggerLevel string) {
-	Logger = slog.Default()
+// Init configures the logger with the minimum level (debug, info, warn or
+// error) and the format (text or json) of the logs, written on the standard
+// error. It is also made the default logger, so the logs of the standard log
+// package and of the dependencies go through it, at the info level.
+func Init(level string, format string) error {
+	var logLevel slog.Level
+	if err := logLevel.UnmarshalText([]byte(strings.TrimSpace(level))); err != nil {
+		return fmt.Errorf("invalid log level %q, expected debug, info, warn or error", level)
+	}
+
+	options := &slog.HandlerOptions{Level: logLevel}
+
+	var handler slog.Handler
+
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", "text":
+		handler = slog.NewTextHandler(os.Stderr, options)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	default:
+		return fmt.Errorf("invalid log format %q, expected text or json", format)
+	}
+
+	Logger = slog.New(handler)
+	slog.SetDefault(Logger)
+
+	return nil
 }
@@ -0,0 +1,273 @@
+// Package metrics keeps the results of the latest certificate checks and the
+// state of the scheduler, and exposes them with the Prometheus client.
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// Check is the result of a certificate check.
+type Check struct {
+	Time     time.Time
+	Duration time.Duration
+	Success  bool
+	// NotAfter, DaysRemaining and ChainValid describe the certificate, they
+	// are only set when the check succeeded.
+	NotAfter      time.Time
+	DaysRemaining int
+	ChainValid    bool
+}
+
+// projectState is the latest check of a project, the certificate being the
+// one of the latest successful check.
+type projectState struct {
+	last        Check
+	certificate *Check
+}
+
+// projectLabels are the labels of the project metrics.
+var projectLabels = []string{"project", "host", "port", "type"}
+
+// Descriptions of the project metrics, collected for the projects given at
+// each scrape.
+var (
+	daysRemainingDesc = prometheus.NewDesc(
+		"ogsc_certificate_days_remaining",
+		"Days until the certificate expires.",
+		projectLabels, nil,
+	)
+	notAfterDesc = prometheus.NewDesc(
+		"ogsc_certificate_not_after_timestamp_seconds",
+		"Expiry date of the certificate.",
+		projectLabels, nil,
+	)
+	chainValidDesc = prometheus.NewDesc(
+		"ogsc_certificate_chain_valid",
+		"Whether the certificate chain is trusted and matches the host (1) or not (0).",
+		projectLabels, nil,
+	)
+	checkSuccessDesc = prometheus.NewDesc(
+		"ogsc_check_success",
+		"Whether the latest check succeeded (1) or failed (0).",
+		projectLabels, nil,
+	)
+	checkDurationDesc = prometheus.NewDesc(
+		"ogsc_check_duration_seconds",
+		"Duration of the latest check.",
+		projectLabels, nil,
+	)
+	checkTimeDesc = prometheus.NewDesc(
+		"ogsc_check_timestamp_seconds",
+		"Date of the latest check.",
+		projectLabels, nil,
+	)
+	projectsDesc = prometheus.NewDesc("ogsc_projects", "Number of projects.", nil, nil)
+)
+
+// Registry records the checks and the scheduler runs. A nil registry records
+// nothing, so the checks work without metrics.
+type Registry struct {
+	registry *prometheus.Registry
+
+	mu       sync.Mutex
+	projects map[string]*projectState
+
+	inFlight  prometheus.Gauge
+	queue     prometheus.Gauge
+	runs      prometheus.Counter
+	lastRun   prometheus.Gauge
+	lastRunAt prometheus.Gauge
+}
+
+func NewRegistry() *Registry {
+	r := &Registry{
+		registry: prometheus.NewRegistry(),
+		projects: map[string]*projectState{},
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "ogsc_checks_in_flight",
+			Help: "Checks currently running.",
+		}),
+		queue: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "ogsc_scheduler_queue_depth",
+			Help: "Projects waiting to be checked by the current scheduler run.",
+		}),
+		runs: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "ogsc_scheduler_runs_total",
+			Help: "Scheduler runs completed.",
+		}),
+		lastRun: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "ogsc_scheduler_run_duration_seconds",
+			Help: "Duration of the latest scheduler run.",
+		}),
+		lastRunAt: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "ogsc_scheduler_last_run_timestamp_seconds",
+			Help: "End of the latest scheduler run.",
+		}),
+	}
+
+	r.registry.MustRegister(
+		r.inFlight,
+		r.queue,
+		r.runs,
+		r.lastRun,
+		r.lastRunAt,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return r
+}
+
+// CheckStarted counts a check in flight, until CheckDone is called.
+func (r *Registry) CheckStarted() {
+	if r == nil {
+		return
+	}
+
+	r.inFlight.Inc()
+}
+
+// CheckDone records the result of the check of a project.
+func (r *Registry) CheckDone(projectID string, check Check) {
+	if r == nil {
+		return
+	}
+
+	r.inFlight.Dec()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, ok := r.projects[projectID]
+	if !ok {
+		state = &projectState{}
+		r.projects[projectID] = state
+	}
+
+	state.last = check
+	if check.Success {
+		state.certificate = &check
+	}
+}
+
+// RunStarted records the start of a scheduler run checking queued projects.
+// RunEnded must be called when the run ends, whether it completed or not.
+func (r *Registry) RunStarted(queued int) {
+	if r == nil {
+		return
+	}
+
+	r.queue.Set(float64(queued))
+}
+
+// Dequeued records that the scheduler took a project of its queue.
+func (r *Registry) Dequeued() {
+	if r == nil {
+		return
+	}
+
+	r.queue.Dec()
+}
+
+// RunDone records a completed scheduler run.
+func (r *Registry) RunDone(duration time.Duration) {
+	if r == nil {
+		return
+	}
+
+	r.runs.Inc()
+	r.lastRun.Set(duration.Seconds())
+	r.lastRunAt.SetToCurrentTime()
+}
+
+// RunEnded empties the queue of the scheduler run, which is interrupted when
+// it did not complete.
+func (r *Registry) RunEnded() {
+	if r == nil {
+		return
+	}
+
+	r.queue.Set(0)
+}
+
+// Gatherer returns the metrics of the projects and of the process. The
+// projects which were not checked since the start have no check metrics.
+func (r *Registry) Gatherer(projects []types.Project) prometheus.Gatherer {
+	scrape := prometheus.NewRegistry()
+	scrape.MustRegister(&projectCollector{registry: r, projects: projects})
+
+	if r == nil {
+		return scrape
+	}
+
+	return prometheus.Gatherers{r.registry, scrape}
+}
+
+// projectCollector collects the metrics of the projects of a scrape.
+type projectCollector struct {
+	registry *Registry
+	projects []types.Project
+}
+
+func (c *projectCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		daysRemainingDesc, notAfterDesc, chainValidDesc,
+		checkSuccessDesc, checkDurationDesc, checkTimeDesc, projectsDesc,
+	} {
+		ch <- desc
+	}
+}
+
+func (c *projectCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(projectsDesc, prometheus.GaugeValue, float64(len(c.projects)))
+
+	if c.registry == nil {
+		return
+	}
+
+	c.registry.mu.Lock()
+	defer c.registry.mu.Unlock()
+
+	for _, p := range c.projects {
+		state, ok := c.registry.projects[p.ID]
+		if !ok {
+			continue
+		}
+
+		labels := []string{p.Name, p.Host, p.Port, p.Type}
+		gauge := func(desc *prometheus.Desc, value float64) {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels...)
+		}
+
+		gauge(checkSuccessDesc, boolValue(state.last.Success))
+		gauge(checkDurationDesc, state.last.Duration.Seconds())
+		gauge(checkTimeDesc, unix(state.last.Time))
+
+		if certificate := state.certificate; certificate != nil {
+			gauge(daysRemainingDesc, float64(certificate.DaysRemaining))
+			gauge(notAfterDesc, unix(certificate.NotAfter))
+			gauge(chainValidDesc, boolValue(certificate.ChainValid))
+		}
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+func unix(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return float64(t.UnixMilli()) / 1000
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+func TestGathererProjects(t *testing.T) {
+	r := NewRegistry()
+	notAfter := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	r.CheckStarted()
+	r.CheckDone("p1", Check{Success: true, NotAfter: notAfter, DaysRemaining: 42, ChainValid: true})
+	r.CheckStarted()
+	r.CheckDone("p1", Check{Success: false})
+	r.CheckStarted()
+	r.CheckDone("p2", Check{Success: true, DaysRemaining: 7})
+
+	shown := []types.Project{
+		{ID: "p1", Name: `shop "eu"`, Host: "shop.example.com", Port: "443", Type: "http"},
+		{ID: "p3", Name: "unchecked", Host: "new.example.com", Port: "443", Type: "http"},
+	}
+
+	expected := `
+# HELP ogsc_certificate_days_remaining Days until the certificate expires.
+# TYPE ogsc_certificate_days_remaining gauge
+ogsc_certificate_days_remaining{host="shop.example.com",port="443",project="shop \"eu\"",type="http"} 42
+# HELP ogsc_check_success Whether the latest check succeeded (1) or failed (0).
+# TYPE ogsc_check_success gauge
+ogsc_check_success{host="shop.example.com",port="443",project="shop \"eu\"",type="http"} 0
+# HELP ogsc_checks_in_flight Checks currently running.
+# TYPE ogsc_checks_in_flight gauge
+ogsc_checks_in_flight 0
+# HELP ogsc_projects Number of projects.
+# TYPE ogsc_projects gauge
+ogsc_projects 2
+`
+
+	// The certificate is the one of the latest successful check, the
+	// projects which are not given are not exposed
+	err := testutil.GatherAndCompare(
+		r.Gatherer(shown),
+		strings.NewReader(expected),
+		"ogsc_certificate_days_remaining", "ogsc_check_success", "ogsc_checks_in_flight", "ogsc_projects",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunEndedResetsQueue(t *testing.T) {
+	r := NewRegistry()
+
+	r.RunStarted(3)
+	r.Dequeued()
+
+	if got := testutil.ToFloat64(r.queue); got != 2 {
+		t.Fatalf("queue = %v, want 2", got)
+	}
+
+	// Interrupted: the run is not counted but its queue is emptied
+	r.RunEnded()
+
+	if got := testutil.ToFloat64(r.queue); got != 0 {
+		t.Errorf("queue = %v after the end of the run, want 0", got)
+	}
+
+	if got := testutil.ToFloat64(r.runs); got != 0 {
+		t.Errorf("runs = %v, want 0", got)
+	}
+}
@@ -0,0 +1,241 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"leblanc.io/open-go-ssl-checker/internal/logger"
+)
+
+const (
+	// CSRFCookieName is the name of the cookie holding the CSRF token.
+	CSRFCookieName = "ogsc_csrf"
+	// CSRFFieldName is the name of the form field repeating the CSRF token.
+	CSRFFieldName = "csrf_token"
+	// csrfBodyPrefix is the size of the body read to find the CSRF token. The
+	// forms send it first, the rest of the body is left to the handlers, which
+	// enforce their own limits.
+	csrfBodyPrefix = 16 << 10
+)
+
+type csrfContextKey struct{}
+
+// CSRF protects the forms against cross-site requests: the token stored in a
+// cookie must be repeated in the submitted form (double-submit cookie), and
+// the origin of the mutating requests must be the server itself or one of the
+// allowed origins.
+type CSRF struct {
+	allowedOrigins []string
+	secure         bool
+}
+
+// NewCSRF returns the CSRF protection. The allowed origins are full origins
+// such as "https://ogsc.example.com", "*" allows every origin.
+func NewCSRF(allowedOrigins []string, secure bool) *CSRF {
+	origins := make([]string, 0, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		if origin = strings.TrimSuffix(strings.TrimSpace(origin), "/"); origin != "" {
+			origins = append(origins, strings.ToLower(origin))
+		}
+	}
+
+	return &CSRF{allowedOrigins: origins, secure: secure}
+}
+
+// Middleware gives a CSRF token to every visitor and rejects the mutating
+// requests from another origin or without the token. The API is only checked
+// for the origin: it is authenticated with API keys sent in headers, which a
+// cross-site form cannot set.
+func (c *CSRF) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := ""
+		if cookie, err := r.Cookie(CSRFCookieName); err == nil && cookie.Value != "" {
+			token = cookie.Value
+		}
+
+		if token == "" {
+			var err error
+
+			token, err = newCSRFToken()
+			if err != nil {
+				logger.Logger.Error("CSRFMiddleware error - newCSRFToken", "error", err)
+				http.Error(w, "Internal server error.", http.StatusInternalServerError)
+
+				return
+			}
+
+			http.SetCookie(w, &http.Cookie{
+				Name:     CSRFCookieName,
+				Value:    token,
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   c.secure,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+
+		if !isSafeMethod(r.Method) {
+			if !c.sameOrigin(r) {
+				logger.Logger.Warn(
+					"CSRFMiddleware - rejected request",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"origin", requestOrigin(r),
+				)
+				http.Error(w, "Cross-origin request refused.", http.StatusForbidden)
+
+				return
+			}
+
+			if !strings.HasPrefix(r.URL.Path, "/api/") && !validCSRFToken(r, token) {
+				http.Error(w, "Invalid or missing CSRF token, reload the page and try again.", http.StatusForbidden)
+
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), csrfContextKey{}, token)))
+	})
+}
+
+// CheckOrigin tells whether a WebSocket upgrade comes from the server itself
+// or from an allowed origin. Clients without Origin header are not browsers
+// and are accepted.
+func (c *CSRF) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	return c.allowed(origin, r.Host)
+}
+
+// sameOrigin checks the Origin header of a mutating request, or its Referer
+// when the browser did not send any Origin.
+func (c *CSRF) sameOrigin(r *http.Request) bool {
+	origin := requestOrigin(r)
+	if origin == "" {
+		return true
+	}
+
+	return c.allowed(origin, r.Host)
+}
+
+func (c *CSRF) allowed(origin, host string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	if strings.EqualFold(u.Host, host) {
+		return true
+	}
+
+	return slices.Contains(c.allowedOrigins, "*") ||
+		slices.Contains(c.allowedOrigins, strings.ToLower(u.Scheme+"://"+u.Host))
+}
+
+// CSRFToken returns the CSRF token of the request, to embed in the forms.
+func CSRFToken(ctx context.Context) string {
+	token, _ := ctx.Value(csrfContextKey{}).(string)
+
+	return token
+}
+
+// requestOrigin returns the Origin header, or the origin of the Referer when
+// the browser did not send any Origin. Browsers send "null" in some privacy
+// sensitive contexts, it never matches the server.
+func requestOrigin(r *http.Request) string {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		return origin
+	}
+
+	if u, err := url.Parse(r.Header.Get("Referer")); err == nil && u.Host != "" {
+		return u.Scheme + "://" + u.Host
+	}
+
+	return ""
+}
+
+func validCSRFToken(r *http.Request, token string) bool {
+	submitted := r.Header.Get("X-CSRF-Token")
+	if submitted == "" {
+		submitted = formCSRFToken(r)
+	}
+
+	return submitted != "" && subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) == 1
+}
+
+// formCSRFToken returns the CSRF token of a submitted form. Only the
+// beginning of the body is read, before any authentication, and it is put
+// back for the handlers.
+func formCSRFToken(r *http.Request) string {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || r.Body == nil ||
+		(mediaType != "application/x-www-form-urlencoded" && mediaType != "multipart/form-data") {
+		return ""
+	}
+
+	prefix, err := io.ReadAll(io.LimitReader(r.Body, csrfBodyPrefix))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(prefix), r.Body), r.Body}
+
+	if err != nil {
+		return ""
+	}
+
+	if mediaType == "application/x-www-form-urlencoded" {
+		// The last field may be cut, the error is the one of this field
+		values, _ := url.ParseQuery(string(prefix))
+
+		return values.Get(CSRFFieldName)
+	}
+
+	reader := multipart.NewReader(bytes.NewReader(prefix), params["boundary"])
+
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return ""
+		}
+
+		if part.FormName() == CSRFFieldName {
+			value, err := io.ReadAll(part)
+			if err != nil {
+				return ""
+			}
+
+			return string(value)
+		}
+	}
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+func newCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
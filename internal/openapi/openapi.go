@@ -0,0 +1,306 @@
+package openapi
+
+import (
+	"maps"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Param describes a query parameter of an endpoint.
+type Param struct {
+	Name        string
+	Description string
+	Type        string // "string", "integer" or "boolean"
+}
+
+// Endpoint describes an API endpoint. The request and response bodies are
+// given as values of the Go types encoded by the handler: their schema is
+// generated from the struct fields and their json tags.
+type Endpoint struct {
+	Method    string
+	Path      string
+	Summary   string
+	Query     []Param
+	Request   any
+	Responses map[int]any
+}
+
+// Document is an OpenAPI 3 document.
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+	Security   []map[string][]string            `json:"security"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type string `json:"type"`
+	In   string `json:"in"`
+	Name string `json:"name"`
+}
+
+type Operation struct {
+	Summary     string               `json:"summary"`
+	OperationID string               `json:"operationId"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+var pathParamRegexp = regexp.MustCompile(`\{([^}]+)\}`)
+
+// Build generates the OpenAPI document describing the endpoints.
+func Build(title string, version string, endpoints []Endpoint) Document {
+	doc := Document{
+		OpenAPI: "3.0.3",
+		Info:    Info{Title: title, Version: version},
+		Paths:   make(map[string]map[string]*Operation),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+			SecuritySchemes: map[string]SecurityScheme{
+				"apiKey": {Type: "apiKey", In: "header", Name: "X-API-Key"},
+			},
+		},
+		Security: []map[string][]string{{"apiKey": {}}},
+	}
+
+	for _, e := range endpoints {
+		op := &Operation{
+			Summary:     e.Summary,
+			OperationID: operationID(e.Method, e.Path),
+			Responses:   make(map[string]*Response),
+		}
+
+		for _, match := range pathParamRegexp.FindAllStringSubmatch(e.Path, -1) {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:     match[1],
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+
+		for _, q := range e.Query {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:        q.Name,
+				In:          "query",
+				Description: q.Description,
+				Schema:      &Schema{Type: q.Type},
+			})
+		}
+
+		if e.Request != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content: map[string]MediaType{
+					"application/json": {Schema: doc.schemaFor(reflect.TypeOf(e.Request))},
+				},
+			}
+		}
+
+		for status, body := range e.Responses {
+			response := &Response{Description: http.StatusText(status)}
+			if body != nil {
+				response.Content = map[string]MediaType{
+					"application/json": {Schema: doc.schemaFor(reflect.TypeOf(body))},
+				}
+			}
+
+			op.Responses[strconv.Itoa(status)] = response
+		}
+
+		if doc.Paths[e.Path] == nil {
+			doc.Paths[e.Path] = make(map[string]*Operation)
+		}
+
+		doc.Paths[e.Path][strings.ToLower(e.Method)] = op
+	}
+
+	return doc
+}
+
+// schemaFor returns the schema of a Go type. Named struct types are added to
+// the components and referenced.
+func (d *Document) schemaFor(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		s := d.schemaFor(t.Elem())
+		if s.Ref != "" {
+			return s
+		}
+
+		s.Nullable = true
+
+		return s
+	}
+
+	if t == reflect.TypeOf(time.Time{}) {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: d.schemaFor(t.Elem())}
+	case reflect.Map:
+		// encoding/json encodes the nil maps as null, e.g. the audit snapshot
+		// before a creation
+		return &Schema{Type: "object", Nullable: true, AdditionalProperties: d.schemaFor(t.Elem())}
+	case reflect.Struct:
+		name := schemaName(t)
+		if name == "" {
+			return d.structSchema(t)
+		}
+
+		if _, ok := d.Components.Schemas[name]; !ok {
+			// Register first to support recursive types
+			d.Components.Schemas[name] = &Schema{}
+			*d.Components.Schemas[name] = *d.structSchema(t)
+		}
+
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		return &Schema{}
+	}
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			// Embedded structs are flattened by encoding/json
+			embedded := d.structSchema(field.Type)
+			maps.Copy(s.Properties, embedded.Properties)
+			s.Required = append(s.Required, embedded.Required...)
+
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		s.Properties[name] = d.schemaFor(field.Type)
+
+		if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	sort.Strings(s.Required)
+
+	return s
+}
+
+// schemaName converts the name of a Go type to a schema name:
+// "projectJSON" becomes "Project" and "page[...summaryJSON]" becomes "SummaryPage".
+func schemaName(t reflect.Type) string {
+	return typeSchemaName(t.Name())
+}
+
+func typeSchemaName(name string) string {
+	if name == "" {
+		return ""
+	}
+
+	if base, param, ok := strings.Cut(name, "["); ok {
+		param = strings.TrimSuffix(param, "]")
+		param = param[strings.LastIndex(param, ".")+1:]
+
+		return typeSchemaName(param) + typeSchemaName(base)
+	}
+
+	name = strings.TrimSuffix(name, "JSON")
+	runes := []rune(name)
+	runes[0] = unicode.ToUpper(runes[0])
+
+	return string(runes)
+}
+
+func operationID(method string, path string) string {
+	var b strings.Builder
+
+	b.WriteString(strings.ToLower(method))
+
+	for _, part := range strings.Split(path, "/") {
+		part = strings.Trim(part, "{}")
+		if part == "" || part == "api" {
+			continue
+		}
+
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+
+	return b.String()
+}
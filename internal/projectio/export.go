@@ -0,0 +1,121 @@
+package projectio
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// ContentTypes are the MIME types of the formats.
+var ContentTypes = map[string]string{
+	FormatCSV:  "text/csv; charset=utf-8",
+	FormatJSON: "application/json",
+	FormatYAML: "application/yaml",
+}
+
+// NewEntry returns the entry describing a project, as read back by Parse.
+func NewEntry(p types.Project) Entry {
+	port, _ := strconv.Atoi(p.Port)
+
+	return Entry{
+		Name:          p.Name,
+		Host:          p.Host,
+		Port:          port,
+		Type:          p.Type,
+		AllowInsecure: p.AllowInsecure,
+		Tags:          p.Tags,
+		Team:          p.Team,
+	}
+}
+
+// Write writes the projects in the given format, in a file which can be
+// imported back.
+func Write(w io.Writer, format string, projects []types.Project) error {
+	entries := make([]Entry, 0, len(projects))
+	for _, p := range projects {
+		entries = append(entries, NewEntry(p))
+	}
+
+	switch format {
+	case FormatCSV:
+		return writeCSV(w, entries)
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(entries)
+	case FormatYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+
+		if err := encoder.Encode(entries); err != nil {
+			return err
+		}
+
+		return encoder.Close()
+	default:
+		return fmt.Errorf("unknown format %q, must be one of %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// CSVCell returns the value written in a CSV cell. The values starting like a
+// formula are prefixed with a quote, so that the spreadsheets show them as
+// text instead of evaluating them. Parse removes the quote.
+func CSVCell(value string) string {
+	if formulaLike(value) {
+		return "'" + value
+	}
+
+	return value
+}
+
+// formulaLike reports whether the value starts like a formula, or like a
+// formula prefixed by CSVCell, which is prefixed again to be read back as is.
+func formulaLike(value string) bool {
+	if value == "" {
+		return false
+	}
+
+	switch value[0] {
+	case '=', '+', '-', '@':
+		return true
+	case '\'':
+		return formulaLike(value[1:])
+	default:
+		return false
+	}
+}
+
+func writeCSV(w io.Writer, entries []Entry) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(csvColumns); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		record := []string{
+			CSVCell(e.Name),
+			CSVCell(e.Host),
+			strconv.Itoa(e.Port),
+			CSVCell(e.Type),
+			strconv.FormatBool(e.AllowInsecure),
+			CSVCell(strings.Join(e.Tags, ",")),
+			CSVCell(e.Team),
+		}
+
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
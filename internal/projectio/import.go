@@ -0,0 +1,275 @@
+// Package projectio reads and writes lists of projects in CSV, JSON and YAML,
+// to import and export them in bulk.
+package projectio
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// Supported formats.
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// Formats lists the supported formats.
+var Formats = []string{FormatCSV, FormatJSON, FormatYAML}
+
+// csvColumns are the columns of the CSV files, the tags are comma separated
+// in their cell.
+var csvColumns = []string{"name", "host", "port", "type", "allow_insecure", "tags", "team"}
+
+// Entry is a project read from an imported file.
+type Entry struct {
+	Name          string   `json:"name"                     yaml:"name"`
+	Host          string   `json:"host"                     yaml:"host"`
+	Port          int      `json:"port"                     yaml:"port"`
+	Type          string   `json:"type"                     yaml:"type"`
+	AllowInsecure bool     `json:"allow_insecure,omitempty" yaml:"allow_insecure,omitempty"`
+	Tags          []string `json:"tags,omitempty"           yaml:"tags,omitempty"`
+	Team          string   `json:"team,omitempty"           yaml:"team,omitempty"`
+
+	// Position is the line of the entry in a CSV file, its index (from 1)
+	// in the list otherwise.
+	Position int `json:"-" yaml:"-"`
+	// Error is set when the entry could not be read.
+	Error string `json:"-" yaml:"-"`
+}
+
+// document is the JSON and YAML file format: either a list of projects, or an
+// object with a projects list.
+type document struct {
+	Projects []Entry `json:"projects" yaml:"projects"`
+}
+
+// DetectFormat returns the format of a file from its name, its content type
+// or, failing that, its content.
+func DetectFormat(filename string, contentType string, data []byte) string {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".csv":
+		return FormatCSV
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	}
+
+	switch {
+	case strings.Contains(contentType, "csv"):
+		return FormatCSV
+	case strings.Contains(contentType, "json"):
+		return FormatJSON
+	case strings.Contains(contentType, "yaml"):
+		return FormatYAML
+	}
+
+	trimmed := bytes.TrimSpace(data)
+
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("[")) || bytes.HasPrefix(trimmed, []byte("{")):
+		return FormatJSON
+	case bytes.HasPrefix(bytes.ToLower(trimmed), []byte("name,")):
+		return FormatCSV
+	default:
+		return FormatYAML
+	}
+}
+
+// Parse reads the projects of a file. The entries which cannot be read are
+// returned with their Error set, so they are reported with the other invalid
+// entries.
+func Parse(format string, data []byte) ([]Entry, error) {
+	var (
+		entries []Entry
+		err     error
+	)
+
+	switch format {
+	case FormatCSV:
+		return parseCSV(data)
+	case FormatJSON:
+		entries, err = parseDocument(data, json.Unmarshal)
+	case FormatYAML:
+		entries, err = parseDocument(data, yaml.Unmarshal)
+	default:
+		return nil, fmt.Errorf("unknown format %q, must be one of %s", format, strings.Join(Formats, ", "))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", strings.ToUpper(format), err)
+	}
+
+	for i := range entries {
+		entries[i].Position = i + 1
+	}
+
+	return entries, nil
+}
+
+func parseDocument(data []byte, unmarshal func([]byte, any) error) ([]Entry, error) {
+	var entries []Entry
+
+	listErr := unmarshal(data, &entries)
+	if listErr == nil {
+		return entries, nil
+	}
+
+	var doc document
+	if err := unmarshal(data, &doc); err != nil {
+		return nil, listErr
+	}
+
+	return doc.Projects, nil
+}
+
+func parseCSV(data []byte) ([]Entry, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+	// The optional trailing columns may be missing
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(csvColumns, name) {
+			return nil, fmt.Errorf("unknown CSV column %q, expected %s", name, strings.Join(csvColumns, ", "))
+		}
+
+		columns[name] = i
+	}
+
+	var entries []Entry
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		entries = append(entries, csvEntry(record, columns, line))
+	}
+
+	return entries, nil
+}
+
+func csvEntry(record []string, columns map[string]int, line int) Entry {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			cell := strings.TrimSpace(record[i])
+			// Written by CSVCell
+			if strings.HasPrefix(cell, "'") && formulaLike(cell[1:]) {
+				return cell[1:]
+			}
+
+			return cell
+		}
+
+		return ""
+	}
+
+	entry := Entry{
+		Name:     field("name"),
+		Host:     field("host"),
+		Type:     field("type"),
+		Team:     field("team"),
+		Position: line,
+	}
+
+	for _, tag := range strings.Split(field("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			entry.Tags = append(entry.Tags, tag)
+		}
+	}
+
+	if value := field("port"); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			entry.Error = "port must be an integer"
+
+			return entry
+		}
+
+		entry.Port = port
+	}
+
+	if value := field("allow_insecure"); value != "" {
+		allowInsecure, err := strconv.ParseBool(value)
+		if err != nil {
+			entry.Error = "allow_insecure must be true or false"
+
+			return entry
+		}
+
+		entry.AllowInsecure = allowInsecure
+	}
+
+	return entry
+}
+
+// validate checks the entry like the add form does.
+func (e Entry) validate() string {
+	if e.Error != "" {
+		return e.Error
+	}
+
+	if strings.TrimSpace(e.Name) == "" || strings.TrimSpace(e.Host) == "" || e.Port == 0 ||
+		strings.TrimSpace(e.Type) == "" {
+		return "name, host, port and type are required"
+	}
+
+	if e.Port < 1 || e.Port > 65535 {
+		return "port must be between 1 and 65535"
+	}
+
+	return ""
+}
+
+// project returns the project described by the entry, without ID.
+func (e Entry) project() types.Project {
+	project := types.Project{
+		Name:          strings.TrimSpace(e.Name),
+		Host:          strings.TrimSpace(e.Host),
+		Port:          strconv.Itoa(e.Port),
+		Type:          strings.TrimSpace(e.Type),
+		AllowInsecure: e.AllowInsecure,
+		Team:          strings.TrimSpace(e.Team),
+		Tags:          []string{},
+	}
+
+	for _, tag := range e.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" && !slices.Contains(project.Tags, tag) {
+			project.Tags = append(project.Tags, tag)
+		}
+	}
+
+	return project
+}
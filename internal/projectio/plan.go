@@ -0,0 +1,140 @@
+package projectio
+
+import (
+	"fmt"
+	"slices"
+
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// Change is an existing project modified by an import.
+type Change struct {
+	Before types.Project
+	After  types.Project
+}
+
+// Invalid is an entry rejected by an import.
+type Invalid struct {
+	Position int
+	Name     string
+	Error    string
+}
+
+// Plan is the outcome of an import, computed before applying it: the projects
+// are matched by name with the existing ones.
+type Plan struct {
+	New     []types.Project
+	Changed []Change
+	// Duplicates are the entries matching an existing project without any
+	// change, or repeating an entry of the same file.
+	Duplicates []types.Project
+	Invalid    []Invalid
+}
+
+// NewPlan compares the entries with the existing projects. The imported
+// projects are managed by managedBy, empty for an import by a user: the
+// projects managed by another source cannot be changed, but can be repeated
+// unchanged. canAccessTeam tells
+// whether the projects of a team can be managed by whoever imports.
+func NewPlan(
+	existing []types.Project,
+	entries []Entry,
+	managedBy string,
+	canAccessTeam func(team string) bool,
+) Plan {
+	var plan Plan
+
+	byName := make(map[string]types.Project, len(existing))
+	for _, p := range existing {
+		byName[p.Name] = p
+	}
+
+	// First entry of each name, to report the repeated ones
+	seen := make(map[string]Entry, len(entries))
+
+	for _, entry := range entries {
+		invalid := func(format string, args ...any) {
+			plan.Invalid = append(plan.Invalid, Invalid{
+				Position: entry.Position,
+				Name:     entry.Name,
+				Error:    fmt.Sprintf(format, args...),
+			})
+		}
+
+		if msg := entry.validate(); msg != "" {
+			invalid("%s", msg)
+
+			continue
+		}
+
+		project := entry.project()
+		project.ManagedBy = managedBy
+
+		if first, ok := seen[project.Name]; ok {
+			if sameProject(first.project(), project) {
+				plan.Duplicates = append(plan.Duplicates, project)
+			} else {
+				invalid("name already used by the entry %d with other values", first.Position)
+			}
+
+			continue
+		}
+
+		seen[project.Name] = entry
+
+		if !canAccessTeam(project.Team) {
+			invalid("you cannot manage the projects of team %s", project.Team)
+
+			continue
+		}
+
+		current, ok := byName[project.Name]
+		if !ok {
+			plan.New = append(plan.New, project)
+
+			continue
+		}
+
+		if !canAccessTeam(current.Team) {
+			invalid("name already used by a project of another team")
+
+			continue
+		}
+
+		project.ID = current.ID
+
+		if current.Managed() && current.ManagedBy != managedBy {
+			// Left unchanged, e.g. exported then imported again, the project
+			// stays managed by its source
+			project.ManagedBy = current.ManagedBy
+			if sameProject(current, project) {
+				plan.Duplicates = append(plan.Duplicates, project)
+			} else {
+				invalid("project is managed by %s", current.ManagedBy)
+			}
+
+			continue
+		}
+
+		if sameProject(current, project) {
+			plan.Duplicates = append(plan.Duplicates, project)
+		} else {
+			plan.Changed = append(plan.Changed, Change{Before: current, After: project})
+		}
+	}
+
+	return plan
+}
+
+// Valid reports whether the plan can be applied: an import is applied
+// entirely or not at all.
+func (p Plan) Valid() bool {
+	return len(p.Invalid) == 0
+}
+
+// sameProject compares the imported fields of two projects.
+func sameProject(a types.Project, b types.Project) bool {
+	return a.Name == b.Name && a.Host == b.Host && a.Port == b.Port && a.Type == b.Type &&
+		a.AllowInsecure == b.AllowInsecure && a.Team == b.Team && a.ManagedBy == b.ManagedBy &&
+		slices.Equal(a.Tags, b.Tags)
+}
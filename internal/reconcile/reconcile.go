@@ -0,0 +1,170 @@
+// Package reconcile applies the projects declared by a source, such as the
+// configuration file, to the store: the declared projects are created or
+// updated and marked as managed by the source, and the managed projects no
+// longer declared are deleted or released.
+package reconcile
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"leblanc.io/open-go-ssl-checker/internal/audit"
+	"leblanc.io/open-go-ssl-checker/internal/projectio"
+	"leblanc.io/open-go-ssl-checker/internal/store"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// Checker checks the certificate of the created and changed projects.
+type Checker interface {
+	CheckAndStoreCertificate(projectID, host, port, projectType string, allowInsecure bool)
+}
+
+// Result counts the projects changed by a reconciliation.
+type Result struct {
+	Created int
+	Updated int
+	Deleted int
+	// Released are the projects no longer declared, kept without pruning.
+	Released  int
+	Unchanged int
+}
+
+// Reconciler applies the projects declared by a source.
+type Reconciler struct {
+	store   store.ProjectRepository
+	checker Checker
+	audit   *audit.Recorder
+	// source is the ManagedBy value of the declared projects.
+	source string
+	// prune deletes the projects of the source which are no longer declared.
+	prune bool
+	mu    sync.Mutex
+}
+
+func NewReconciler(
+	s store.ProjectRepository,
+	checker Checker,
+	recorder *audit.Recorder,
+	source string,
+	prune bool,
+) *Reconciler {
+	return &Reconciler{store: s, checker: checker, audit: recorder, source: source, prune: prune}
+}
+
+// Reconcile applies the declared projects. Nothing is changed when one of
+// them is invalid, or uses the name of a project managed by another source.
+// The projects of the source no longer declared are deleted when pruning, or
+// released to the users otherwise.
+func (r *Reconciler) Reconcile(entries []projectio.Entry) (Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var result Result
+
+	existing, err := r.store.ListProjects()
+	if err != nil {
+		return result, fmt.Errorf("error listing projects: %w", err)
+	}
+
+	plan := projectio.NewPlan(existing, entries, r.source, func(string) bool { return true })
+	if !plan.Valid() {
+		errs := make([]error, 0, len(plan.Invalid))
+		for _, i := range plan.Invalid {
+			errs = append(errs, fmt.Errorf("project %d (%s): %s", i.Position, i.Name, i.Error))
+		}
+
+		return result, fmt.Errorf("invalid declared projects: %w", errors.Join(errs...))
+	}
+
+	for i := range plan.New {
+		plan.New[i].ID = uuid.New().String()
+	}
+
+	updated := make([]types.Project, 0, len(plan.Changed))
+	for _, c := range plan.Changed {
+		updated = append(updated, c.After)
+	}
+
+	if err := r.store.ImportProjects(plan.New, updated); err != nil {
+		return result, fmt.Errorf("error applying declared projects: %w", err)
+	}
+
+	actor := audit.Actor{Name: r.source, Source: types.AuditSourceReconcile}
+
+	for _, p := range plan.New {
+		r.audit.Record(actor, types.AuditProjectCreate, target(p), nil, audit.ProjectSnapshot(p))
+		r.check(p)
+	}
+
+	for _, c := range plan.Changed {
+		r.audit.Record(
+			actor,
+			types.AuditProjectUpdate,
+			target(c.After),
+			audit.ProjectSnapshot(c.Before),
+			audit.ProjectSnapshot(c.After),
+		)
+
+		if c.Before.Host != c.After.Host || c.Before.Port != c.After.Port || c.Before.Type != c.After.Type ||
+			c.Before.AllowInsecure != c.After.AllowInsecure {
+			r.check(c.After)
+		}
+	}
+
+	result.Created, result.Updated, result.Unchanged = len(plan.New), len(plan.Changed), len(plan.Duplicates)
+
+	declared := make([]string, 0, len(entries))
+	for _, e := range entries {
+		declared = append(declared, strings.TrimSpace(e.Name))
+	}
+
+	for _, p := range existing {
+		if p.ManagedBy != r.source || slices.Contains(declared, p.Name) {
+			continue
+		}
+
+		if r.prune {
+			if err := r.store.DeleteProject(p.ID); err != nil {
+				return result, fmt.Errorf("error deleting project %s: %w", p.Name, err)
+			}
+
+			r.audit.Record(actor, types.AuditProjectDelete, target(p), audit.ProjectSnapshot(p), nil)
+			result.Deleted++
+
+			continue
+		}
+
+		// Without pruning, the project is kept and managed by the users again
+		released := p
+		released.ManagedBy = ""
+
+		if err := r.store.UpdateProject(released); err != nil {
+			return result, fmt.Errorf("error releasing project %s: %w", p.Name, err)
+		}
+
+		r.audit.Record(
+			actor,
+			types.AuditProjectUpdate,
+			target(p),
+			audit.ProjectSnapshot(p),
+			audit.ProjectSnapshot(released),
+		)
+		result.Released++
+	}
+
+	return result, nil
+}
+
+func (r *Reconciler) check(p types.Project) {
+	if r.checker != nil {
+		go r.checker.CheckAndStoreCertificate(p.ID, p.Host, p.Port, p.Type, p.AllowInsecure)
+	}
+}
+
+func target(p types.Project) audit.Target {
+	return audit.Target{Type: audit.TargetProject, ID: p.ID, Name: p.Name}
+}
@@ -0,0 +1,157 @@
+package reconcile
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/logger"
+	"leblanc.io/open-go-ssl-checker/internal/projectio"
+)
+
+// Source returns the declared projects and the files declaring them, which
+// are watched for changes.
+type Source func() ([]projectio.Entry, []string, error)
+
+// Watcher reconciles the declared projects at startup, on SIGHUP and when one
+// of the files declaring them changes.
+type Watcher struct {
+	reconciler *Reconciler
+	source     Source
+	interval   time.Duration
+	// modTimes are the modification times of the watched files at the last
+	// reconciliation.
+	modTimes map[string]time.Time
+	stopChan chan struct{}
+	running  sync.WaitGroup
+}
+
+// NewWatcher returns a watcher polling the files every interval, or only
+// reloading them on SIGHUP when interval is 0.
+func NewWatcher(reconciler *Reconciler, source Source, interval time.Duration) *Watcher {
+	return &Watcher{
+		reconciler: reconciler,
+		source:     source,
+		interval:   interval,
+		modTimes:   map[string]time.Time{},
+		stopChan:   make(chan struct{}),
+	}
+}
+
+// Start reconciles the declared projects, then launches the watching
+// goroutine.
+func (w *Watcher) Start() {
+	w.RunOnce()
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	// Without interval, the nil channel never ticks
+	var (
+		ticker *time.Ticker
+		tick   <-chan time.Time
+	)
+
+	if w.interval > 0 {
+		logger.Logger.Info("Watching the declared projects", "interval", w.interval)
+		ticker = time.NewTicker(w.interval)
+		tick = ticker.C
+	}
+
+	w.running.Go(func() {
+		for {
+			select {
+			case <-hangup:
+				logger.Logger.Info("SIGHUP received, reloading the declared projects")
+				w.RunOnce()
+			case <-tick:
+				if w.changed() {
+					logger.Logger.Info("Declared projects changed, reloading them")
+					w.RunOnce()
+				}
+			case <-w.stopChan:
+				signal.Stop(hangup)
+
+				if ticker != nil {
+					ticker.Stop()
+				}
+
+				logger.Logger.Info("Declared projects watcher stopped")
+
+				return
+			}
+		}
+	})
+}
+
+// Stop stops the watching goroutine and waits for the running reconciliation to
+// complete, so that the store can be closed.
+func (w *Watcher) Stop() {
+	close(w.stopChan)
+	w.running.Wait()
+}
+
+// RunOnce reads the declared projects and reconciles them. Errors are logged:
+// the store is left unchanged until the declaration is fixed.
+func (w *Watcher) RunOnce() {
+	entries, files, err := w.source()
+
+	w.modTimes = modTimes(files)
+
+	if err != nil {
+		logger.Logger.Error("Error reading the declared projects", "error", err)
+
+		return
+	}
+
+	result, err := w.reconciler.Reconcile(entries)
+	if err != nil {
+		logger.Logger.Error("Error reconciling the declared projects", "error", err)
+
+		return
+	}
+
+	logger.Logger.Info(
+		"Declared projects reconciled",
+		"created", result.Created,
+		"updated", result.Updated,
+		"deleted", result.Deleted,
+		"released", result.Released,
+		"unchanged", result.Unchanged,
+	)
+}
+
+// changed reports whether a watched file was modified, created or removed
+// since the last reconciliation.
+func (w *Watcher) changed() bool {
+	for file, modTime := range w.modTimes {
+		info, err := os.Stat(file)
+
+		switch {
+		case err != nil:
+			if !modTime.IsZero() {
+				return true
+			}
+		case !info.ModTime().Equal(modTime):
+			return true
+		}
+	}
+
+	return false
+}
+
+func modTimes(files []string) map[string]time.Time {
+	times := make(map[string]time.Time, len(files))
+
+	for _, file := range files {
+		times[file] = time.Time{}
+
+		if info, err := os.Stat(file); err == nil {
+			times[file] = info.ModTime()
+		}
+	}
+
+	return times
+}
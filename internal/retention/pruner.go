@@ -0,0 +1,118 @@
+package retention
+
+import (
+	"sync"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/logger"
+	"leblanc.io/open-go-ssl-checker/internal/store"
+)
+
+// Pruner periodically applies a retention Policy to the checks history.
+type Pruner struct {
+	store    store.Store
+	policy   Policy
+	interval time.Duration
+	stopChan chan struct{}
+	running  sync.WaitGroup
+}
+
+func NewPruner(s store.Store, policy Policy, interval time.Duration) *Pruner {
+	return &Pruner{
+		store:    s,
+		policy:   policy,
+		interval: interval,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start launches the pruning goroutine.
+func (p *Pruner) Start() {
+	logger.Logger.Info(
+		"Starting history pruner",
+		"interval", p.interval,
+		"keep_all", p.policy.KeepAll,
+		"downsample", p.policy.Downsample,
+		"max_age_months", p.policy.MaxAgeMonths,
+	)
+
+	// Without interval, the history is only pruned at startup: the nil
+	// channel never ticks
+	var (
+		ticker *time.Ticker
+		tick   <-chan time.Time
+	)
+
+	if p.interval > 0 {
+		ticker = time.NewTicker(p.interval)
+		tick = ticker.C
+	}
+
+	p.running.Go(func() {
+		p.RunOnce()
+
+		for {
+			select {
+			case <-tick:
+				p.RunOnce()
+			case <-p.stopChan:
+				if ticker != nil {
+					ticker.Stop()
+				}
+
+				logger.Logger.Info("History pruner stopped")
+
+				return
+			}
+		}
+	})
+}
+
+// Stop stops the pruning goroutine and waits for the running pruning to
+// complete, so that the store can be closed.
+func (p *Pruner) Stop() {
+	close(p.stopChan)
+	p.running.Wait()
+}
+
+// RunOnce applies the retention policy to the history of every project.
+func (p *Pruner) RunOnce() {
+	projects, err := p.store.ListProjects()
+	if err != nil {
+		logger.Logger.Error("Error retrieving projects for history pruning", "error", err)
+
+		return
+	}
+
+	start := time.Now()
+	now := start
+	deleted := 0
+
+	for _, project := range projects {
+		checks, err := p.store.GetCertificateChecksForProject(project.ID)
+		if err != nil {
+			logger.Logger.Error(
+				"Error retrieving check history for pruning",
+				"project_id", project.ID,
+				"error", err,
+			)
+
+			continue
+		}
+
+		ids := p.policy.Select(checks, now)
+		if len(ids) == 0 {
+			continue
+		}
+
+		if err := p.store.DeleteCertificateChecks(ids); err != nil {
+			logger.Logger.Error("Error pruning check history", "project_id", project.ID, "error", err)
+
+			continue
+		}
+
+		deleted += len(ids)
+	}
+
+	logger.Logger.Info("History pruning completed", "deleted", deleted, "duration", time.Since(start))
+}
@@ -0,0 +1,138 @@
+package retention
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+const (
+	DownsampleNone = "none"
+	DownsampleDay  = "day"
+	DownsampleWeek = "week"
+)
+
+// Policy describes which certificate checks are kept.
+//
+// Checks younger than KeepAll are all kept. Older checks are downsampled to
+// one check (the latest) per day or per week, and checks older than
+// MaxAgeMonths calendar months are dropped. A zero MaxAgeMonths keeps
+// downsampled checks forever.
+//
+// The latest check of a project and every check where the certificate (or the
+// check status) changed compared to the previous one are never downsampled,
+// only the MaxAgeMonths limit applies to them.
+type Policy struct {
+	KeepAll      time.Duration
+	Downsample   string
+	MaxAgeMonths int
+}
+
+// ValidateDownsample checks the downsampling of a policy: an unknown value
+// would silently keep every check.
+func ValidateDownsample(downsample string) error {
+	switch downsample {
+	case DownsampleNone, DownsampleDay, DownsampleWeek:
+		return nil
+	default:
+		return fmt.Errorf("invalid downsampling %q, expected day, week or none", downsample)
+	}
+}
+
+// Select returns the IDs of the checks to delete according to the policy.
+// The checks must belong to the same project, in any order.
+func (p Policy) Select(checks []types.CertificateCheck, now time.Time) []int64 {
+	if len(checks) == 0 {
+		return nil
+	}
+
+	sorted := make([]types.CertificateCheck, len(checks))
+	copy(sorted, checks)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CheckTime.Before(sorted[j].CheckTime)
+	})
+
+	var expired time.Time
+	if p.MaxAgeMonths > 0 {
+		expired = now.AddDate(0, -p.MaxAgeMonths, 0)
+	}
+
+	keep := make(map[int64]bool, len(sorted))
+	buckets := make(map[string]int64)
+
+	for i, c := range sorted {
+		age := now.Sub(c.CheckTime)
+
+		if !expired.IsZero() && c.CheckTime.Before(expired) {
+			continue
+		}
+
+		if age <= p.KeepAll || i == len(sorted)-1 || (i > 0 && changed(sorted[i-1], c)) {
+			keep[c.ID] = true
+
+			continue
+		}
+
+		key := p.bucket(c.CheckTime)
+		if key == "" {
+			keep[c.ID] = true
+
+			continue
+		}
+
+		// Checks are sorted chronologically: the last one seen wins the bucket
+		if previous, ok := buckets[key]; ok {
+			delete(keep, previous)
+		}
+
+		buckets[key] = c.ID
+		keep[c.ID] = true
+	}
+
+	var ids []int64
+
+	for _, c := range sorted {
+		if !keep[c.ID] {
+			ids = append(ids, c.ID)
+		}
+	}
+
+	return ids
+}
+
+func (p Policy) bucket(t time.Time) string {
+	t = t.UTC()
+
+	switch p.Downsample {
+	case DownsampleDay:
+		return t.Format("2006-01-02")
+	case DownsampleWeek:
+		year, week := t.ISOWeek()
+
+		return fmt.Sprintf("%d-W%02d", year, week)
+	default:
+		return ""
+	}
+}
+
+// changed reports whether the certificate or the check status differs
+// between two consecutive checks.
+func changed(previous, current types.CertificateCheck) bool {
+	failedBefore := previous.DaysRemaining == -1
+	failedNow := current.DaysRemaining == -1
+
+	if failedBefore != failedNow {
+		return true
+	}
+
+	if failedNow {
+		// Consecutive failures only differ by their error message
+		return false
+	}
+
+	return previous.Domains != current.Domains ||
+		previous.Issuer != current.Issuer ||
+		previous.ExpiryDate != current.ExpiryDate
+}
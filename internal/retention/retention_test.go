@@ -0,0 +1,56 @@
+package retention
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+func TestSelectMaxAgeCalendarMonths(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	policy := Policy{KeepAll: 24 * time.Hour, Downsample: DownsampleNone, MaxAgeMonths: 1}
+
+	checks := []types.CertificateCheck{
+		// 29 days old: within 30 days, but more than a calendar month
+		{ID: 1, CheckTime: time.Date(2026, 2, 14, 12, 0, 0, 0, time.UTC), ExpiryDate: "a"},
+		{ID: 2, CheckTime: time.Date(2026, 2, 16, 12, 0, 0, 0, time.UTC), ExpiryDate: "b"},
+		{ID: 3, CheckTime: now, ExpiryDate: "c"},
+	}
+
+	if got := policy.Select(checks, now); !slices.Equal(got, []int64{1}) {
+		t.Fatalf("Select() = %v, want [1]", got)
+	}
+}
+
+func TestSelectDownsampleDay(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	policy := Policy{KeepAll: 24 * time.Hour, Downsample: DownsampleDay}
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	checks := []types.CertificateCheck{
+		{ID: 1, CheckTime: day.Add(1 * time.Hour)},
+		{ID: 2, CheckTime: day.Add(2 * time.Hour)},
+		{ID: 3, CheckTime: day.Add(3 * time.Hour)},
+		{ID: 4, CheckTime: now},
+	}
+
+	if got := policy.Select(checks, now); !slices.Equal(got, []int64{1, 2}) {
+		t.Fatalf("Select() = %v, want [1 2]", got)
+	}
+}
+
+func TestValidateDownsample(t *testing.T) {
+	for _, downsample := range []string{DownsampleNone, DownsampleDay, DownsampleWeek} {
+		if err := ValidateDownsample(downsample); err != nil {
+			t.Errorf("ValidateDownsample(%q) error = %v", downsample, err)
+		}
+	}
+
+	for _, downsample := range []string{"", "weekly", "Day"} {
+		if err := ValidateDownsample(downsample); err == nil {
+			t.Errorf("ValidateDownsample(%q) error = nil, want an error", downsample)
+		}
+	}
+}
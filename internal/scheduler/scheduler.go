@@ -1,44 +1,88 @@
 package scheduler
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"leblanc.io/open-go-ssl-checker/internal/checker"
+	"leblanc.io/open-go-ssl-checker/internal/logger"
+	"leblanc.io/open-go-ssl-checker/internal/tracing"
 )
 
 type PeriodicChecker struct {
 	cs       *checker.CertificateService
 	interval time.Duration
 	stopChan chan struct{}
+	// ctx is the parent of the checks, canceled when the draining times out.
+	ctx    context.Context
+	cancel context.CancelFunc
+	runs   sync.WaitGroup
+
+	mu        sync.Mutex
+	stopped   bool
+	startedAt time.Time
+	lastRun   time.Time
+}
+
+// Status is the state of the periodic checker.
+type Status struct {
+	// Running is true between Start and Shutdown.
+	Running  bool
+	Interval time.Duration
+	// StartedAt is the start of the checker and LastRun the end of its latest
+	// series of checks, zero until the first one completes.
+	StartedAt time.Time
+	LastRun   time.Time
+}
+
+// Stale reports whether no series of checks completed for twice the interval,
+// e.g. because a check hangs or the projects cannot be listed.
+func (s Status) Stale(now time.Time) bool {
+	since := s.LastRun
+	if since.IsZero() {
+		since = s.StartedAt
+	}
+
+	return now.Sub(since) > 2*s.Interval
 }
 
 func NewPeriodicChecker(cs *checker.CertificateService, interval time.Duration) *PeriodicChecker {
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &PeriodicChecker{
 		cs:       cs,
 		interval: interval,
 		stopChan: make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
 	}
 }
 
 // Start launches the periodic checking goroutine.
 func (pc *PeriodicChecker) Start() {
-	log.Printf("Starting periodic checker with interval %v.", pc.interval)
+	logger.Logger.Info("Starting periodic checker", "interval", pc.interval)
 	ticker := time.NewTicker(pc.interval)
 
+	pc.mu.Lock()
+	pc.startedAt = time.Now()
+	pc.mu.Unlock()
+
 	go func() {
 		// Run an initial check immediately at startup (optional)
-		log.Println("Running first set of periodic checks at startup...")
+		logger.Logger.Info("Running first set of periodic checks at startup")
 		pc.runChecks()
 
 		for {
 			select {
 			case <-ticker.C:
-				log.Println("Triggering periodic certificate checks...")
+				logger.Logger.Info("Triggering periodic certificate checks")
 				pc.runChecks()
 			case <-pc.stopChan:
 				ticker.Stop()
-				log.Println("Periodic checker stopped.")
+				logger.Logger.Info("Periodic checker stopped")
 
 				return
 			}
@@ -46,9 +90,52 @@ func (pc *PeriodicChecker) Start() {
 	}()
 }
 
-// Stop stops the periodic checking goroutine.
-func (pc *PeriodicChecker) Stop() {
+// Shutdown stops the periodic checking goroutine: no series of checks starts
+// anymore and the running ones stop after their current check. It waits for
+// these checks to complete, and cancels them when ctx is done.
+func (pc *PeriodicChecker) Shutdown(ctx context.Context) error {
+	pc.mu.Lock()
+	if pc.stopped {
+		pc.mu.Unlock()
+
+		return nil
+	}
+
+	pc.stopped = true
+	pc.startedAt = time.Time{}
+	pc.mu.Unlock()
+
 	close(pc.stopChan)
+
+	drained := make(chan struct{})
+
+	go func() {
+		pc.runs.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		pc.cancel()
+		<-drained
+
+		return fmt.Errorf("error draining the periodic checks: %w", ctx.Err())
+	}
+}
+
+// Status returns the state of the periodic checker.
+func (pc *PeriodicChecker) Status() Status {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	return Status{
+		Running:   !pc.startedAt.IsZero(),
+		Interval:  pc.interval,
+		StartedAt: pc.startedAt,
+		LastRun:   pc.lastRun,
+	}
 }
 
 // RunOnce triggers a single execution of all certificate checks immediately.
@@ -58,42 +145,82 @@ func (pc *PeriodicChecker) RunOnce() {
 
 // runChecks retrieves all projects and triggers verification for each.
 func (pc *PeriodicChecker) runChecks() {
-	log.Println("Starting periodic check series.")
+	// The runs are counted under the lock, so Shutdown waits for every run
+	// started before it.
+	pc.mu.Lock()
+	if pc.stopped {
+		pc.mu.Unlock()
+
+		return
+	}
+
+	pc.runs.Add(1)
+	pc.mu.Unlock()
+
+	defer pc.runs.Done()
 
 	projects, err := pc.cs.Store.ListProjects()
 	if err != nil {
-		log.Printf(
-			"Error retrieving projects for periodic check: %v",
-			err,
-		)
+		logger.Logger.Error("Error retrieving projects for periodic check", "error", err)
 
 		return
 	}
 
 	if len(projects) == 0 {
-		log.Println("No projects to check periodically.")
+		logger.Logger.Info("No projects to check periodically")
+		pc.runDone()
 
 		return
 	}
 
-	log.Printf("Checking %d project(s)...", len(projects))
-
-	for _, project := range projects {
-		log.Printf(
-			"Periodic check for: %s (ID: %s, Host: %s:%s)",
-			project.Name,
-			project.ID,
-			project.Host,
-			project.Port,
-		)
-		pc.cs.CheckAndStoreCertificate(
-			project.ID,
-			project.Host,
-			project.Port,
-			project.Type,
-			project.AllowInsecure,
-		)
+	logger.Logger.Info("Starting periodic check series", "projects", len(projects))
+
+	start := time.Now()
+	pc.cs.Metrics.RunStarted(len(projects))
+	// The queue is emptied on every exit, including the shutdown
+	defer pc.cs.Metrics.RunEnded()
+
+	ctx, span := tracing.Start(
+		pc.ctx,
+		"scheduler.run",
+		attribute.Int("ogsc.projects", len(projects)),
+	)
+	defer span.End()
+
+	for i, project := range projects {
+		select {
+		case <-pc.stopChan:
+			logger.Logger.Info("Periodic check series interrupted by the shutdown", "checked", i, "projects", len(projects))
+
+			return
+		default:
+		}
+
+		pc.cs.Metrics.Dequeued()
+
+		// The checks are children of the run, to find the slow ones
+		checkCtx, cancel := context.WithTimeout(ctx, pc.cs.Timeout)
+		_, _, _ = pc.cs.CheckProject(checkCtx, project)
+		cancel()
+	}
+
+	// The last check was canceled by the shutdown
+	if pc.ctx.Err() != nil {
+		logger.Logger.Info("Periodic check series interrupted by the shutdown", "projects", len(projects))
+
+		return
 	}
 
-	log.Println("Periodic check series completed.")
+	duration := time.Since(start)
+	pc.cs.Metrics.RunDone(duration)
+	logger.Logger.Info("Periodic check series completed", "projects", len(projects), "duration", duration)
+	pc.runDone()
+}
+
+// runDone records the end of a series of checks.
+func (pc *PeriodicChecker) runDone() {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	pc.lastRun = time.Now()
 }
@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/checker"
+	"leblanc.io/open-go-ssl-checker/internal/metrics"
+	"leblanc.io/open-go-ssl-checker/internal/store"
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// gauge returns the value of a metric without labels.
+func gauge(t *testing.T, registry *metrics.Registry, name string) float64 {
+	t.Helper()
+
+	families, err := registry.Gatherer(nil).Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+
+	for _, family := range families {
+		if family.GetName() == name {
+			return family.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+
+	t.Fatalf("metric %s not found", name)
+
+	return 0
+}
+
+// The queue is emptied when the shutdown interrupts a run.
+func TestShutdownResetsQueue(t *testing.T) {
+	// The server accepts the connections but never answers the handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		var conns []net.Conn
+
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				for _, c := range conns {
+					c.Close()
+				}
+
+				return
+			}
+
+			conns = append(conns, conn)
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	s := store.NewMemoryStore()
+	for _, id := range []string{"p1", "p2", "p3"} {
+		if err := s.AddProject(types.Project{ID: id, Name: id, Host: host, Port: port, Type: "http"}); err != nil {
+			t.Fatalf("AddProject() error = %v", err)
+		}
+	}
+
+	cs := checker.NewCertificateService(s, nil, time.Minute)
+	cs.Metrics = metrics.NewRegistry()
+	pc := NewPeriodicChecker(cs, time.Hour)
+
+	done := make(chan struct{})
+
+	go func() {
+		pc.RunOnce()
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for gauge(t, cs.Metrics, "ogsc_checks_in_flight") != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("the first check did not start")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if queue := gauge(t, cs.Metrics, "ogsc_scheduler_queue_depth"); queue != 2 {
+		t.Fatalf("queue depth = %v during the first check, want 2", queue)
+	}
+
+	// The first check is canceled, the others are not started
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := pc.Shutdown(ctx); err == nil {
+		t.Fatal("Shutdown() error = nil, want the draining timeout")
+	}
+
+	<-done
+
+	if queue := gauge(t, cs.Metrics, "ogsc_scheduler_queue_depth"); queue != 0 {
+		t.Errorf("queue depth = %v after the shutdown, want 0", queue)
+	}
+
+	if inFlight := gauge(t, cs.Metrics, "ogsc_checks_in_flight"); inFlight != 0 {
+		t.Errorf("checks in flight = %v after the shutdown, want 0", inFlight)
+	}
+}
@@ -0,0 +1,744 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// MemoryStore is a Store keeping everything in memory. It is used for the
+// ephemeral mode and as a test double for the handlers and the checker, so it
+// behaves as the SQLite store: the checks need an existing project, and the
+// slices are copied so the callers never share them with the store.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	projects map[string]types.Project
+	checks   []types.CertificateCheck
+	nextID   int64
+	apiKeys  map[string]types.APIKey
+	users    map[string]types.User
+	sessions map[string]types.Session
+	audit    []types.AuditEntry
+	// candidates are indexed by ID.
+	candidates map[string]types.Candidate
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		projects:   make(map[string]types.Project),
+		nextID:     1,
+		apiKeys:    make(map[string]types.APIKey),
+		users:      make(map[string]types.User),
+		sessions:   make(map[string]types.Session),
+		candidates: make(map[string]types.Candidate),
+	}
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+func (s *MemoryStore) InitSchema() error {
+	return nil
+}
+
+func (s *MemoryStore) Ping(_ context.Context) error {
+	return nil
+}
+
+func (s *MemoryStore) GetSchemaVersion(_ context.Context) (int, error) {
+	return SchemaVersion, nil
+}
+
+func (s *MemoryStore) AddProject(project types.Project) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addProject(project)
+}
+
+func (s *MemoryStore) UpdateProject(project types.Project) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.updateProject(project)
+}
+
+func (s *MemoryStore) ImportProjects(added []types.Project, updated []types.Project) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Restored when a project fails, as a rolled back transaction
+	previous := maps.Clone(s.projects)
+
+	for _, project := range added {
+		if err := s.addProject(project); err != nil {
+			s.projects = previous
+
+			return err
+		}
+	}
+
+	for _, project := range updated {
+		if err := s.updateProject(project); err != nil {
+			s.projects = previous
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addProject adds the project, the caller must hold the lock.
+func (s *MemoryStore) addProject(project types.Project) error {
+	if _, ok := s.projects[project.ID]; ok {
+		return fmt.Errorf("error inserting project: id %s already exists", project.ID)
+	}
+
+	if s.nameTaken(project.Name, project.ID) {
+		return fmt.Errorf("error inserting project %s: %w", project.Name, ErrDuplicateProjectName)
+	}
+
+	s.projects[project.ID] = cloneProject(project)
+
+	return nil
+}
+
+// updateProject replaces the project, the caller must hold the lock.
+func (s *MemoryStore) updateProject(project types.Project) error {
+	if _, ok := s.projects[project.ID]; !ok {
+		return fmt.Errorf("error updating project %s: %w", project.ID, ErrProjectNotFound)
+	}
+
+	if s.nameTaken(project.Name, project.ID) {
+		return fmt.Errorf("error updating project %s: %w", project.ID, ErrDuplicateProjectName)
+	}
+
+	s.projects[project.ID] = cloneProject(project)
+
+	return nil
+}
+
+// nameTaken reports whether a project other than exceptID uses the name.
+// The caller must hold the lock.
+func (s *MemoryStore) nameTaken(name string, exceptID string) bool {
+	for _, p := range s.projects {
+		if p.Name == name && p.ID != exceptID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *MemoryStore) GetProject(id string) (*types.Project, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.projects[id]
+	if !ok {
+		return nil, nil // Project not found
+	}
+
+	p = cloneProject(p)
+
+	return &p, nil
+}
+
+func (s *MemoryStore) GetProjectName(projectID string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.projects[projectID]
+	if !ok {
+		return "", fmt.Errorf("project %s not found", projectID)
+	}
+
+	return p.Name, nil
+}
+
+func (s *MemoryStore) ListProjects() ([]types.Project, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	projects := make([]types.Project, 0, len(s.projects))
+	for _, p := range s.projects {
+		projects = append(projects, cloneProject(p))
+	}
+
+	sort.Slice(projects, func(i, j int) bool {
+		return projects[i].Name < projects[j].Name
+	})
+
+	return projects, nil
+}
+
+func (s *MemoryStore) DeleteProject(projectID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.projects, projectID)
+
+	// Mimic the ON DELETE CASCADE of the SQL schema
+	checks := s.checks[:0]
+	for _, c := range s.checks {
+		if c.ProjectID != projectID {
+			checks = append(checks, c)
+		}
+	}
+
+	s.checks = checks
+
+	return nil
+}
+
+func (s *MemoryStore) AddCertificateCheck(check types.CertificateCheck) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.projects[check.ProjectID]; !ok {
+		return fmt.Errorf("error inserting certificate check: %w", ErrProjectNotFound)
+	}
+
+	check.ID = s.nextID
+	s.nextID++
+	s.checks = append(s.checks, check)
+
+	return nil
+}
+
+func (s *MemoryStore) DeleteCertificateChecks(ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	toDelete := make(map[int64]struct{}, len(ids))
+	for _, id := range ids {
+		toDelete[id] = struct{}{}
+	}
+
+	checks := s.checks[:0]
+	for _, c := range s.checks {
+		if _, ok := toDelete[c.ID]; !ok {
+			checks = append(checks, c)
+		}
+	}
+
+	s.checks = checks
+
+	return nil
+}
+
+func (s *MemoryStore) GetCertificateChecksForProject(
+	projectID string,
+) ([]types.CertificateCheck, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	project, ok := s.projects[projectID]
+	if !ok {
+		return nil, nil
+	}
+
+	var checks []types.CertificateCheck
+
+	for _, c := range s.checks {
+		if c.ProjectID == projectID {
+			c.ProjectName = project.Name
+			checks = append(checks, c)
+		}
+	}
+
+	sort.SliceStable(checks, func(i, j int) bool {
+		return checks[i].CheckTime.After(checks[j].CheckTime)
+	})
+
+	return checks, nil
+}
+
+func (s *MemoryStore) GetLatestChecksSummary() ([]types.ProjectCheckSummary, error) {
+	projects, err := s.ListProjects()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	latest := make(map[string]types.CertificateCheck)
+
+	for _, c := range s.checks {
+		if l, ok := latest[c.ProjectID]; !ok || !c.CheckTime.Before(l.CheckTime) {
+			latest[c.ProjectID] = c
+		}
+	}
+
+	summaries := make([]types.ProjectCheckSummary, 0, len(projects))
+
+	for _, p := range projects {
+		summary := types.ProjectCheckSummary{
+			ProjectID:     p.ID,
+			ProjectName:   p.Name,
+			Host:          p.Host,
+			Port:          p.Port,
+			Type:          p.Type,
+			AllowInsecure: p.AllowInsecure,
+			Tags:          p.Tags,
+			Team:          p.Team,
+		}
+
+		if c, ok := latest[p.ID]; ok {
+			checkTime := c.CheckTime
+			days := c.DaysRemaining
+
+			summary.CheckTime = &checkTime
+			summary.Domains = c.Domains
+			summary.IP = c.IP
+			summary.Issuer = c.Issuer
+			summary.ExpiryDate = c.ExpiryDate
+			summary.DaysRemaining = &days
+		}
+
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, nil
+}
+
+func (s *MemoryStore) AddAPIKey(key types.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, k := range s.apiKeys {
+		if k.Name == key.Name {
+			return fmt.Errorf("error inserting API key %s: %w", key.Name, ErrDuplicateAPIKeyName)
+		}
+	}
+
+	s.apiKeys[key.ID] = cloneAPIKey(key)
+
+	return nil
+}
+
+func (s *MemoryStore) ListAPIKeys() ([]types.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]types.APIKey, 0, len(s.apiKeys))
+	for _, k := range s.apiKeys {
+		keys = append(keys, cloneAPIKey(k))
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name < keys[j].Name
+	})
+
+	return keys, nil
+}
+
+func (s *MemoryStore) GetAPIKeyByPrefix(prefix string) (*types.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, k := range s.apiKeys {
+		if k.Prefix == prefix {
+			k = cloneAPIKey(k)
+
+			return &k, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *MemoryStore) RevokeAPIKey(id string, revokedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.apiKeys[id]
+	if !ok {
+		return fmt.Errorf("error revoking API key %s: %w", id, ErrAPIKeyNotFound)
+	}
+
+	k.RevokedAt = &revokedAt
+	s.apiKeys[id] = k
+
+	return nil
+}
+
+func (s *MemoryStore) TouchAPIKey(id string, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.apiKeys[id]
+	if !ok {
+		return fmt.Errorf("error updating API key %s: %w", id, ErrAPIKeyNotFound)
+	}
+
+	k.LastUsedAt = &usedAt
+	s.apiKeys[id] = k
+
+	return nil
+}
+
+func (s *MemoryStore) AddUser(user types.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		// As the unique index of the OpenID Connect subjects in SQLite
+		if u.Username == user.Username || (user.Subject != "" && u.Issuer == user.Issuer && u.Subject == user.Subject) {
+			return fmt.Errorf("error inserting user %s: %w", user.Username, ErrDuplicateUsername)
+		}
+	}
+
+	s.users[user.ID] = cloneUser(user)
+
+	return nil
+}
+
+func (s *MemoryStore) GetUser(id string) (*types.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return nil, nil
+	}
+
+	u = cloneUser(u)
+
+	return &u, nil
+}
+
+func (s *MemoryStore) GetUserByUsername(username string) (*types.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if u.Username == username {
+			u = cloneUser(u)
+
+			return &u, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *MemoryStore) GetUserBySubject(issuer string, subject string) (*types.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if subject == "" {
+		return nil, nil
+	}
+
+	for _, u := range s.users {
+		if u.Issuer == issuer && u.Subject == subject {
+			u = cloneUser(u)
+
+			return &u, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *MemoryStore) ListUsers() ([]types.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]types.User, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, cloneUser(u))
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+
+	return users, nil
+}
+
+func (s *MemoryStore) UpdateUsername(id string, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return fmt.Errorf("error updating user %s: %w", id, ErrUserNotFound)
+	}
+
+	for _, other := range s.users {
+		if other.Username == username && other.ID != id {
+			return fmt.Errorf("error updating user %s: %w", id, ErrDuplicateUsername)
+		}
+	}
+
+	u.Username = username
+	s.users[id] = u
+
+	return nil
+}
+
+func (s *MemoryStore) UpdateUserSubject(id string, issuer string, subject string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return fmt.Errorf("error updating user %s: %w", id, ErrUserNotFound)
+	}
+
+	u.Issuer = issuer
+	u.Subject = subject
+	s.users[id] = u
+
+	return nil
+}
+
+func (s *MemoryStore) UpdateUserPassword(id string, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return fmt.Errorf("error updating user %s: %w", id, ErrUserNotFound)
+	}
+
+	u.PasswordHash = passwordHash
+	s.users[id] = u
+
+	return nil
+}
+
+func (s *MemoryStore) UpdateUserRole(id string, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return fmt.Errorf("error updating user %s: %w", id, ErrUserNotFound)
+	}
+
+	u.Role = role
+	s.users[id] = u
+
+	return nil
+}
+
+func (s *MemoryStore) UpdateUserTeams(id string, teams []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return fmt.Errorf("error updating user %s: %w", id, ErrUserNotFound)
+	}
+
+	u.Teams = slices.Clone(teams)
+	s.users[id] = u
+
+	return nil
+}
+
+func (s *MemoryStore) DeleteUser(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[id]; !ok {
+		return fmt.Errorf("error deleting user %s: %w", id, ErrUserNotFound)
+	}
+
+	delete(s.users, id)
+
+	for hash, session := range s.sessions {
+		if session.UserID == id {
+			delete(s.sessions, hash)
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) AddSession(session types.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[session.TokenHash] = session
+
+	return nil
+}
+
+func (s *MemoryStore) GetSession(tokenHash string) (*types.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[tokenHash]
+	if !ok {
+		return nil, nil
+	}
+
+	return &session, nil
+}
+
+func (s *MemoryStore) DeleteSession(tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, tokenHash)
+
+	return nil
+}
+
+func (s *MemoryStore) DeleteExpiredSessions(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, session := range s.sessions {
+		if !now.Before(session.ExpiresAt) {
+			delete(s.sessions, hash)
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) AddAuditEntry(entry types.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.ID = int64(len(s.audit) + 1)
+	s.audit = append(s.audit, entry)
+
+	return nil
+}
+
+func (s *MemoryStore) ListAuditEntries(filter types.AuditFilter) ([]types.AuditEntry, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var matching []types.AuditEntry
+
+	// Most recent first
+	for i := len(s.audit) - 1; i >= 0; i-- {
+		entry := s.audit[i]
+
+		if (filter.Actor != "" && entry.Actor != filter.Actor) ||
+			(filter.Action != "" && entry.Action != filter.Action) ||
+			(filter.TargetID != "" && entry.TargetID != filter.TargetID) ||
+			(filter.Since != nil && entry.Time.Before(*filter.Since)) {
+			continue
+		}
+
+		matching = append(matching, entry)
+	}
+
+	total := len(matching)
+	if filter.Offset >= total {
+		return nil, total, nil
+	}
+
+	return matching[filter.Offset:min(filter.Offset+filter.Limit, total)], total, nil
+}
+
+func (s *MemoryStore) AddCandidate(candidate types.Candidate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.candidates {
+		if c.Host == candidate.Host && c.Port == candidate.Port {
+			return fmt.Errorf("error inserting candidate %s:%s: endpoint already exists", candidate.Host, candidate.Port)
+		}
+	}
+
+	s.candidates[candidate.ID] = candidate
+
+	return nil
+}
+
+func (s *MemoryStore) GetCandidate(id string) (*types.Candidate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	candidate, ok := s.candidates[id]
+	if !ok {
+		return nil, nil
+	}
+
+	return &candidate, nil
+}
+
+func (s *MemoryStore) ListCandidates(status string) ([]types.Candidate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var candidates []types.Candidate
+
+	for _, c := range s.candidates {
+		if status == "" || c.Status == status {
+			candidates = append(candidates, c)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if !candidates[i].FirstSeen.Equal(candidates[j].FirstSeen) {
+			return candidates[i].FirstSeen.After(candidates[j].FirstSeen)
+		}
+
+		if candidates[i].Host != candidates[j].Host {
+			return candidates[i].Host < candidates[j].Host
+		}
+
+		return candidates[i].Port < candidates[j].Port
+	})
+
+	return candidates, nil
+}
+
+func (s *MemoryStore) UpdateCandidate(candidate types.Candidate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.candidates[candidate.ID]
+	if !ok {
+		return fmt.Errorf("error updating candidate %s: %w", candidate.ID, ErrCandidateNotFound)
+	}
+
+	current.Issuer = candidate.Issuer
+	current.NotAfter = candidate.NotAfter
+	current.LastSeen = candidate.LastSeen
+	current.Status = candidate.Status
+	current.ProjectID = candidate.ProjectID
+	s.candidates[candidate.ID] = current
+
+	return nil
+}
+
+func cloneProject(project types.Project) types.Project {
+	project.Tags = slices.Clone(project.Tags)
+
+	return project
+}
+
+func cloneAPIKey(key types.APIKey) types.APIKey {
+	key.Scopes = slices.Clone(key.Scopes)
+	key.Teams = slices.Clone(key.Teams)
+
+	return key
+}
+
+func cloneUser(user types.User) types.User {
+	user.Teams = slices.Clone(user.Teams)
+
+	return user
+}
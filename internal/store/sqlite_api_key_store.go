@@ -0,0 +1,150 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+const apiKeyColumns = "id, name, prefix, hash, scopes, teams, created_at, expires_at, last_used_at, revoked_at"
+
+func (s *SQLiteStore) AddAPIKey(key types.APIKey) error {
+	_, err := s.db.Exec(
+		"INSERT INTO api_keys ("+apiKeyColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		key.ID,
+		key.Name,
+		key.Prefix,
+		key.Hash,
+		joinList(key.Scopes),
+		joinList(key.Teams),
+		key.CreatedAt,
+		key.ExpiresAt,
+		key.LastUsedAt,
+		key.RevokedAt,
+	)
+	if err != nil {
+		if isUniqueConstraintError(err) {
+			return fmt.Errorf("error inserting API key %s: %w", key.Name, ErrDuplicateAPIKeyName)
+		}
+
+		return fmt.Errorf("error inserting API key: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) ListAPIKeys() ([]types.APIKey, error) {
+	rows, err := s.db.Query("SELECT " + apiKeyColumns + " FROM api_keys ORDER BY name ASC")
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving API keys list: %w", err)
+	}
+
+	defer rows.Close()
+
+	var keys []types.APIKey
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, *key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over API keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (s *SQLiteStore) GetAPIKeyByPrefix(prefix string) (*types.APIKey, error) {
+	row := s.db.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE prefix = ?", prefix)
+
+	key, err := scanAPIKey(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // API key not found
+		}
+
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func (s *SQLiteStore) RevokeAPIKey(id string, revokedAt time.Time) error {
+	return s.updateAPIKeyTime(id, "revoked_at", revokedAt)
+}
+
+func (s *SQLiteStore) TouchAPIKey(id string, usedAt time.Time) error {
+	return s.updateAPIKeyTime(id, "last_used_at", usedAt)
+}
+
+func (s *SQLiteStore) updateAPIKeyTime(id string, column string, t time.Time) error {
+	res, err := s.db.Exec("UPDATE api_keys SET "+column+" = ? WHERE id = ?", t, id)
+	if err != nil {
+		return fmt.Errorf("error updating API key %s: %w", id, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error updating API key %s: %w", id, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("error updating API key %s: %w", id, ErrAPIKeyNotFound)
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row rowScanner) (*types.APIKey, error) {
+	var key types.APIKey
+	var scopes, teams string
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+
+	err := row.Scan(
+		&key.ID,
+		&key.Name,
+		&key.Prefix,
+		&key.Hash,
+		&scopes,
+		&teams,
+		&key.CreatedAt,
+		&expiresAt,
+		&lastUsedAt,
+		&revokedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("error scanning API key: %w", err)
+	}
+
+	key.Scopes = splitList(scopes)
+	key.Teams = splitList(teams)
+
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+
+	return &key, nil
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+const auditColumns = "id, time, actor, source, remote_addr, action, target_type, target_id, target_name, " +
+	"before_value, after_value"
+
+func (s *SQLiteStore) AddAuditEntry(entry types.AuditEntry) error {
+	_, err := s.db.Exec(
+		"INSERT INTO audit_log ("+auditColumns+") VALUES (NULL, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		// Stored in UTC so the dates compare as text
+		entry.Time.UTC(),
+		entry.Actor,
+		entry.Source,
+		entry.RemoteAddr,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetID,
+		entry.TargetName,
+		entry.Before,
+		entry.After,
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting audit log entry: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) ListAuditEntries(filter types.AuditFilter) ([]types.AuditEntry, int, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+
+	if filter.Actor != "" {
+		conditions = append(conditions, "actor = ?")
+		args = append(args, filter.Actor)
+	}
+
+	if filter.Action != "" {
+		conditions = append(conditions, "action = ?")
+		args = append(args, filter.Action)
+	}
+
+	if filter.TargetID != "" {
+		conditions = append(conditions, "target_id = ?")
+		args = append(args, filter.TargetID)
+	}
+
+	if filter.Since != nil {
+		conditions = append(conditions, "time >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM audit_log"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error counting audit log entries: %w", err)
+	}
+
+	rows, err := s.db.Query(
+		"SELECT "+auditColumns+" FROM audit_log"+where+" ORDER BY time DESC, id DESC LIMIT ? OFFSET ?",
+		append(args, filter.Limit, filter.Offset)...,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error retrieving audit log entries: %w", err)
+	}
+
+	defer rows.Close()
+
+	var entries []types.AuditEntry
+
+	for rows.Next() {
+		var entry types.AuditEntry
+
+		err := rows.Scan(
+			&entry.ID,
+			&entry.Time,
+			&entry.Actor,
+			&entry.Source,
+			&entry.RemoteAddr,
+			&entry.Action,
+			&entry.TargetType,
+			&entry.TargetID,
+			&entry.TargetName,
+			&entry.Before,
+			&entry.After,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error reading audit log entry: %w", err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating over audit log entries: %w", err)
+	}
+
+	return entries, total, nil
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+const candidateColumns = "id, host, port, type, domain, source, issuer, not_after, first_seen, last_seen, status, project_id"
+
+func (s *SQLiteStore) AddCandidate(candidate types.Candidate) error {
+	_, err := s.db.Exec(
+		"INSERT INTO candidates ("+candidateColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		candidate.ID,
+		candidate.Host,
+		candidate.Port,
+		candidate.Type,
+		candidate.Domain,
+		candidate.Source,
+		candidate.Issuer,
+		candidate.NotAfter.UTC(),
+		candidate.FirstSeen.UTC(),
+		candidate.LastSeen.UTC(),
+		candidate.Status,
+		candidate.ProjectID,
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting candidate %s:%s: %w", candidate.Host, candidate.Port, err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) GetCandidate(id string) (*types.Candidate, error) {
+	row := s.db.QueryRow("SELECT "+candidateColumns+" FROM candidates WHERE id = ?", id)
+
+	candidate, err := scanCandidate(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Candidate not found
+		}
+
+		return nil, err
+	}
+
+	return candidate, nil
+}
+
+func (s *SQLiteStore) ListCandidates(status string) ([]types.Candidate, error) {
+	query := "SELECT " + candidateColumns + " FROM candidates"
+
+	var args []any
+
+	if status != "" {
+		query += " WHERE status = ?"
+		args = append(args, status)
+	}
+
+	rows, err := s.db.Query(query+" ORDER BY first_seen DESC, host ASC, port ASC", args...)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving candidates: %w", err)
+	}
+
+	defer rows.Close()
+
+	var candidates []types.Candidate
+
+	for rows.Next() {
+		candidate, err := scanCandidate(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, *candidate)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over candidates: %w", err)
+	}
+
+	return candidates, nil
+}
+
+func (s *SQLiteStore) UpdateCandidate(candidate types.Candidate) error {
+	res, err := s.db.Exec(
+		"UPDATE candidates SET issuer = ?, not_after = ?, last_seen = ?, status = ?, project_id = ? WHERE id = ?",
+		candidate.Issuer,
+		candidate.NotAfter.UTC(),
+		candidate.LastSeen.UTC(),
+		candidate.Status,
+		candidate.ProjectID,
+		candidate.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating candidate %s: %w", candidate.ID, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error updating candidate %s: %w", candidate.ID, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("error updating candidate %s: %w", candidate.ID, ErrCandidateNotFound)
+	}
+
+	return nil
+}
+
+func scanCandidate(row rowScanner) (*types.Candidate, error) {
+	var candidate types.Candidate
+
+	err := row.Scan(
+		&candidate.ID,
+		&candidate.Host,
+		&candidate.Port,
+		&candidate.Type,
+		&candidate.Domain,
+		&candidate.Source,
+		&candidate.Issuer,
+		&candidate.NotAfter,
+		&candidate.FirstSeen,
+		&candidate.LastSeen,
+		&candidate.Status,
+		&candidate.ProjectID,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("error scanning candidate: %w", err)
+	}
+
+	return &candidate, nil
+}
@@ -1,18 +1,24 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 	"leblanc.io/open-go-ssl-checker/internal/types"
 )
 
-type Store struct {
+// SQLiteStore is the database/sql implementation of Store.
+type SQLiteStore struct {
 	db *sql.DB
 }
 
-func NewStore(driver string, dsn string) (*Store, error) {
+var _ Store = (*SQLiteStore)(nil)
+
+func NewSQLiteStore(driver string, dsn string) (*SQLiteStore, error) {
 	db, err := sql.Open(driver, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("error opening database: %w", err)
@@ -24,14 +30,14 @@ func NewStore(driver string, dsn string) (*Store, error) {
 		return nil, fmt.Errorf("database ping error: %w", err)
 	}
 
-	return &Store{db: db}, nil
+	return &SQLiteStore{db: db}, nil
 }
 
-func (s *Store) Close() error {
+func (s *SQLiteStore) Close() error {
 	return s.db.Close()
 }
 
-func (s *Store) InitSchema() error {
+func (s *SQLiteStore) InitSchema() error {
 	_, err := s.db.Exec(`
         CREATE TABLE IF NOT EXISTS projects (
             id TEXT PRIMARY KEY,
@@ -39,7 +45,10 @@ func (s *Store) InitSchema() error {
             host TEXT,
             port TEXT,
             type TEXT,
-			allow_insecure BOOLEAN DEFAULT FALSE
+			allow_insecure BOOLEAN DEFAULT FALSE,
+			tags TEXT DEFAULT '',
+			team TEXT DEFAULT '',
+			managed_by TEXT DEFAULT ''
         )
     `)
 	if err != nil {
@@ -63,35 +72,326 @@ func (s *Store) InitSchema() error {
 		return fmt.Errorf("error creating certificate_checks table: %w", err)
 	}
 
+	if err := s.ensureColumn("projects", "tags", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	if err := s.ensureColumn("projects", "team", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	if err := s.ensureColumn("projects", "managed_by", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+        CREATE TABLE IF NOT EXISTS api_keys (
+            id TEXT PRIMARY KEY,
+            name TEXT UNIQUE,
+            prefix TEXT UNIQUE,
+            hash TEXT,
+            scopes TEXT,
+            created_at DATETIME,
+            expires_at DATETIME NULL,
+            last_used_at DATETIME NULL,
+            revoked_at DATETIME NULL
+        )
+    `)
+	if err != nil {
+		return fmt.Errorf("error creating api_keys table: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+        CREATE TABLE IF NOT EXISTS users (
+            id TEXT PRIMARY KEY,
+            username TEXT UNIQUE,
+            password_hash TEXT,
+            created_at DATETIME
+        );
+
+        CREATE TABLE IF NOT EXISTS sessions (
+            token_hash TEXT PRIMARY KEY,
+            user_id TEXT,
+            created_at DATETIME,
+            expires_at DATETIME,
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+        );
+    `)
+	if err != nil {
+		return fmt.Errorf("error creating users and sessions tables: %w", err)
+	}
+
+	// Users created before the roles had every right
+	if err := s.ensureColumn("users", "role", "TEXT DEFAULT 'admin'"); err != nil {
+		return err
+	}
+
+	if err := s.ensureColumn("users", "provider", "TEXT DEFAULT 'local'"); err != nil {
+		return err
+	}
+
+	if err := s.ensureColumn("users", "teams", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	if err := s.ensureColumn("users", "oidc_issuer", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	if err := s.ensureColumn("users", "oidc_subject", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+        CREATE UNIQUE INDEX IF NOT EXISTS users_oidc_subject ON users (oidc_issuer, oidc_subject)
+            WHERE oidc_subject != ''
+    `)
+	if err != nil {
+		return fmt.Errorf("error creating users_oidc_subject index: %w", err)
+	}
+
+	if err := s.ensureColumn("api_keys", "teams", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+        CREATE TABLE IF NOT EXISTS audit_log (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            time DATETIME,
+            actor TEXT,
+            source TEXT,
+            remote_addr TEXT,
+            action TEXT,
+            target_type TEXT,
+            target_id TEXT,
+            target_name TEXT,
+            before_value TEXT,
+            after_value TEXT
+        );
+
+        CREATE INDEX IF NOT EXISTS audit_log_time ON audit_log (time);
+    `)
+	if err != nil {
+		return fmt.Errorf("error creating audit_log table: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+        CREATE TABLE IF NOT EXISTS candidates (
+            id TEXT PRIMARY KEY,
+            host TEXT,
+            port TEXT DEFAULT '443',
+            type TEXT DEFAULT 'http',
+            domain TEXT,
+            source TEXT,
+            issuer TEXT,
+            not_after DATETIME,
+            first_seen DATETIME,
+            last_seen DATETIME,
+            status TEXT,
+            project_id TEXT DEFAULT '',
+            UNIQUE (host, port)
+        )
+    `)
+	if err != nil {
+		return fmt.Errorf("error creating candidates table: %w", err)
+	}
+
+	if err := s.ensureColumn("candidates", "port", "TEXT DEFAULT '443'"); err != nil {
+		return err
+	}
+
+	if err := s.ensureColumn("candidates", "type", "TEXT DEFAULT 'http'"); err != nil {
+		return err
+	}
+
+	return s.setSchemaVersion()
+}
+
+// setSchemaVersion records the version of the schema, unless the database was
+// created by a newer version.
+func (s *SQLiteStore) setSchemaVersion() error {
+	version, err := s.GetSchemaVersion(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if version >= SchemaVersion {
+		return nil
+	}
+
+	if _, err := s.db.Exec(fmt.Sprintf("PRAGMA user_version = %d", SchemaVersion)); err != nil {
+		return fmt.Errorf("error recording schema version: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) Ping(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("database ping error: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) GetSchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	if err := s.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("error reading schema version: %w", err)
+	}
+
+	return version, nil
+}
+
+// ensureColumn adds the column to the table of a database created by an
+// older version when it does not exist yet.
+func (s *SQLiteStore) ensureColumn(table string, column string, definition string) error {
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("error reading %s table structure: %w", table, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid          int
+			name         string
+			columnType   string
+			notNull      bool
+			defaultValue sql.NullString
+			primaryKey   int
+		)
+
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &primaryKey); err != nil {
+			return fmt.Errorf("error reading %s table structure: %w", table, err)
+		}
+
+		if name == column {
+			return nil
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading %s table structure: %w", table, err)
+	}
+
+	if _, err := s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("error adding column %s to %s table: %w", column, table, err)
+	}
+
 	return nil
 }
 
-func (s *Store) AddProject(project types.Project) error {
-	_, err := s.db.Exec(
-		"INSERT INTO projects (id, name, host, port, type, allow_insecure) VALUES (?, ?, ?, ?, ?, ?)",
+// execer runs the statements on the database or in a transaction.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func (s *SQLiteStore) AddProject(project types.Project) error {
+	return insertProject(s.db, project)
+}
+
+func (s *SQLiteStore) UpdateProject(project types.Project) error {
+	return updateProject(s.db, project)
+}
+
+func (s *SQLiteStore) ImportProjects(added []types.Project, updated []types.Project) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+
+	for _, project := range added {
+		if err := insertProject(tx, project); err != nil {
+			tx.Rollback()
+
+			return err
+		}
+	}
+
+	for _, project := range updated {
+		if err := updateProject(tx, project); err != nil {
+			tx.Rollback()
+
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func insertProject(e execer, project types.Project) error {
+	_, err := e.Exec(
+		"INSERT INTO projects (id, name, host, port, type, allow_insecure, tags, team, managed_by) "+
+			"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
 		project.ID,
 		project.Name,
 		project.Host,
 		project.Port,
 		project.Type,
 		project.AllowInsecure,
+		joinList(project.Tags),
+		project.Team,
+		project.ManagedBy,
 	)
 	if err != nil {
+		if isUniqueConstraintError(err) {
+			return fmt.Errorf("error inserting project %s: %w", project.Name, ErrDuplicateProjectName)
+		}
+
 		return fmt.Errorf("error inserting project: %w", err)
 	}
 
 	return nil
 }
 
-func (s *Store) GetProject(id string) (*types.Project, error) {
+func updateProject(e execer, project types.Project) error {
+	res, err := e.Exec(
+		"UPDATE projects SET name = ?, host = ?, port = ?, type = ?, allow_insecure = ?, tags = ?, team = ?, "+
+			"managed_by = ? WHERE id = ?",
+		project.Name,
+		project.Host,
+		project.Port,
+		project.Type,
+		project.AllowInsecure,
+		joinList(project.Tags),
+		project.Team,
+		project.ManagedBy,
+		project.ID,
+	)
+	if err != nil {
+		if isUniqueConstraintError(err) {
+			return fmt.Errorf("error updating project %s: %w", project.ID, ErrDuplicateProjectName)
+		}
+
+		return fmt.Errorf("error updating project %s: %w", project.ID, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error updating project %s: %w", project.ID, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("error updating project %s: %w", project.ID, ErrProjectNotFound)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) GetProject(id string) (*types.Project, error) {
 	row := s.db.QueryRow(
-		"SELECT id, name, host, port, type, allow_insecure FROM projects WHERE id = ?",
+		"SELECT id, name, host, port, type, allow_insecure, tags, team, managed_by FROM projects WHERE id = ?",
 		id,
 	)
 
 	var p types.Project
+	var tags, team, managedBy sql.NullString
 
-	if err := row.Scan(&p.ID, &p.Name, &p.Host, &p.Port, &p.Type, &p.AllowInsecure); err != nil {
+	if err := row.Scan(
+		&p.ID, &p.Name, &p.Host, &p.Port, &p.Type, &p.AllowInsecure, &tags, &team, &managedBy,
+	); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // Project not found
 		}
@@ -99,12 +399,16 @@ func (s *Store) GetProject(id string) (*types.Project, error) {
 		return nil, fmt.Errorf("error retrieving project %s: %w", id, err)
 	}
 
+	p.Tags = splitList(tags.String)
+	p.Team = team.String
+	p.ManagedBy = managedBy.String
+
 	return &p, nil
 }
 
-func (s *Store) ListProjects() ([]types.Project, error) {
+func (s *SQLiteStore) ListProjects() ([]types.Project, error) {
 	rows, err := s.db.Query(
-		"SELECT id, name, host, port, type, allow_insecure FROM projects ORDER BY name ASC",
+		"SELECT id, name, host, port, type, allow_insecure, tags, team, managed_by FROM projects ORDER BY name ASC",
 	)
 	if err != nil {
 		return nil, fmt.Errorf("error retrieving projects list: %w", err)
@@ -116,10 +420,18 @@ func (s *Store) ListProjects() ([]types.Project, error) {
 
 	for rows.Next() {
 		var p types.Project
-		if err := rows.Scan(&p.ID, &p.Name, &p.Host, &p.Port, &p.Type, &p.AllowInsecure); err != nil {
+		var tags, team, managedBy sql.NullString
+
+		if err := rows.Scan(
+			&p.ID, &p.Name, &p.Host, &p.Port, &p.Type, &p.AllowInsecure, &tags, &team, &managedBy,
+		); err != nil {
 			return nil, fmt.Errorf("error scanning project: %w", err)
 		}
 
+		p.Tags = splitList(tags.String)
+		p.Team = team.String
+		p.ManagedBy = managedBy.String
+
 		projects = append(projects, p)
 	}
 
@@ -130,12 +442,20 @@ func (s *Store) ListProjects() ([]types.Project, error) {
 	return projects, nil
 }
 
-func (s *Store) DeleteProject(projectID string) error {
+func (s *SQLiteStore) DeleteProject(projectID string) error {
 	tx, err := s.db.Begin()
 	if err != nil {
 		return fmt.Errorf("error starting transaction: %w", err)
 	}
 
+	// The foreign keys are not enforced by default, delete the history too
+	_, err = tx.Exec("DELETE FROM certificate_checks WHERE project_id = ?", projectID)
+	if err != nil {
+		tx.Rollback()
+
+		return fmt.Errorf("error deleting checks of project %s: %w", projectID, err)
+	}
+
 	_, err = tx.Exec("DELETE FROM projects WHERE id = ?", projectID)
 	if err != nil {
 		tx.Rollback()
@@ -146,20 +466,56 @@ func (s *Store) DeleteProject(projectID string) error {
 	return tx.Commit()
 }
 
-func (s *Store) AddCertificateCheck(check types.CertificateCheck) error {
-	_, err := s.db.Exec(`
+// AddCertificateCheck stores the check, or returns ErrProjectNotFound when its
+// project does not exist (e.g. deleted while it was checked).
+func (s *SQLiteStore) AddCertificateCheck(check types.CertificateCheck) error {
+	result, err := s.db.Exec(`
         INSERT INTO certificate_checks (
             check_time, project_id, domains, ip, issuer, expiry_date, days_remaining
-        ) VALUES (?, ?, ?, ?, ?, ?, ?)
-    `, check.CheckTime, check.ProjectID, check.Domains, check.IP, check.Issuer, check.ExpiryDate, check.DaysRemaining)
+        ) SELECT ?, id, ?, ?, ?, ?, ? FROM projects WHERE id = ?
+    `, check.CheckTime, check.Domains, check.IP, check.Issuer, check.ExpiryDate, check.DaysRemaining, check.ProjectID)
 	if err != nil {
 		return fmt.Errorf("error inserting certificate check: %w", err)
 	}
 
+	if inserted, err := result.RowsAffected(); err == nil && inserted == 0 {
+		return fmt.Errorf("error inserting certificate check: %w", ErrProjectNotFound)
+	}
+
 	return nil
 }
 
-func (s *Store) GetCertificateChecksForProject(projectID string) ([]types.CertificateCheck, error) {
+// DeleteCertificateChecks removes the given checks in a single transaction.
+func (s *SQLiteStore) DeleteCertificateChecks(ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+
+	stmt, err := tx.Prepare("DELETE FROM certificate_checks WHERE id = ?")
+	if err != nil {
+		tx.Rollback()
+
+		return fmt.Errorf("error preparing certificate checks deletion: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, id := range ids {
+		if _, err := stmt.Exec(id); err != nil {
+			tx.Rollback()
+
+			return fmt.Errorf("error deleting certificate check %d: %w", id, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) GetCertificateChecksForProject(projectID string) ([]types.CertificateCheck, error) {
 	query := `
         SELECT cc.id, cc.check_time, cc.project_id, p.name, cc.domains, cc.ip, cc.issuer, cc.expiry_date, cc.days_remaining
         FROM certificate_checks cc
@@ -210,7 +566,7 @@ func (s *Store) GetCertificateChecksForProject(projectID string) ([]types.Certif
 	return checks, nil
 }
 
-func (s *Store) GetLatestChecksSummary() ([]types.ProjectCheckSummary, error) {
+func (s *SQLiteStore) GetLatestChecksSummary() ([]types.ProjectCheckSummary, error) {
 	query := `
         SELECT
             p.id,
@@ -219,6 +575,8 @@ func (s *Store) GetLatestChecksSummary() ([]types.ProjectCheckSummary, error) {
             p.port,
             p.type,
 			p.allow_insecure,
+			p.tags,
+			p.team,
             cc.check_time,
             cc.domains,
 			cc.ip,
@@ -255,6 +613,7 @@ func (s *Store) GetLatestChecksSummary() ([]types.ProjectCheckSummary, error) {
 	for rows.Next() {
 		var s types.ProjectCheckSummary
 
+		var tags, team sql.NullString
 		var checkTime sql.NullTime
 		var domains sql.NullString
 		var ip sql.NullString
@@ -263,12 +622,15 @@ func (s *Store) GetLatestChecksSummary() ([]types.ProjectCheckSummary, error) {
 		var daysRemaining sql.NullInt64
 
 		if err := rows.Scan(
-			&s.ProjectID, &s.ProjectName, &s.Host, &s.Port, &s.Type, &s.AllowInsecure,
+			&s.ProjectID, &s.ProjectName, &s.Host, &s.Port, &s.Type, &s.AllowInsecure, &tags, &team,
 			&checkTime, &domains, &ip, &issuer, &expiryDate, &daysRemaining,
 		); err != nil {
 			return nil, fmt.Errorf("error scanning check summary: %w", err)
 		}
 
+		s.Tags = splitList(tags.String)
+		s.Team = team.String
+
 		if checkTime.Valid {
 			s.CheckTime = &checkTime.Time
 		}
@@ -304,7 +666,7 @@ func (s *Store) GetLatestChecksSummary() ([]types.ProjectCheckSummary, error) {
 	return summaries, nil
 }
 
-func (s *Store) GetProjectName(projectID string) (string, error) {
+func (s *SQLiteStore) GetProjectName(projectID string) (string, error) {
 	var name string
 	err := s.db.QueryRow("SELECT name FROM projects WHERE id = ?", projectID).Scan(&name)
 	if err != nil {
@@ -321,3 +683,30 @@ func (s *Store) GetProjectName(projectID string) (string, error) {
 
 	return name, nil
 }
+
+func isUniqueConstraintError(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+
+	return false
+}
+
+// joinList serializes a list (tags, scopes...) for a comma separated column.
+func joinList(values []string) string {
+	return strings.Join(values, ",")
+}
+
+// splitList parses a comma separated column.
+func splitList(value string) []string {
+	var values []string
+
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
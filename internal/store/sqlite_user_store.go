@@ -0,0 +1,265 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+const userColumns = "id, username, password_hash, role, provider, teams, created_at, oidc_issuer, oidc_subject"
+
+func (s *SQLiteStore) AddUser(user types.User) error {
+	_, err := s.db.Exec(
+		"INSERT INTO users ("+userColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		user.ID,
+		user.Username,
+		user.PasswordHash,
+		user.Role,
+		user.Provider,
+		joinList(user.Teams),
+		user.CreatedAt,
+		user.Issuer,
+		user.Subject,
+	)
+	if err != nil {
+		if isUniqueConstraintError(err) {
+			return fmt.Errorf("error inserting user %s: %w", user.Username, ErrDuplicateUsername)
+		}
+
+		return fmt.Errorf("error inserting user: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) GetUser(id string) (*types.User, error) {
+	return s.getUser("id", id)
+}
+
+func (s *SQLiteStore) GetUserByUsername(username string) (*types.User, error) {
+	return s.getUser("username", username)
+}
+
+func (s *SQLiteStore) GetUserBySubject(issuer string, subject string) (*types.User, error) {
+	if subject == "" {
+		return nil, nil
+	}
+
+	row := s.db.QueryRow(
+		"SELECT "+userColumns+" FROM users WHERE oidc_issuer = ? AND oidc_subject = ?",
+		issuer,
+		subject,
+	)
+
+	user, err := scanUser(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // User not found
+		}
+
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *SQLiteStore) getUser(column string, value string) (*types.User, error) {
+	row := s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE "+column+" = ?", value)
+
+	user, err := scanUser(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // User not found
+		}
+
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *SQLiteStore) ListUsers() ([]types.User, error) {
+	rows, err := s.db.Query("SELECT " + userColumns + " FROM users ORDER BY username ASC")
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving users list: %w", err)
+	}
+
+	defer rows.Close()
+
+	var users []types.User
+
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, *user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over users: %w", err)
+	}
+
+	return users, nil
+}
+
+func (s *SQLiteStore) UpdateUsername(id string, username string) error {
+	err := s.updateUser(id, "username", username)
+	if isUniqueConstraintError(err) {
+		return fmt.Errorf("error updating user %s: %w", id, ErrDuplicateUsername)
+	}
+
+	return err
+}
+
+func (s *SQLiteStore) UpdateUserSubject(id string, issuer string, subject string) error {
+	res, err := s.db.Exec("UPDATE users SET oidc_issuer = ?, oidc_subject = ? WHERE id = ?", issuer, subject, id)
+	if err != nil {
+		return fmt.Errorf("error updating user %s: %w", id, err)
+	}
+
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("error updating user %s: %w", id, ErrUserNotFound)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) UpdateUserPassword(id string, passwordHash string) error {
+	return s.updateUser(id, "password_hash", passwordHash)
+}
+
+func (s *SQLiteStore) UpdateUserRole(id string, role string) error {
+	return s.updateUser(id, "role", role)
+}
+
+func (s *SQLiteStore) UpdateUserTeams(id string, teams []string) error {
+	return s.updateUser(id, "teams", joinList(teams))
+}
+
+func (s *SQLiteStore) updateUser(id string, column string, value string) error {
+	res, err := s.db.Exec("UPDATE users SET "+column+" = ? WHERE id = ?", value, id)
+	if err != nil {
+		return fmt.Errorf("error updating user %s: %w", id, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error updating user %s: %w", id, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("error updating user %s: %w", id, ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// DeleteUser removes the user and its sessions in a single transaction.
+func (s *SQLiteStore) DeleteUser(id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = ?", id); err != nil {
+		tx.Rollback()
+
+		return fmt.Errorf("error deleting sessions of user %s: %w", id, err)
+	}
+
+	res, err := tx.Exec("DELETE FROM users WHERE id = ?", id)
+	if err != nil {
+		tx.Rollback()
+
+		return fmt.Errorf("error deleting user %s: %w", id, err)
+	}
+
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		tx.Rollback()
+
+		return fmt.Errorf("error deleting user %s: %w", id, ErrUserNotFound)
+	}
+
+	return tx.Commit()
+}
+
+func scanUser(row rowScanner) (*types.User, error) {
+	var user types.User
+	var teams string
+
+	err := row.Scan(
+		&user.ID,
+		&user.Username,
+		&user.PasswordHash,
+		&user.Role,
+		&user.Provider,
+		&teams,
+		&user.CreatedAt,
+		&user.Issuer,
+		&user.Subject,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("error scanning user: %w", err)
+	}
+
+	user.Teams = splitList(teams)
+
+	return &user, nil
+}
+
+func (s *SQLiteStore) AddSession(session types.Session) error {
+	_, err := s.db.Exec(
+		"INSERT INTO sessions (token_hash, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		session.TokenHash,
+		session.UserID,
+		session.CreatedAt,
+		session.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting session: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) GetSession(tokenHash string) (*types.Session, error) {
+	var session types.Session
+
+	err := s.db.QueryRow(
+		"SELECT token_hash, user_id, created_at, expires_at FROM sessions WHERE token_hash = ?",
+		tokenHash,
+	).Scan(&session.TokenHash, &session.UserID, &session.CreatedAt, &session.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Session not found
+		}
+
+		return nil, fmt.Errorf("error retrieving session: %w", err)
+	}
+
+	return &session, nil
+}
+
+func (s *SQLiteStore) DeleteSession(tokenHash string) error {
+	if _, err := s.db.Exec("DELETE FROM sessions WHERE token_hash = ?", tokenHash); err != nil {
+		return fmt.Errorf("error deleting session: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) DeleteExpiredSessions(now time.Time) error {
+	if _, err := s.db.Exec("DELETE FROM sessions WHERE expires_at <= ?", now); err != nil {
+		return fmt.Errorf("error deleting expired sessions: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// MemoryDriver is the database driver name selecting the in-memory store.
+// Nothing is persisted: every project and check is lost on restart.
+const MemoryDriver = "memory"
+
+// SchemaVersion is the version of the schema created by InitSchema, raised
+// with every change of the schema.
+const SchemaVersion = 2
+
+var (
+	// ErrProjectNotFound is returned when the requested project does not exist.
+	ErrProjectNotFound = errors.New("project not found")
+	// ErrDuplicateProjectName is returned when another project already uses the name.
+	ErrDuplicateProjectName = errors.New("project name already exists")
+	// ErrAPIKeyNotFound is returned when the requested API key does not exist.
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	// ErrDuplicateAPIKeyName is returned when another API key already uses the name.
+	ErrDuplicateAPIKeyName = errors.New("API key name already exists")
+	// ErrUserNotFound is returned when the requested user does not exist.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrDuplicateUsername is returned when another user already uses the username.
+	ErrDuplicateUsername = errors.New("username already exists")
+	// ErrCandidateNotFound is returned when the requested candidate does not exist.
+	ErrCandidateNotFound = errors.New("candidate not found")
+)
+
+// ProjectRepository gives access to the monitored projects.
+type ProjectRepository interface {
+	AddProject(project types.Project) error
+	GetProject(id string) (*types.Project, error)
+	GetProjectName(projectID string) (string, error)
+	ListProjects() ([]types.Project, error)
+	UpdateProject(project types.Project) error
+	DeleteProject(projectID string) error
+	// ImportProjects adds and updates projects in a single transaction:
+	// nothing is changed when one of them fails.
+	ImportProjects(added []types.Project, updated []types.Project) error
+}
+
+// CheckRepository gives access to the certificate checks history.
+type CheckRepository interface {
+	AddCertificateCheck(check types.CertificateCheck) error
+	GetCertificateChecksForProject(projectID string) ([]types.CertificateCheck, error)
+	DeleteCertificateChecks(ids []int64) error
+}
+
+// SummaryRepository gives access to the latest check of each project.
+type SummaryRepository interface {
+	GetLatestChecksSummary() ([]types.ProjectCheckSummary, error)
+}
+
+// APIKeyRepository gives access to the API keys.
+type APIKeyRepository interface {
+	AddAPIKey(key types.APIKey) error
+	ListAPIKeys() ([]types.APIKey, error)
+	GetAPIKeyByPrefix(prefix string) (*types.APIKey, error)
+	RevokeAPIKey(id string, revokedAt time.Time) error
+	TouchAPIKey(id string, usedAt time.Time) error
+}
+
+// UserRepository gives access to the web UI users.
+type UserRepository interface {
+	AddUser(user types.User) error
+	GetUser(id string) (*types.User, error)
+	GetUserByUsername(username string) (*types.User, error)
+	// GetUserBySubject returns the OpenID Connect user of the issuer with
+	// the subject, or nil.
+	GetUserBySubject(issuer string, subject string) (*types.User, error)
+	ListUsers() ([]types.User, error)
+	UpdateUsername(id string, username string) error
+	// UpdateUserSubject links the user to its OpenID Connect subject.
+	UpdateUserSubject(id string, issuer string, subject string) error
+	UpdateUserPassword(id string, passwordHash string) error
+	UpdateUserRole(id string, role string) error
+	UpdateUserTeams(id string, teams []string) error
+	DeleteUser(id string) error
+}
+
+// SessionRepository gives access to the web UI sessions.
+type SessionRepository interface {
+	AddSession(session types.Session) error
+	GetSession(tokenHash string) (*types.Session, error)
+	DeleteSession(tokenHash string) error
+	DeleteExpiredSessions(now time.Time) error
+}
+
+// AuditRepository gives access to the audit log.
+type AuditRepository interface {
+	AddAuditEntry(entry types.AuditEntry) error
+	// ListAuditEntries returns the requested page of entries and the total
+	// number of entries matching the filter.
+	ListAuditEntries(filter types.AuditFilter) ([]types.AuditEntry, int, error)
+}
+
+// CandidateRepository gives access to the hosts proposed by the discoveries.
+type CandidateRepository interface {
+	AddCandidate(candidate types.Candidate) error
+	GetCandidate(id string) (*types.Candidate, error)
+	// ListCandidates returns the candidates with the status, every candidate
+	// when empty, most recently found first.
+	ListCandidates(status string) ([]types.Candidate, error)
+	UpdateCandidate(candidate types.Candidate) error
+}
+
+// Store is the storage backend used by the handlers, the checker and the
+// WebSocket hub.
+type Store interface {
+	ProjectRepository
+	CheckRepository
+	SummaryRepository
+	APIKeyRepository
+	UserRepository
+	SessionRepository
+	AuditRepository
+	CandidateRepository
+
+	InitSchema() error
+	Close() error
+
+	// Ping checks that the database answers.
+	Ping(ctx context.Context) error
+	// GetSchemaVersion returns the version of the schema of the database,
+	// SchemaVersion once InitSchema ran.
+	GetSchemaVersion(ctx context.Context) (int, error)
+}
+
+// Open returns the Store matching the given driver: the in-memory store for
+// MemoryDriver, a database/sql backed store otherwise.
+func Open(driver string, dsn string) (Store, error) {
+	if driver == MemoryDriver {
+		return NewMemoryStore(), nil
+	}
+
+	s, err := NewSQLiteStore(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s store: %w", driver, err)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"leblanc.io/open-go-ssl-checker/internal/types"
+)
+
+// backends returns the stores which must behave the same way.
+func backends(t *testing.T) map[string]Store {
+	t.Helper()
+
+	sqlite, err := Open("sqlite3", filepath.Join(t.TempDir(), "ogsc.db"))
+	if err != nil {
+		t.Fatalf("error opening SQLite store: %v", err)
+	}
+
+	t.Cleanup(func() { sqlite.Close() })
+
+	if err := sqlite.InitSchema(); err != nil {
+		t.Fatalf("error initializing SQLite schema: %v", err)
+	}
+
+	return map[string]Store{"memory": NewMemoryStore(), "sqlite": sqlite}
+}
+
+func TestAddCertificateCheckUnknownProject(t *testing.T) {
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			err := s.AddCertificateCheck(types.CertificateCheck{ProjectID: "unknown", CheckTime: time.Now()})
+			if !errors.Is(err, ErrProjectNotFound) {
+				t.Fatalf("AddCertificateCheck() error = %v, want ErrProjectNotFound", err)
+			}
+		})
+	}
+}
+
+func TestDeleteProjectDeletesChecks(t *testing.T) {
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			project := types.Project{ID: "p1", Name: "example", Host: "example.com", Port: "443", Type: "http"}
+			if err := s.AddProject(project); err != nil {
+				t.Fatalf("AddProject() error = %v", err)
+			}
+
+			if err := s.AddCertificateCheck(types.CertificateCheck{ProjectID: "p1", CheckTime: time.Now()}); err != nil {
+				t.Fatalf("AddCertificateCheck() error = %v", err)
+			}
+
+			if err := s.DeleteProject("p1"); err != nil {
+				t.Fatalf("DeleteProject() error = %v", err)
+			}
+
+			// Recreated, the project must not inherit the history
+			if err := s.AddProject(project); err != nil {
+				t.Fatalf("AddProject() error = %v", err)
+			}
+
+			checks, err := s.GetCertificateChecksForProject("p1")
+			if err != nil {
+				t.Fatalf("GetCertificateChecksForProject() error = %v", err)
+			}
+
+			if len(checks) != 0 {
+				t.Fatalf("got %d checks after the deletion, want 0", len(checks))
+			}
+		})
+	}
+}
+
+func TestSlicesNotShared(t *testing.T) {
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			tags := []string{"a", "b"}
+			if err := s.AddProject(types.Project{ID: "p1", Name: "p", Host: "h", Port: "443", Tags: tags}); err != nil {
+				t.Fatalf("AddProject() error = %v", err)
+			}
+
+			tags[0] = "changed"
+
+			project, err := s.GetProject("p1")
+			if err != nil || project == nil {
+				t.Fatalf("GetProject() = %v, %v", project, err)
+			}
+
+			project.Tags[1] = "changed"
+
+			project, _ = s.GetProject("p1")
+			if project.Tags[0] != "a" || project.Tags[1] != "b" {
+				t.Fatalf("stored tags = %v, want [a b]", project.Tags)
+			}
+
+			teams := []string{"ops"}
+			if err := s.AddUser(types.User{ID: "u1", Username: "alice", Role: types.RoleViewer, Teams: teams}); err != nil {
+				t.Fatalf("AddUser() error = %v", err)
+			}
+
+			teams[0] = "changed"
+
+			user, err := s.GetUserByUsername("alice")
+			if err != nil || user == nil {
+				t.Fatalf("GetUserByUsername() = %v, %v", user, err)
+			}
+
+			user.Teams[0] = "changed"
+
+			if user, _ = s.GetUser("u1"); user.Teams[0] != "ops" {
+				t.Fatalf("stored teams = %v, want [ops]", user.Teams)
+			}
+		})
+	}
+}
+
+func TestGetUserBySubject(t *testing.T) {
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			user := types.User{
+				ID: "u1", Username: "alice", Role: types.RoleViewer, Provider: types.ProviderOIDC,
+				Issuer: "https://idp.example.com", Subject: "alice-id",
+			}
+			if err := s.AddUser(user); err != nil {
+				t.Fatalf("AddUser() error = %v", err)
+			}
+
+			if err := s.UpdateUsername("u1", "alice.martin"); err != nil {
+				t.Fatalf("UpdateUsername() error = %v", err)
+			}
+
+			got, err := s.GetUserBySubject("https://idp.example.com", "alice-id")
+			if err != nil || got == nil || got.ID != "u1" || got.Username != "alice.martin" {
+				t.Fatalf("GetUserBySubject() = %+v, %v, want the renamed user", got, err)
+			}
+
+			if got, _ := s.GetUserBySubject("https://other.example.com", "alice-id"); got != nil {
+				t.Fatalf("GetUserBySubject() = %+v for another issuer, want nil", got)
+			}
+
+			if err := s.AddUser(types.User{ID: "u2", Username: "bob", Role: types.RoleViewer}); err != nil {
+				t.Fatalf("AddUser() error = %v", err)
+			}
+
+			if err := s.UpdateUsername("u2", "alice.martin"); !errors.Is(err, ErrDuplicateUsername) {
+				t.Fatalf("UpdateUsername() error = %v, want ErrDuplicateUsername", err)
+			}
+		})
+	}
+}
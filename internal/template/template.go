@@ -3,29 +3,48 @@ package template
 import (
 	"embed"
 	"html/template"
+	"maps"
 	"net/http"
 	"strings"
 
+	"leblanc.io/open-go-ssl-checker/internal/auth"
 	"leblanc.io/open-go-ssl-checker/internal/localizer"
+	"leblanc.io/open-go-ssl-checker/internal/middleware"
 	"leblanc.io/open-go-ssl-checker/templates"
 )
 
 var files embed.FS = templates.GetFiles()
 
 var funcs template.FuncMap = template.FuncMap{
-	"ToUpper": strings.ToUpper,
+	"ToUpper":     strings.ToUpper,
+	"StringsJoin": strings.Join,
 	"Defer": func(number *int) int {
 		return *number // Dereference pointer to get the actual value of number
 	},
 }
 
-func getTemplates(file string, acceptLanguage string) *template.Template {
-	l := localizer.Get(acceptLanguage)
-	funcs["Translate"] = l.Translate
+// getTemplates parses the page with the functions bound to the request: the
+// translations of its language, its viewer and its CSRF token.
+func getTemplates(file string, r *http.Request) *template.Template {
+	l := localizer.Get(r.Header.Get("Accept-Language"))
+	viewer := auth.ViewerFromContext(r.Context())
+
+	requestFuncs := maps.Clone(funcs)
+	requestFuncs["Translate"] = l.Translate
+	requestFuncs["Viewer"] = func() auth.Viewer {
+		return viewer
+	}
+
+	csrfToken := middleware.CSRFToken(r.Context())
+	requestFuncs["CSRFField"] = func() template.HTML {
+		return template.HTML(`<input type="hidden" name="` + middleware.CSRFFieldName + `" value="` +
+			template.HTMLEscapeString(csrfToken) + `">`)
+	}
+
 	return template.Must(
-		template.New("layout.html").Funcs(funcs).ParseFS(files, "layout.html", file+".html"))
+		template.New("layout.html").Funcs(requestFuncs).ParseFS(files, "layout.html", file+".html"))
 }
 
-func Execute(w http.ResponseWriter, file string, acceptLanguage string, data interface{}) {
-	getTemplates(file, acceptLanguage).Execute(w, data)
+func Execute(w http.ResponseWriter, r *http.Request, file string, data interface{}) {
+	getTemplates(file, r).Execute(w, data)
 }
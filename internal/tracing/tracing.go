@@ -0,0 +1,107 @@
+// Package tracing configures the OpenTelemetry SDK exporting the spans of the
+// certificate checks to a collector with OTLP over HTTP, and the W3C trace
+// context propagation.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const scopeName = "leblanc.io/open-go-ssl-checker"
+
+// Config configures the export of the spans.
+type Config struct {
+	// Endpoint is the base URL of the OTLP/HTTP receiver, e.g.
+	// http://localhost:4318, the spans are posted to <endpoint>/v1/traces.
+	Endpoint string
+	// Headers are added to the export requests, e.g. for authentication.
+	Headers map[string]string
+	// ServiceName and Version identify the process in the traces.
+	ServiceName string
+	Version     string
+	// SampleRatio is the fraction of the new traces recorded, from 0 to 1.
+	// The spans with a parent follow its decision.
+	SampleRatio float64
+}
+
+// Setup installs the tracer provider exporting the spans and the W3C trace
+// context propagator. Until it is called, the spans are not recorded. The
+// returned function flushes the spans and stops the export.
+func Setup(config Config) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(
+		context.Background(),
+		otlptracehttp.WithEndpointURL(strings.TrimSuffix(config.Endpoint, "/")+"/v1/traces"),
+		otlptracehttp.WithHeaders(config.Headers),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating OTLP exporter: %w", err)
+	}
+
+	provider, err := newProvider(config, exporter)
+	if err != nil {
+		return nil, err
+	}
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return provider.Shutdown, nil
+}
+
+// newProvider returns the tracer provider sampling the traces and exporting
+// their spans in batches.
+func newProvider(config Config, exporter sdktrace.SpanExporter) (*sdktrace.TracerProvider, error) {
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(config.ServiceName),
+		semconv.ServiceVersion(config.Version),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error creating tracing resource: %w", err)
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// The children of a span sampled out are not recorded either
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	), nil
+}
+
+// Start starts a span, the child of the current span of the context or the
+// root of a new trace.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(scopeName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// StartClient starts a span of a request to a remote service.
+func StartClient(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(scopeName).Start(
+		ctx,
+		name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attributes...),
+	)
+}
+
+// RecordError marks the span as failed, when err is not nil.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,166 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// useProvider installs a provider exporting to memory, until the end of the
+// test.
+func useProvider(t *testing.T, ratio float64) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+
+	provider, err := newProvider(Config{ServiceName: "test", SampleRatio: ratio}, exporter)
+	if err != nil {
+		t.Fatalf("newProvider() error = %v", err)
+	}
+
+	previous, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	return exporter
+}
+
+func flush(t *testing.T) {
+	t.Helper()
+
+	if provider, ok := otel.GetTracerProvider().(interface{ ForceFlush(context.Context) error }); ok {
+		if err := provider.ForceFlush(context.Background()); err != nil {
+			t.Fatalf("ForceFlush() error = %v", err)
+		}
+	}
+}
+
+func TestChildrenOfSampledOutRun(t *testing.T) {
+	exporter := useProvider(t, 0)
+
+	ctx, run := Start(context.Background(), "scheduler.run")
+	_, check := Start(ctx, "certificate.check")
+	check.End()
+	run.End()
+
+	flush(t)
+
+	// The checks do not become the roots of traces of their own
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Fatalf("exported %d spans, want none", len(spans))
+	}
+}
+
+func TestChildrenOfRun(t *testing.T) {
+	exporter := useProvider(t, 1)
+
+	ctx, run := Start(context.Background(), "scheduler.run")
+	_, check := StartClient(ctx, "tcp.connect")
+	RecordError(check, errors.New("connection refused"))
+	RecordError(check, nil)
+	check.End()
+	run.End()
+
+	flush(t)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+
+	child, root := spans[0], spans[1]
+	if child.Parent.SpanID() != root.SpanContext.SpanID() || child.SpanContext.TraceID() != root.SpanContext.TraceID() {
+		t.Errorf("tcp.connect is not a child of scheduler.run")
+	}
+
+	if child.Status.Code != codes.Error || child.Status.Description != "connection refused" {
+		t.Errorf("status = %+v, want the error", child.Status)
+	}
+}
+
+func TestTraceParent(t *testing.T) {
+	exporter := useProvider(t, 0)
+
+	const (
+		traceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parentID = "00f067aa0ba902b7"
+	)
+
+	// The caller sampled its trace: the check is recorded whatever the ratio
+	header := http.Header{"Traceparent": {"00-" + traceID + "-" + parentID + "-01"}}
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(header))
+
+	_, check := Start(ctx, "certificate.check")
+	check.End()
+
+	flush(t)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(spans))
+	}
+
+	if spans[0].SpanContext.TraceID().String() != traceID || spans[0].Parent.SpanID().String() != parentID {
+		t.Errorf("span = %s/%s, want a child of the traceparent", spans[0].SpanContext.TraceID(), spans[0].Parent.SpanID())
+	}
+}
+
+func TestSetupExportsOTLP(t *testing.T) {
+	received := make(chan *http.Request, 1)
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+
+		select {
+		case received <- r:
+		default:
+		}
+	}))
+	defer collector.Close()
+
+	previous, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	defer func() {
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(previousPropagator)
+	}()
+
+	shutdown, err := Setup(Config{
+		Endpoint:    collector.URL + "/",
+		Headers:     map[string]string{"authorization": "Bearer secret"},
+		ServiceName: "test",
+		SampleRatio: 1,
+	})
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+
+	_, span := Start(context.Background(), "certificate.check")
+	span.End()
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown() error = %v", err)
+	}
+
+	select {
+	case r := <-received:
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" || r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("export request = %s %s, authorization %q", r.Method, r.URL.Path, r.Header.Get("Authorization"))
+		}
+	default:
+		t.Fatal("no span exported")
+	}
+}
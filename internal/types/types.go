@@ -4,6 +4,22 @@ import (
 	"time"
 )
 
+// Check statuses, derived from the days remaining before expiry.
+const (
+	StatusUnchecked = "unchecked"
+	StatusFailed    = "failed"
+	StatusExpired   = "expired"
+	StatusCritical  = "critical"
+	StatusWarning   = "warning"
+	StatusOK        = "ok"
+)
+
+// Thresholds (in days remaining) used by the dashboard to highlight certificates.
+const (
+	CriticalDays = 15
+	WarningDays  = 30
+)
+
 type Project struct {
 	ID            string
 	Name          string
@@ -11,6 +27,25 @@ type Project struct {
 	Port          string
 	Type          string
 	AllowInsecure bool
+	Tags          []string
+	// Team owning the project. Projects without team are shared with every team.
+	Team string
+	// ManagedBy is the source declaring the project, e.g. "config" for the
+	// configuration file. Managed projects are read-only in the UI and the
+	// API; the projects added by the users have no source.
+	ManagedBy string
+}
+
+// Sources managing projects.
+const (
+	ManagedByConfig = "config"
+	// ManagedBySelf is the certificate of the server itself.
+	ManagedBySelf = "self"
+)
+
+// Managed reports whether the project is declared by a source and read-only.
+func (p Project) Managed() bool {
+	return p.ManagedBy != ""
 }
 
 type CertificateCheck struct {
@@ -20,11 +55,16 @@ type CertificateCheck struct {
 	ProjectName   string
 	Domains       string
 	IP            string
-	Issuer 	      string
+	Issuer        string
 	ExpiryDate    string
 	DaysRemaining int
 }
 
+// Status returns the status of the check.
+func (c CertificateCheck) Status() string {
+	return StatusForDays(c.DaysRemaining)
+}
+
 type ProjectCheckSummary struct {
 	ProjectID     string
 	ProjectName   string
@@ -32,10 +72,197 @@ type ProjectCheckSummary struct {
 	Port          string
 	Type          string
 	AllowInsecure bool
+	Tags          []string
+	Team          string
 	CheckTime     *time.Time
 	Domains       string
 	IP            string
-	Issuer 	      string
+	Issuer        string
 	ExpiryDate    string
 	DaysRemaining *int
 }
+
+// Status returns the status of the latest check of the project.
+func (s ProjectCheckSummary) Status() string {
+	if s.DaysRemaining == nil {
+		return StatusUnchecked
+	}
+
+	return StatusForDays(*s.DaysRemaining)
+}
+
+// StatusForDays returns the status matching a number of days remaining.
+// A failed check is stored with -1 days remaining.
+func StatusForDays(days int) string {
+	switch {
+	case days == -1:
+		return StatusFailed
+	case days < 0:
+		return StatusExpired
+	case days < CriticalDays:
+		return StatusCritical
+	case days < WarningDays:
+		return StatusWarning
+	default:
+		return StatusOK
+	}
+}
+
+// API key scopes. The admin scope grants every other scope.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeCheck = "check"
+	ScopeAdmin = "admin"
+)
+
+// APIKey is a named API key. Only the hash of the key is stored: the Prefix
+// identifies the key without revealing it. A key with Teams is restricted to
+// the projects of these teams.
+type APIKey struct {
+	ID         string
+	Name       string
+	Prefix     string
+	Hash       string
+	Scopes     []string
+	Teams      []string
+	CreatedAt  time.Time
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+// Web UI roles, each role grants the rights of the previous ones.
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// RoleRank orders the roles: the higher rank grants more rights. Unknown
+// roles have the rank 0 and grant nothing.
+func RoleRank(role string) int {
+	switch role {
+	case RoleViewer:
+		return 1
+	case RoleEditor:
+		return 2
+	case RoleAdmin:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// User providers.
+const (
+	ProviderLocal = "local"
+	ProviderOIDC  = "oidc"
+)
+
+// User is a user of the web UI, either local or provisioned on its first
+// OpenID Connect login. Only the bcrypt hash of the password of the local
+// users is stored.
+type User struct {
+	ID           string
+	Username     string
+	PasswordHash string
+	Role         string
+	Provider     string
+	// Teams whose projects the user can access, the admins access every project.
+	Teams     []string
+	CreatedAt time.Time
+	// Issuer and Subject identify the OpenID Connect users: their username,
+	// which the identity provider may change or reuse, is only displayed.
+	Issuer  string
+	Subject string
+}
+
+// Session is a logged in web UI session. Only the hash of the session token
+// (sent to the browser in a cookie) is stored.
+type Session struct {
+	TokenHash string
+	UserID    string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// Audit log actions.
+const (
+	AuditProjectCreate   = "project.create"
+	AuditProjectUpdate   = "project.update"
+	AuditProjectDelete   = "project.delete"
+	AuditProjectCheck    = "project.check"
+	AuditRefresh         = "refresh"
+	AuditAPIKeyCreate    = "api_key.create"
+	AuditAPIKeyRevoke    = "api_key.revoke"
+	AuditUserCreate      = "user.create"
+	AuditUserUpdate      = "user.update"
+	AuditUserDelete      = "user.delete"
+	AuditCandidateIgnore = "candidate.ignore"
+)
+
+// Audit log sources, telling how the change was made.
+const (
+	AuditSourceWeb       = "web"
+	AuditSourceAPI       = "api"
+	AuditSourceWebSocket = "websocket"
+	AuditSourceCLI       = "cli"
+	AuditSourceReconcile = "reconcile"
+)
+
+// AuditEntry records who changed what. Before and After are JSON snapshots of
+// the target, Before is empty on creation and After on deletion.
+type AuditEntry struct {
+	ID         int64
+	Time       time.Time
+	Actor      string
+	Source     string
+	RemoteAddr string
+	Action     string
+	TargetType string
+	TargetID   string
+	TargetName string
+	Before     string
+	After      string
+}
+
+// AuditFilter selects the audit log entries, most recent first. Empty fields
+// do not filter.
+type AuditFilter struct {
+	Actor    string
+	Action   string
+	TargetID string
+	Since    *time.Time
+	Limit    int
+	Offset   int
+}
+
+// Candidate statuses.
+const (
+	CandidatePending  = "pending"
+	CandidateApproved = "approved"
+	CandidateIgnored  = "ignored"
+)
+
+// Candidate is an endpoint found by a discovery, such as the Certificate
+// Transparency logs or a DNS zone, proposed as a new project until it is
+// approved or ignored.
+type Candidate struct {
+	ID   string
+	Host string
+	Port string
+	// Type is the project type proposed for the endpoint.
+	Type string
+	// Domain is the configured domain or zone the host was found for.
+	Domain string
+	Source string
+	// Issuer and NotAfter describe the latest certificate seen for the host.
+	Issuer    string
+	NotAfter  time.Time
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Status    string
+	// ProjectID is the project created when the candidate was approved.
+	ProjectID string
+}
@@ -1,50 +1,66 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"fmt"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"leblanc.io/open-go-ssl-checker/internal/logger"
 	"leblanc.io/open-go-ssl-checker/internal/store"
+	"leblanc.io/open-go-ssl-checker/internal/types"
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		// Allow all origins for simplicity.
-		// In production, you should check r.Header.Get("Origin")
-		// against a list of allowed origins.
-		return true
-	},
+// ClientAccess is what a client is allowed to do.
+type ClientAccess struct {
+	// CanRefresh is false for the read-only visitors, whose refresh requests are ignored.
+	CanRefresh bool
+	// CanView reports whether the client can see the projects of the team.
+	CanView func(team string) bool
+	// OnRefresh, when set, is called for each refresh request triggering the checks.
+	OnRefresh func()
 }
 
 type Client struct {
-	conn *websocket.Conn
-	send chan []byte
+	conn   *websocket.Conn
+	send   chan []byte
+	access ClientAccess
 }
 
 type Hub struct {
 	clients          map[*Client]bool
-	broadcast        chan []byte // Messages to send (serialized JSON data)
+	broadcast        chan []types.ProjectCheckSummary // Summaries to send, filtered for each client
 	register         chan *Client
 	unregister       chan *Client
-	store            *store.Store // To retrieve updated data
-	mu               sync.Mutex   // To protect access to `clients`
+	store            store.SummaryRepository // To retrieve updated data
+	mu               sync.Mutex              // To protect access to `clients`
 	refreshRequested chan struct{}
+	upgrader         websocket.Upgrader
+	shutdown         chan struct{}
+	closing          bool           // Set by Shutdown, protected by mu
+	pumps            sync.WaitGroup // The writePump goroutines, sending the close frames
 }
 
-func NewHub(s *store.Store) *Hub {
+// NewHub returns a hub accepting the connections whose origin passes
+// checkOrigin, so other sites cannot open a connection with the cookies of the
+// visitors.
+func NewHub(s store.SummaryRepository, checkOrigin func(r *http.Request) bool) *Hub {
 	return &Hub{
 		clients:          make(map[*Client]bool),
-		broadcast:        make(chan []byte),
+		broadcast:        make(chan []types.ProjectCheckSummary),
 		register:         make(chan *Client),
 		unregister:       make(chan *Client),
 		store:            s,
 		refreshRequested: make(chan struct{}, 1),
+		shutdown:         make(chan struct{}),
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     checkOrigin,
+		},
 	}
 }
 
@@ -53,9 +69,17 @@ func (h *Hub) Run() {
 		select {
 		case client := <-h.register:
 			h.mu.Lock()
+			if h.closing {
+				// Connected during the shutdown: only send the close frame
+				close(client.send)
+				h.mu.Unlock()
+
+				continue
+			}
+
 			h.clients[client] = true
 
-			log.Println("WebSocket client registered")
+			logger.Logger.Debug("WebSocket client registered")
 			h.mu.Unlock()
 			// Send current state on initial connection
 			h.sendCurrentState(client)
@@ -65,19 +89,34 @@ func (h *Hub) Run() {
 			if _, ok := h.clients[client]; ok {
 				delete(h.clients, client)
 				close(client.send)
-				log.Println("WebSocket client unregistered")
+				logger.Logger.Debug("WebSocket client unregistered")
 			}
 			h.mu.Unlock()
 
-		case message := <-h.broadcast: // This channel will be used by NotifyUpdate
+		case <-h.shutdown:
 			h.mu.Lock()
 			for client := range h.clients {
+				close(client.send)
+				delete(h.clients, client)
+			}
+			h.mu.Unlock()
+
+		case summaries := <-h.broadcast: // This channel will be used by NotifyUpdate
+			h.mu.Lock()
+			for client := range h.clients {
+				message, err := client.message(summaries)
+				if err != nil {
+					logger.Logger.Error("WebSocket Hub error - Marshal summaries", "error", err)
+
+					continue
+				}
+
 				select {
 				case client.send <- message:
 				default: // Do not block if the client's buffer is full
 					close(client.send)
 					delete(h.clients, client)
-					log.Println("WebSocket client unregistered (buffer full or error)")
+					logger.Logger.Debug("WebSocket client unregistered (buffer full or error)")
 				}
 			}
 			h.mu.Unlock()
@@ -87,22 +126,16 @@ func (h *Hub) Run() {
 
 // NotifyUpdate is called when data has changed and needs to be broadcast.
 func (h *Hub) NotifyUpdate() {
-	log.Println("Update notification received, preparing WebSocket broadcast...")
+	logger.Logger.Debug("Update notification received, preparing WebSocket broadcast")
 
 	summaries, err := h.store.GetLatestChecksSummary()
 	if err != nil {
-		log.Printf("WebSocket Hub error - GetLatestChecksSummary: %v", err)
+		logger.Logger.Error("WebSocket Hub error - GetLatestChecksSummary", "error", err)
 
 		return
 	}
 
-	jsonData, err := json.Marshal(summaries)
-	if err != nil {
-		log.Printf("WebSocket Hub error - Marshal summaries: %v", err)
-
-		return
-	}
-	h.broadcast <- jsonData // Send JSON data to broadcast channel
+	h.broadcast <- summaries // Send the summaries to the broadcast channel
 }
 
 // RefreshRequests returns a read-only channel that emits a signal
@@ -111,16 +144,31 @@ func (h *Hub) RefreshRequests() <-chan struct{} {
 	return h.refreshRequested
 }
 
-// ServeWs handles client WebSocket requests.
-func (h *Hub) ServeWs(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+// ServeWs handles client WebSocket requests. The client only receives the
+// summaries of the projects it can view.
+func (h *Hub) ServeWs(w http.ResponseWriter, r *http.Request, access ClientAccess) {
+	// The pumps are counted under the lock, so Shutdown waits for every client
+	// connected before it.
+	h.mu.Lock()
+	if h.closing {
+		h.mu.Unlock()
+		http.Error(w, "Server shutting down.", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	h.pumps.Add(1)
+	h.mu.Unlock()
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("WebSocket Upgrade error: %v", err)
+		h.pumps.Done()
+		logger.Logger.Error("WebSocket Upgrade error", "error", err)
 
 		return
 	}
 
-	client := &Client{conn: conn, send: make(chan []byte, 256)}
+	client := &Client{conn: conn, send: make(chan []byte, 256), access: access}
 	h.register <- client
 
 	// Allow receiving messages from the client (if necessary)
@@ -129,18 +177,60 @@ func (h *Hub) ServeWs(w http.ResponseWriter, r *http.Request) {
 	go client.readPump(h)  // Pass the hub to allow unregistering
 }
 
+// Shutdown sends a close frame to every client, telling it the server is going
+// away, and refuses the new connections. It waits for the frames to be sent
+// until ctx is done.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	h.closing = true
+	h.mu.Unlock()
+
+	select {
+	case h.shutdown <- struct{}{}:
+	case <-ctx.Done():
+		return fmt.Errorf("error closing the WebSocket connections: %w", ctx.Err())
+	}
+
+	closed := make(chan struct{})
+
+	go func() {
+		h.pumps.Wait()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("error closing the WebSocket connections: %w", ctx.Err())
+	}
+}
+
+// closeMessage is the payload of the close frame sent when the hub closes the
+// connection.
+func (h *Hub) closeMessage() []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closing {
+		return websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	}
+
+	return []byte{}
+}
+
 // sendCurrentState sends the current state of data to the specified client.
 func (h *Hub) sendCurrentState(client *Client) {
 	summaries, err := h.store.GetLatestChecksSummary()
 	if err != nil {
-		log.Printf("WebSocket Hub error - sendCurrentState - GetLatestChecksSummary: %v", err)
+		logger.Logger.Error("WebSocket Hub error - sendCurrentState - GetLatestChecksSummary", "error", err)
 
 		return
 	}
 
-	jsonData, err := json.Marshal(summaries)
+	jsonData, err := client.message(summaries)
 	if err != nil {
-		log.Printf("WebSocket Hub error - sendCurrentState - Marshal summaries: %v", err)
+		logger.Logger.Error("WebSocket Hub error - sendCurrentState - Marshal summaries", "error", err)
 
 		return
 	}
@@ -150,13 +240,26 @@ func (h *Hub) sendCurrentState(client *Client) {
 	select {
 	case client.send <- jsonData:
 	default:
-		log.Printf(
-			"Unable to send initial state to client %v (buffer full or closed)",
-			client.conn.RemoteAddr(),
+		logger.Logger.Warn(
+			"Unable to send initial state to client (buffer full or closed)",
+			"remote_addr", client.conn.RemoteAddr().String(),
 		)
 	}
 }
 
+// message serializes the summaries of the projects the client can view.
+func (c *Client) message(summaries []types.ProjectCheckSummary) ([]byte, error) {
+	visible := make([]types.ProjectCheckSummary, 0, len(summaries))
+
+	for _, s := range summaries {
+		if c.access.CanView == nil || c.access.CanView(s.Team) {
+			visible = append(visible, s)
+		}
+	}
+
+	return json.Marshal(visible)
+}
+
 func (c *Client) readPump(hub *Hub) {
 	defer func() {
 		hub.unregister <- c
@@ -189,7 +292,7 @@ func (c *Client) readPump(hub *Hub) {
 				websocket.CloseGoingAway,
 				websocket.CloseAbnormalClosure,
 			) {
-				log.Printf("WebSocket readPump error: %v", err)
+				logger.Logger.Error("WebSocket readPump error", "error", err)
 			}
 
 			break
@@ -197,19 +300,29 @@ func (c *Client) readPump(hub *Hub) {
 
 		err = c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 		if err != nil {
-			log.Printf("WebSocket readPump error (SetReadDeadline): %v", err)
+			logger.Logger.Error("WebSocket readPump error (SetReadDeadline)", "error", err)
 			return
 		}
 
 		if messageType == websocket.TextMessage {
 			if string(message) == "refresh" {
+				if !c.access.CanRefresh {
+					logger.Logger.Info("Refresh request from a read-only client; ignoring")
+
+					continue
+				}
+
 				// Signal a refresh request without blocking
 				select {
 				case hub.refreshRequested <- struct{}{}:
-					log.Println("Received refresh request from client; triggering full checks...")
+					logger.Logger.Info("Received refresh request from client; triggering full checks")
+
+					if c.access.OnRefresh != nil {
+						c.access.OnRefresh()
+					}
 				default:
 					// If a refresh signal is already pending, avoid piling up
-					log.Println("Refresh request already pending; ignoring duplicate.")
+					logger.Logger.Info("Refresh request already pending; ignoring duplicate")
 				}
 			}
 		}
@@ -224,6 +337,7 @@ func (c *Client) writePump(hub *Hub) {
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
+		hub.pumps.Done()
 	}()
 
 	for {
@@ -236,7 +350,7 @@ func (c *Client) writePump(hub *Hub) {
 
 			if !ok {
 				// The hub closed the c.send channel
-				err := c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				err := c.conn.WriteMessage(websocket.CloseMessage, hub.closeMessage())
 				if err != nil {
 					return
 				}
@@ -245,7 +359,7 @@ func (c *Client) writePump(hub *Hub) {
 			}
 
 			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
-				log.Printf("WebSocket writePump error: %v", err)
+				logger.Logger.Error("WebSocket writePump error", "error", err)
 
 				return // Important: If writing fails, the client has probably gone away.
 			}
@@ -256,7 +370,7 @@ func (c *Client) writePump(hub *Hub) {
 			}
 
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-				log.Printf("WebSocket writePump error (ping): %v", err)
+				logger.Logger.Error("WebSocket writePump error (ping)", "error", err)
 
 				return
 			}
@@ -1,26 +1,42 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"embed"
 	"errors"
 	"flag"
 	"fmt"
-	"log"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/ilyakaznacheev/cleanenv"
+	"leblanc.io/open-go-ssl-checker/internal/audit"
+	"leblanc.io/open-go-ssl-checker/internal/auth"
 	"leblanc.io/open-go-ssl-checker/internal/checker"
 	"leblanc.io/open-go-ssl-checker/internal/config"
+	"leblanc.io/open-go-ssl-checker/internal/discovery"
 	"leblanc.io/open-go-ssl-checker/internal/handlers"
+	"leblanc.io/open-go-ssl-checker/internal/https"
+	"leblanc.io/open-go-ssl-checker/internal/logger"
+	"leblanc.io/open-go-ssl-checker/internal/metrics"
 	"leblanc.io/open-go-ssl-checker/internal/middleware"
+	"leblanc.io/open-go-ssl-checker/internal/projectio"
+	"leblanc.io/open-go-ssl-checker/internal/reconcile"
+	"leblanc.io/open-go-ssl-checker/internal/retention"
 	"leblanc.io/open-go-ssl-checker/internal/scheduler"
 	"leblanc.io/open-go-ssl-checker/internal/store"
+	"leblanc.io/open-go-ssl-checker/internal/tracing"
+	"leblanc.io/open-go-ssl-checker/internal/types"
 	"leblanc.io/open-go-ssl-checker/internal/websocket"
 )
 
@@ -28,7 +44,20 @@ import (
 var staticFs embed.FS
 
 type args struct {
-	ConfigPath string
+	ConfigPath   string
+	CreateAPIKey string
+	RevokeAPIKey string
+	ListAPIKeys  bool
+	Scopes       string
+	Expires      time.Duration
+	CreateUser   string
+	SetPassword  string
+	DeleteUser   string
+	ListUsers    bool
+	SetRole      string
+	Role         string
+	SetTeams     string
+	Teams        string
 }
 
 var cfg config.Config
@@ -47,39 +76,218 @@ func showVersion() {
 }
 
 func main() {
-	loadConfig(&cfg)
+	os.Exit(run())
+}
+
+// run runs the command of the arguments, or serves until SIGINT or SIGTERM,
+// and returns the exit code once everything is stopped.
+func run() int {
+	arguments, err := loadConfig(&cfg)
+	if err != nil {
+		fmt.Println(err)
+
+		return 2
+	}
+
+	if err := logger.Init(cfg.Server.LogLevel, cfg.Server.LogFormat); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		return 2
+	}
 
 	// Initialize the Store (Database)
-	dbStore, err := store.NewStore(cfg.Database.Driver, cfg.Database.Dsn)
+	dbStore, err := store.Open(cfg.Database.Driver, cfg.Database.Dsn)
 	if err != nil {
-		log.Fatalf("Error initializing store: %v", err)
+		logger.Logger.Error("Error initializing store", "error", err)
+
+		return 1
 	}
-	defer dbStore.Close()
+
+	defer func() {
+		if err := dbStore.Close(); err != nil {
+			logger.Logger.Error("Error closing the database", "error", err)
+		}
+	}()
 
 	if err := dbStore.InitSchema(); err != nil {
-		log.Fatalf("Error initializing DB schema: %v", err)
+		logger.Logger.Error("Error initializing DB schema", "error", err)
+
+		return 1
+	}
+
+	logger.Logger.Info("Database schema initialized/verified", "driver", cfg.Database.Driver)
+
+	if ran, err := runCommand(dbStore, arguments); ran {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+
+			return 1
+		}
+
+		return 0
+	}
+
+	// The settings which cannot be validated with the configuration are
+	// applied before starting anything, an error stops the startup
+	var certificates *https.Reloader
+
+	if tlsCfg := cfg.Server.TLS; tlsCfg.Enabled {
+		certificates, err = https.NewReloader(tlsCfg.CertFile, tlsCfg.KeyFile, tlsCfg.ReloadInterval)
+		if err != nil {
+			logger.Logger.Error("Error configuring HTTPS", "error", err)
+
+			return 1
+		}
+
+		certificates.Start()
+		defer certificates.Stop()
+
+		// The session and CSRF cookies must not leak over plain HTTP
+		cfg.Auth.SecureCookie = true
+	} else if tlsCfg.SelfMonitor.Enabled || tlsCfg.RedirectPort > 0 {
+		logger.Logger.Warn("server.tls.self_monitor and server.tls.redirect_port are ignored because server.tls.enabled is false")
+	}
+
+	if cfg.Tracing.Enabled {
+		flushTraces, err := setupTracing()
+		if err != nil {
+			logger.Logger.Error("Error configuring tracing", "error", err)
+
+			return 1
+		}
+
+		defer flushTraces()
+	}
+
+	sources, err := discoverySources()
+	if err != nil {
+		logger.Logger.Error("Error configuring Kubernetes discovery", "error", err)
+
+		return 1
+	}
+
+	oidc, err := newOIDC(dbStore)
+	if err != nil {
+		logger.Logger.Error("Error configuring OIDC login", "error", err)
+
+		return 1
+	}
+
+	// Listen before the first checks, so the check of the server itself waits
+	// for it to serve instead of being refused
+	serverAddress := net.JoinHostPort(cfg.Server.Host, strconv.Itoa(cfg.Server.Port))
+
+	listener, err := net.Listen("tcp", serverAddress)
+	if err != nil {
+		logger.Logger.Error("Error starting HTTP server", "error", err)
+
+		return 1
+	}
+
+	var redirectListener net.Listener
+
+	if certificates != nil && cfg.Server.TLS.RedirectPort > 0 {
+		redirectAddress := net.JoinHostPort(cfg.Server.Host, strconv.Itoa(cfg.Server.TLS.RedirectPort))
+
+		redirectListener, err = net.Listen("tcp", redirectAddress)
+		if err != nil {
+			listener.Close()
+			logger.Logger.Error("Error starting HTTP redirect server", "error", err)
+
+			return 1
+		}
 	}
 
-	log.Println("Database schema initialized/verified.")
+	csrf := middleware.NewCSRF(cfg.Server.AllowedOrigins, cfg.Auth.SecureCookie)
 
-	wsHub := websocket.NewHub(dbStore)
+	wsHub := websocket.NewHub(dbStore, csrf.CheckOrigin)
 	go wsHub.Run() // Start the hub in a goroutine
-	log.Println("WebSocket hub started.")
+	logger.Logger.Info("WebSocket hub started")
 
 	// Initialize the certificate checking service
-	certCheckerService := checker.NewCertificateService(dbStore, wsHub)
+	certCheckerService := checker.NewCertificateService(dbStore, wsHub, cfg.Checker.Timeout)
+	certCheckerService.Metrics = metrics.NewRegistry()
+	auditRecorder := audit.NewRecorder(dbStore)
+
+	// Apply the projects declared in the configuration before the first checks
+	projectsReconciler := reconcile.NewReconciler(
+		dbStore,
+		certCheckerService,
+		auditRecorder,
+		types.ManagedByConfig,
+		cfg.Projects.Prune,
+	)
+	projectsWatcher := reconcile.NewWatcher(
+		projectsReconciler,
+		func() ([]projectio.Entry, []string, error) {
+			return declaredProjects(arguments.ConfigPath)
+		},
+		cfg.Projects.WatchInterval,
+	)
+	projectsWatcher.Start()
+	defer projectsWatcher.Stop()
+
+	// The certificate of the server is a project of its own, deleted once the
+	// monitoring is disabled
+	selfReconciler := reconcile.NewReconciler(
+		dbStore,
+		certCheckerService,
+		auditRecorder,
+		types.ManagedBySelf,
+		true,
+	)
+	if _, err := selfReconciler.Reconcile(selfProjects(certificates)); err != nil {
+		logger.Logger.Error("Error monitoring the certificate of the server", "error", err)
+	}
+
+	if len(sources) > 0 {
+		discoveryRunner := discovery.NewRunner(
+			dbStore,
+			certCheckerService,
+			auditRecorder,
+			cfg.Discovery.Interval,
+			sources...,
+		)
+		discoveryRunner.Start()
+		defer discoveryRunner.Stop()
+	}
+
+	if ct := cfg.Discovery.CT; ct.Enabled {
+		ctDiscovery := discovery.NewCTDiscovery(discovery.NewCrtSh(ct.URL), dbStore, ct.Domains, ct.Interval)
+		ctDiscovery.Start()
+		defer ctDiscovery.Stop()
+	}
+
+	if dns := cfg.Discovery.DNS; dns.Enabled {
+		dnsDiscovery := discovery.NewDNSDiscovery(dnsZones(), dns.Ports, dbStore, dns.ProbeTimeout, dns.Interval)
+		dnsDiscovery.Start()
+		defer dnsDiscovery.Stop()
+	}
 
 	periodicCertChecker := scheduler.NewPeriodicChecker(
 		certCheckerService,
 		defaultPeriodicCheckInterval,
 	)
 	periodicCertChecker.Start()
-	defer periodicCertChecker.Stop()
+
+	if cfg.Retention.Enabled {
+		historyPruner := retention.NewPruner(
+			dbStore,
+			retention.Policy{
+				KeepAll:      time.Duration(cfg.Retention.KeepAllDays) * 24 * time.Hour,
+				Downsample:   cfg.Retention.Downsample,
+				MaxAgeMonths: cfg.Retention.MaxAgeMonths,
+			},
+			cfg.Retention.Interval,
+		)
+		historyPruner.Start()
+		defer historyPruner.Stop()
+	}
 
 	// Listen for WebSocket-driven refresh requests and trigger a full check run
 	go func() {
 		for range wsHub.RefreshRequests() {
-			log.Println("Manual refresh requested via WebSocket. Launching full certificate checks...")
+			logger.Logger.Info("Manual refresh requested via WebSocket, launching full certificate checks")
 			periodicCertChecker.RunOnce()
 		}
 	}()
@@ -89,56 +297,398 @@ func main() {
 		Store:   dbStore,
 		Checker: certCheckerService,
 		ApiKey:  cfg.Server.ApiKey,
+		Version: version,
+		Audit:   auditRecorder,
+
+		Scheduler: periodicCertChecker,
+
+		CandidateProjects: map[string]handlers.CandidateProject{
+			discovery.CandidateSourceCT:  {Team: cfg.Discovery.CT.Team, Tags: cfg.Discovery.CT.Tags},
+			discovery.CandidateSourceDNS: {Team: cfg.Discovery.DNS.Team, Tags: cfg.Discovery.DNS.Tags},
+		},
+	}
+
+	if cfg.Auth.Enabled {
+		appCtx.Sessions = auth.NewSessionManager(dbStore, dbStore, cfg.Auth.SessionTTL, cfg.Auth.SecureCookie)
+		appCtx.AnonymousRead = cfg.Auth.AnonymousRead
+		appCtx.OIDC = oidc
+
+		if users, err := dbStore.ListUsers(); err == nil && len(users) == 0 && appCtx.OIDC == nil {
+			logger.Logger.Warn("Web UI authentication is enabled but no user exists, create one with -create-user")
+		}
+	} else {
+		if cfg.Auth.OIDC.Enabled {
+			logger.Logger.Warn("auth.oidc is ignored because auth.enabled is false")
+		}
+
+		logger.Logger.Warn("Web UI authentication is disabled, anyone reaching the server can manage the projects")
 	}
 
 	// Configure the routes
 	router := mux.NewRouter()
-	router.Handle("/", middleware.LinkMiddleware(http.HandlerFunc(appCtx.IndexHandler))).
+	// Pages only reading data are open to the anonymous visitors when
+	// auth.anonymous_read is enabled, the others always require a login.
+	viewerPage := func(h http.HandlerFunc) http.Handler {
+		return appCtx.RequireViewer(middleware.LinkMiddleware(h))
+	}
+
+	router.Handle("/", viewerPage(appCtx.IndexHandler)).Methods("GET")
+	router.Handle("/add", appCtx.RequireEditor(http.HandlerFunc(appCtx.AddProjectHandler))).
+		Methods("GET", "POST")
+	router.Handle("/projects", viewerPage(appCtx.ProjectsHandler)).Methods("GET")
+	router.Handle("/edit/{uuid}", appCtx.RequireEditor(http.HandlerFunc(appCtx.EditProjectHandler))).
+		Methods("GET", "POST")
+	router.Handle("/import", appCtx.RequireEditor(http.HandlerFunc(appCtx.ImportHandler))).
+		Methods("GET", "POST")
+	router.Handle("/delete/{uuid}", appCtx.RequireEditor(http.HandlerFunc(appCtx.DeleteProjectHandler))).
+		Methods("POST")
+	router.Handle("/history/{uuid}", viewerPage(appCtx.HistoryHandler)).Methods("GET")
+	router.Handle("/export/projects", appCtx.RequireViewer(http.HandlerFunc(appCtx.ExportProjectsHandler))).
 		Methods("GET")
-	router.HandleFunc("/add", appCtx.AddProjectHandler).Methods("GET", "POST")
-	router.Handle("/projects", middleware.LinkMiddleware(http.HandlerFunc(appCtx.ProjectsHandler))).
+	router.Handle("/export/checks", appCtx.RequireViewer(http.HandlerFunc(appCtx.ExportChecksHandler))).
 		Methods("GET")
-	router.HandleFunc("/delete/{uuid}", appCtx.DeleteProjectHandler).Methods("POST")
-	router.Handle("/history/{uuid}", middleware.LinkMiddleware(http.HandlerFunc(appCtx.HistoryHandler))).
+	router.Handle("/discovered", appCtx.RequireEditor(http.HandlerFunc(appCtx.CandidatesHandler))).
 		Methods("GET")
-	router.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		wsHub.ServeWs(w, r)
-	})
+	router.Handle("/discovered/{id}/approve", appCtx.RequireEditor(http.HandlerFunc(appCtx.ApproveCandidateHandler))).
+		Methods("POST")
+	router.Handle("/discovered/{id}/ignore", appCtx.RequireEditor(http.HandlerFunc(appCtx.IgnoreCandidateHandler))).
+		Methods("POST")
+	router.Handle("/audit", appCtx.RequireAdmin(middleware.LinkMiddleware(http.HandlerFunc(appCtx.AuditHandler)))).
+		Methods("GET")
+	router.Handle("/ws", appCtx.RequireViewer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Read-only visitors receive the updates but cannot trigger a refresh
+		viewer := auth.ViewerFromContext(r.Context())
+		wsHub.ServeWs(w, r, websocket.ClientAccess{
+			CanRefresh: viewer.CanEdit(),
+			CanView:    viewer.Access().CanAccessTeam,
+			OnRefresh:  appCtx.RefreshAuditor(r),
+		})
+	})))
+	router.HandleFunc("/login", appCtx.LoginHandler).Methods("GET", "POST")
+	router.HandleFunc("/logout", appCtx.LogoutHandler).Methods("POST")
+	router.HandleFunc("/auth/oidc/login", appCtx.OIDCLoginHandler).Methods("GET")
+	router.HandleFunc("/auth/oidc/callback", appCtx.OIDCCallbackHandler).Methods("GET")
 
 	router.PathPrefix("/static/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFileFS(w, r, staticFs, strings.TrimLeft(r.RequestURI, "/"))
 	}).Methods("GET")
 
-	if cfg.Server.ApiKey == "" {
-		log.Println("WARNING: API key not set. The API will not be available.")
-	} else {
-		router.HandleFunc("/api/projects", appCtx.AddProjectAPIHandler).Methods("POST")
+	// The probes of the load balancers and orchestrators, without authentication
+	router.HandleFunc("/healthz", appCtx.HealthzHandler).Methods("GET")
+	router.HandleFunc("/readyz", appCtx.ReadyzHandler).Methods("GET")
+
+	if cfg.Metrics.Enabled {
+		if cfg.Metrics.Public {
+			router.HandleFunc("/metrics", appCtx.MetricsHandler).Methods("GET")
+		} else {
+			router.Handle("/metrics", appCtx.APIKeyMiddleware(types.ScopeRead, http.HandlerFunc(appCtx.MetricsHandler))).
+				Methods("GET")
+		}
+	}
+
+	if cfg.Server.ApiKey != "" {
+		logger.Logger.Warn("server.api_key is deprecated, create scoped API keys with -create-api-key instead")
 	}
 
+	appCtx.RegisterAPI(router)
+
 	// Start the web server
-	serverPort := net.JoinHostPort(cfg.Server.Host, strconv.Itoa(cfg.Server.Port))
+	server := &http.Server{
+		Handler:           csrf.Middleware(router),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	servers := map[string]*http.Server{"HTTP server": server}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	log.Printf("Starting server on %s", serverPort)
+	serverErr := make(chan error, 2)
 
-	if err := http.ListenAndServe(serverPort, router); err != nil {
-		log.Fatalf("Error starting HTTP server: %v", err)
+	go func() {
+		if certificates == nil {
+			logger.Logger.Info("Starting server", "address", serverAddress)
+			serverErr <- server.Serve(listener)
+
+			return
+		}
+
+		server.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certificates.GetCertificate,
+		}
+
+		logger.Logger.Info("Starting server", "address", serverAddress, "tls", true)
+		serverErr <- server.ServeTLS(listener, "", "")
+	}()
+
+	if redirectListener != nil {
+		redirectServer := &http.Server{
+			Handler:           https.RedirectHandler(cfg.Server.Port),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		servers["HTTP redirect server"] = redirectServer
+
+		go func() {
+			logger.Logger.Info("Redirecting HTTP to HTTPS", "address", redirectListener.Addr().String())
+			serverErr <- redirectServer.Serve(redirectListener)
+		}()
+	}
+
+	exitCode := 0
+
+	select {
+	case err := <-serverErr:
+		logger.Logger.Error("Error serving HTTP", "error", err)
+
+		exitCode = 1
+	case <-ctx.Done():
+		// A second signal kills the process without waiting
+		stop()
+		logger.Logger.Info("Shutting down", "timeout", cfg.Server.ShutdownTimeout)
+	}
+
+	shutdown(servers, wsHub, periodicCertChecker, certCheckerService)
+
+	return exitCode
+}
+
+// shutdown stops the HTTP servers once their requests in flight complete, sends
+// a close frame to the WebSocket clients and lets the running periodic and
+// background checks finish, within the shutdown timeout. The other jobs, then
+// the database, are stopped by the defers of run.
+func shutdown(
+	servers map[string]*http.Server,
+	hub *websocket.Hub,
+	periodicChecker *scheduler.PeriodicChecker,
+	certChecker *checker.CertificateService,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	components := map[string]func(context.Context) error{
+		"WebSocket hub":     hub.Shutdown,
+		"periodic checker":  periodicChecker.Shutdown,
+		"background checks": certChecker.Shutdown,
+	}
+	for name, server := range servers {
+		components[name] = server.Shutdown
+	}
+
+	var wg sync.WaitGroup
+
+	for name, shutdown := range components {
+		wg.Go(func() {
+			if err := shutdown(ctx); err != nil {
+				logger.Logger.Error("Error shutting down", "component", name, "error", err)
+			}
+		})
+	}
+
+	wg.Wait()
+	logger.Logger.Info("Server stopped")
+}
+
+// selfProjects returns the project checking the certificate of the server,
+// none when the server does not serve HTTPS or does not monitor itself.
+func selfProjects(certificates *https.Reloader) []projectio.Entry {
+	selfMonitor := cfg.Server.TLS.SelfMonitor
+	if certificates == nil || !selfMonitor.Enabled {
+		return nil
+	}
+
+	host := selfMonitor.Host
+	if host == "" {
+		host = selfHost(certificates.Leaf())
+	}
+
+	return []projectio.Entry{{
+		Name:          selfMonitor.Name,
+		Host:          host,
+		Port:          cfg.Server.Port,
+		Type:          "http",
+		AllowInsecure: selfMonitor.AllowInsecure,
+		Position:      1,
+	}}
+}
+
+// selfHost returns the first name of the certificate, the checks verifying
+// it, or the address of the server when the certificate has only wildcards.
+func selfHost(leaf *x509.Certificate) string {
+	for _, name := range leaf.DNSNames {
+		if !strings.HasPrefix(name, "*.") {
+			return name
+		}
 	}
+
+	if ip := net.ParseIP(cfg.Server.Host); cfg.Server.Host == "" || (ip != nil && ip.IsUnspecified()) {
+		return "localhost"
+	}
+
+	return cfg.Server.Host
 }
 
-func loadConfig(cfg *config.Config) {
-	args := processArgs(&cfg)
+func loadConfig(cfg *config.Config) (args, error) {
+	args := processArgs(cfg)
 	// read configuration from the file and environment variables
 	if _, err := os.Stat(args.ConfigPath); errors.Is(err, os.ErrNotExist) {
 		if err := cleanenv.ReadEnv(cfg); err != nil {
-			fmt.Println(err)
-			os.Exit(2)
+			return args, err
 		}
 	} else {
-		if err := cleanenv.ReadConfig(args.ConfigPath, &cfg); err != nil {
-			fmt.Println(err)
-			os.Exit(2)
+		if err := cleanenv.ReadConfig(args.ConfigPath, cfg); err != nil {
+			return args, err
 		}
 	}
+
+	return args, cfg.Validate()
+}
+
+// newOIDC returns the OpenID Connect login, nil when it is disabled.
+func newOIDC(users store.UserRepository) (*auth.OIDC, error) {
+	oidcCfg := cfg.Auth.OIDC
+	if !cfg.Auth.Enabled || !oidcCfg.Enabled {
+		return nil, nil
+	}
+
+	oidc, err := auth.NewOIDC(auth.OIDCConfig{
+		Issuer:          oidcCfg.Issuer,
+		ClientID:        oidcCfg.ClientID,
+		ClientSecret:    oidcCfg.ClientSecret,
+		RedirectURL:     oidcCfg.RedirectURL,
+		Scopes:          oidcCfg.Scopes,
+		UsernameClaim:   oidcCfg.UsernameClaim,
+		GroupsClaim:     oidcCfg.GroupsClaim,
+		ViewerGroups:    oidcCfg.ViewerGroups,
+		EditorGroups:    oidcCfg.EditorGroups,
+		AdminGroups:     oidcCfg.AdminGroups,
+		DefaultRole:     oidcCfg.DefaultRole,
+		TeamGroupPrefix: oidcCfg.TeamGroupPrefix,
+	}, users, cfg.Auth.SecureCookie)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Logger.Info("OIDC login enabled", "issuer", oidcCfg.Issuer)
+
+	return oidc, nil
+}
+
+// discoverySources returns the enabled discovery sources. A source which
+// cannot be configured stops the startup, rather than being silently not
+// discovered.
+func discoverySources() ([]discovery.Source, error) {
+	var sources []discovery.Source
+
+	if k := cfg.Discovery.Kubernetes; k.Enabled {
+		clusters, err := discovery.NewKubernetesSources(discovery.KubernetesConfig{
+			Kubeconfig: k.Kubeconfig,
+			Contexts:   k.Contexts,
+			Namespaces: k.Namespaces,
+			Team:       k.Team,
+			Tags:       k.Tags,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, cluster := range clusters {
+			sources = append(sources, cluster)
+		}
+	}
+
+	return sources, nil
+}
+
+// setupTracing exports the spans to the configured OpenTelemetry collector. It
+// returns the function flushing the spans on exit.
+func setupTracing() (func(), error) {
+	// The headers are checked by config.Validate
+	headers, _ := config.ParseHeaders(cfg.Tracing.Headers)
+
+	shutdown, err := tracing.Setup(tracing.Config{
+		Endpoint:    cfg.Tracing.Endpoint,
+		Headers:     headers,
+		ServiceName: cfg.Tracing.ServiceName,
+		Version:     version,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := shutdown(ctx); err != nil {
+			logger.Logger.Error("Error flushing the traces", "error", err)
+		}
+	}, nil
+}
+
+// dnsZones returns the zone files and the zone transfers of the DNS
+// discovery, checked by config.Validate.
+func dnsZones() []discovery.Zone {
+	var zones []discovery.Zone
+
+	for _, file := range cfg.Discovery.DNS.ZoneFiles {
+		origin, path, found := strings.Cut(file, "=")
+		if !found {
+			origin, path = "", file
+		}
+
+		zones = append(zones, discovery.NewZoneFile(origin, path))
+	}
+
+	for _, transfer := range cfg.Discovery.DNS.AXFR {
+		zone, server, _ := config.ParseAXFR(transfer)
+		zones = append(zones, discovery.NewAXFR(zone, server))
+	}
+
+	return zones
+}
+
+// declaredProjects reads the projects declared in the configuration file and
+// in the projects file, with these files to watch. The configuration is read
+// again, so the changes are applied without restarting.
+func declaredProjects(configPath string) ([]projectio.Entry, []string, error) {
+	var c config.Config
+
+	files := []string{configPath}
+
+	var err error
+	if _, statErr := os.Stat(configPath); errors.Is(statErr, os.ErrNotExist) {
+		err = cleanenv.ReadEnv(&c)
+	} else {
+		err = cleanenv.ReadConfig(configPath, &c)
+	}
+
+	if err != nil {
+		return nil, files, fmt.Errorf("error reading configuration: %w", err)
+	}
+
+	entries := c.Projects.Items
+	for i := range entries {
+		entries[i].Position = i + 1
+	}
+
+	if c.Projects.File == "" {
+		return entries, files, nil
+	}
+
+	files = append(files, c.Projects.File)
+
+	data, err := os.ReadFile(c.Projects.File)
+	if err != nil {
+		return nil, files, fmt.Errorf("error reading projects file: %w", err)
+	}
+
+	fileEntries, err := projectio.Parse(projectio.DetectFormat(c.Projects.File, "", data), data)
+	if err != nil {
+		return nil, files, fmt.Errorf("error reading projects file %s: %w", c.Projects.File, err)
+	}
+
+	return append(entries, fileEntries...), files, nil
 }
 
 func processArgs(cfg interface{}) args {
@@ -148,6 +698,44 @@ func processArgs(cfg interface{}) args {
 
 	flag.StringVar(&arguments.ConfigPath, "c", "config.yaml", "Path to configuration file")
 	versionFlag := flag.Bool("version", false, "Show version")
+	flag.StringVar(&arguments.CreateAPIKey, "create-api-key", "", "Create an API key with this name and exit")
+	flag.StringVar(
+		&arguments.Scopes,
+		"scopes",
+		types.ScopeRead,
+		"Comma separated scopes of the created API key (read, write, check, admin)",
+	)
+	flag.DurationVar(&arguments.Expires, "expires", 0, "Validity of the created API key (e.g. 720h, 0 = never expires)")
+	flag.StringVar(&arguments.RevokeAPIKey, "revoke-api-key", "", "Revoke the API key with this name and exit")
+	flag.BoolVar(&arguments.ListAPIKeys, "list-api-keys", false, "List the API keys and exit")
+	flag.StringVar(
+		&arguments.CreateUser,
+		"create-user",
+		"",
+		"Create a web UI user with this name and exit (the password is read from the standard input)",
+	)
+	flag.StringVar(
+		&arguments.SetPassword,
+		"set-password",
+		"",
+		"Change the password of this web UI user and exit (the password is read from the standard input)",
+	)
+	flag.StringVar(
+		&arguments.Role,
+		"role",
+		types.RoleAdmin,
+		"Role of the created web UI user, or new role with -set-role (viewer, editor, admin)",
+	)
+	flag.StringVar(&arguments.SetRole, "set-role", "", "Change the role of this web UI user and exit")
+	flag.StringVar(
+		&arguments.Teams,
+		"teams",
+		"",
+		"Comma separated teams of the created web UI user or API key, or new teams with -set-teams",
+	)
+	flag.StringVar(&arguments.SetTeams, "set-teams", "", "Change the teams of this web UI user and exit")
+	flag.StringVar(&arguments.DeleteUser, "delete-user", "", "Delete this web UI user and exit")
+	flag.BoolVar(&arguments.ListUsers, "list-users", false, "List the web UI users and exit")
 
 	fu := flag.Usage
 	flag.Usage = func() {
@@ -10,6 +10,9 @@ const updateDefaultPort = () => {
 
 document.addEventListener('DOMContentLoaded', function() {
     const protocolSelect = document.getElementById('type');
-    updateDefaultPort();
+    // Keep the port of the edited project
+    if (!document.getElementById('port').value) {
+        updateDefaultPort();
+    }
     protocolSelect.addEventListener('change', updateDefaultPort);
 });
\ No newline at end of file